@@ -0,0 +1,460 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+// Diagnostic is a single problem found by the checker
+type Diagnostic struct {
+	Line    int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[line %d] Type error: %s", d.Line, d.Message)
+}
+
+// binding is what the checker knows about a name in scope
+type binding struct {
+	Type      Type
+	Signature *signature // set while the name still holds a function declaration
+}
+
+// Checker is a gradual type checker: annotated declarations are checked against what flows into them,
+// literals and operators are inferred, and everything else is Any so unannotated code never produces a
+// diagnostic the interpreter wouldn't also hit. implements parser.ExprVisitor and parser.StmtVisitor
+type Checker struct {
+	Diagnostics []Diagnostic
+
+	scopes   []map[string]*binding
+	function *signature // the function whose body is being checked, nil at the top level
+}
+
+// NewChecker creates a checker that knows the arity of the given native functions
+func NewChecker(natives map[string]int) *Checker {
+	globals := map[string]*binding{}
+	for name, arity := range natives {
+		globals[name] = &binding{
+			Type:      Type{Kind: Function},
+			Signature: &signature{Name: name, Arity: arity, Return: anyType},
+		}
+	}
+
+	return &Checker{scopes: []map[string]*binding{globals}}
+}
+
+// Check checks a program and returns every diagnostic found, in source order
+func (c *Checker) Check(stmts []parser.Stmt) []Diagnostic {
+	c.checkStmts(stmts)
+	return c.Diagnostics
+}
+
+func (c *Checker) report(line int, format string, args ...any) {
+	c.Diagnostics = append(c.Diagnostics, Diagnostic{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *Checker) beginScope() { c.scopes = append(c.scopes, map[string]*binding{}) }
+func (c *Checker) endScope()   { c.scopes = c.scopes[:len(c.scopes)-1] }
+
+func (c *Checker) define(name string, b *binding) {
+	c.scopes[len(c.scopes)-1][name] = b
+}
+
+// lookup finds the innermost binding for name, names the checker has never seen are nil
+func (c *Checker) lookup(name string) *binding {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if b, ok := c.scopes[i][name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *Checker) checkStmts(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		// a bad statement has no partial one when nothing of it parsed
+		if stmt != nil {
+			stmt.Accept(c)
+		}
+	}
+}
+
+func (c *Checker) infer(expr parser.Expr) Type {
+	if expr == nil {
+		return anyType
+	}
+	t, _ := expr.Accept(c)
+	if t, ok := t.(Type); ok {
+		return t
+	}
+	return anyType
+}
+
+// requireNumber mirrors checkNumberOperand(s) in the interpreter
+func (c *Checker) requireNumber(operator lexer.Token, t Type) {
+	switch {
+	case t.Kind == Any:
+	case t.Kind == Nil:
+		c.report(operator.Line, "nil used as an operand of '%s'.", operator.Lexeme)
+	case t.Kind != Number:
+		c.report(operator.Line, "operand of '%s' must be a number, got %s.", operator.Lexeme, t)
+	case t.Nullable:
+		c.report(operator.Line, "operand of '%s' may be nil, its type is %s.", operator.Lexeme, t)
+	}
+}
+
+// StmtVisitor implementation below ----------------------------------------------------------------
+func (c *Checker) VisitPrintStmt(stmt *parser.PrintStmt) error {
+	c.infer(stmt.Expr)
+	return nil
+}
+
+func (c *Checker) VisitExpressionStmt(stmt *parser.ExpressionStmt) error {
+	c.infer(stmt.Expr)
+	return nil
+}
+
+func (c *Checker) VisitVariableDeclStmt(stmt *parser.VariableDeclarationStmt) error {
+	declared := c.fromAnnotation(stmt.Type)
+
+	if stmt.Initializer == nil {
+		if stmt.Type != nil && !nilType.assignableTo(declared) {
+			c.report(stmt.Name.Line, "'%s' is declared as %s but starts out nil, give it an initializer or make it %s?.", stmt.Name.Lexeme, declared, declared)
+		}
+	} else if value := c.infer(stmt.Initializer); !value.assignableTo(declared) {
+		c.report(stmt.Name.Line, "cannot initialize '%s' of type %s with a %s.", stmt.Name.Lexeme, declared, value)
+	}
+
+	c.define(stmt.Name.Lexeme, &binding{Type: declared})
+	return nil
+}
+
+func (c *Checker) VisitBlockStmt(stmt *parser.BlockStmt) error {
+	c.beginScope()
+	defer c.endScope()
+
+	c.checkStmts(stmt.Stmts)
+	return nil
+}
+
+func (c *Checker) VisitIfStmt(stmt *parser.IfStmt) error {
+	c.infer(stmt.Condition)
+	c.checkStmts([]parser.Stmt{stmt.ThenBranch, stmt.ElseBranch})
+	return nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt *parser.WhileStmt) error {
+	c.infer(stmt.Condition)
+	c.checkStmts([]parser.Stmt{stmt.Body})
+	return nil
+}
+
+func (c *Checker) VisitFunctionStmt(stmt *parser.FunctionStmt) error {
+	sig := &signature{
+		Name:      stmt.Name.Lexeme,
+		Arity:     len(stmt.Params),
+		Return:    c.fromAnnotation(stmt.ReturnType),
+		Generator: stmt.Generator,
+	}
+	for i := range stmt.Params {
+		var annotation *parser.TypeAnnotation
+		if i < len(stmt.ParamTypes) {
+			annotation = stmt.ParamTypes[i]
+		}
+		sig.Params = append(sig.Params, c.fromAnnotation(annotation))
+	}
+
+	// defined before the body is checked so recursive calls are checked too
+	c.define(stmt.Name.Lexeme, &binding{Type: Type{Kind: Function}, Signature: sig})
+
+	enclosing := c.function
+	c.function = sig
+	c.beginScope()
+	defer func() {
+		c.endScope()
+		c.function = enclosing
+	}()
+
+	for i, param := range stmt.Params {
+		c.define(param.Lexeme, &binding{Type: sig.Params[i]})
+	}
+	c.checkStmts(stmt.Body)
+
+	return nil
+}
+
+func (c *Checker) VisitReturnStmt(stmt *parser.ReturnStmt) error {
+	value := nilType
+	if stmt.Value != nil {
+		value = c.infer(stmt.Value)
+	}
+
+	if c.function != nil && !value.assignableTo(c.function.Return) {
+		c.report(stmt.Keyword.Line, "'%s' returns %s but this returns a %s.", c.function.Name, c.function.Return, value)
+	}
+	return nil
+}
+
+func (c *Checker) VisitYieldStmt(stmt *parser.YieldStmt) error {
+	value := nilType
+	if stmt.Value != nil {
+		value = c.infer(stmt.Value)
+	}
+
+	if c.function != nil && !value.assignableTo(c.function.Return) {
+		c.report(stmt.Keyword.Line, "'%s' yields %s but this yields a %s.", c.function.Name, c.function.Return, value)
+	}
+	return nil
+}
+
+func (c *Checker) VisitTestStmt(stmt *parser.TestStmt) error {
+	c.beginScope()
+	c.checkStmts(stmt.Body)
+	c.endScope()
+	return nil
+}
+
+// whatever part of a bad statement parsed is still checked, so the names it declares are known
+func (c *Checker) VisitBadStmt(stmt *parser.BadStmt) error {
+	c.checkStmts([]parser.Stmt{stmt.Partial})
+	return nil
+}
+
+func (c *Checker) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	c.infer(stmt.Subject)
+
+	for _, matchCase := range stmt.Cases {
+		c.beginScope()
+		for _, pattern := range matchCase.Patterns {
+			if pattern.Kind == parser.BindingPattern {
+				c.define(pattern.Token.Lexeme, &binding{Type: anyType})
+			}
+		}
+		c.infer(matchCase.Guard)
+		c.checkStmts([]parser.Stmt{matchCase.Body})
+		c.endScope()
+	}
+	return nil
+}
+
+// ExprVisitor implementation below ----------------------------------------------------------------
+func (c *Checker) VisitLiteralExpr(expr *parser.LiteralExpr) (any, error) {
+	if expr.IsNil {
+		return nilType, nil
+	}
+
+	switch expr.Value.(type) {
+	case float64:
+		return numberType, nil
+	case string:
+		return stringType, nil
+	case bool:
+		return boolType, nil
+	case nil:
+		return nilType, nil
+	default:
+		return anyType, nil
+	}
+}
+
+func (c *Checker) VisitGroupingExpr(expr *parser.GroupingExpr) (any, error) {
+	return c.infer(expr.Expr), nil
+}
+
+func (c *Checker) VisitVariableExpr(expr *parser.VariableExpr) (any, error) {
+	if b := c.lookup(expr.Name.Lexeme); b != nil {
+		return b.Type, nil
+	}
+	return anyType, nil
+}
+
+func (c *Checker) VisitUnaryExpr(expr *parser.UnaryExpr) (any, error) {
+	operand := c.infer(expr.Expr)
+
+	if expr.Operator.TokenType == lexer.BANG {
+		return boolType, nil
+	}
+
+	c.requireNumber(lexer.Token(expr.Operator), operand)
+	return numberType, nil
+}
+
+func (c *Checker) VisitBinaryExpr(expr *parser.BinaryExpr) (any, error) {
+	left, right := c.infer(expr.LeftExpr), c.infer(expr.RightExpr)
+	return c.binary(lexer.Token(expr.Operator), expr.Operator.TokenType, left, right), nil
+}
+
+// binary infers the result of applying op, compound assignments check through here as well
+func (c *Checker) binary(operator lexer.Token, op lexer.TokenType, left, right Type) Type {
+	switch op {
+	case lexer.COMMA:
+		return right
+	case lexer.EQUAL_EQUAL, lexer.BANG_EQUAL:
+		return boolType
+	case lexer.GREATER, lexer.GREATER_EQUAL, lexer.LESS, lexer.LESS_EQUAL:
+		c.requireNumber(operator, left)
+		c.requireNumber(operator, right)
+		return boolType
+	case lexer.PLUS:
+		return c.plus(operator, left, right)
+	default:
+		c.requireNumber(operator, left)
+		c.requireNumber(operator, right)
+		return numberType
+	}
+}
+
+// plus adds two numbers or concatenates two strings, the known side decides which when the other is Any
+func (c *Checker) plus(operator lexer.Token, left, right Type) Type {
+	if left.mayBeNil() || right.mayBeNil() {
+		c.report(operator.Line, "nil used as an operand of '+', got %s and %s.", left, right)
+		return anyType
+	}
+
+	switch {
+	case left.Kind == Any && right.Kind == Any:
+		return anyType
+	case left.Kind == Any:
+		left = right
+	case right.Kind == Any:
+		right = left
+	}
+
+	if left.Kind != right.Kind || (left.Kind != Number && left.Kind != String) {
+		c.report(operator.Line, "operands of '+' must be two numbers or two strings, got %s and %s.", left, right)
+		return anyType
+	}
+	return left
+}
+
+// checkAssign checks a value being stored into an existing variable
+func (c *Checker) checkAssign(name lexer.Token, value Type) {
+	b := c.lookup(name.Lexeme)
+	if b == nil {
+		return
+	}
+
+	if !value.assignableTo(b.Type) {
+		c.report(name.Line, "cannot assign a %s to '%s' of type %s.", value, name.Lexeme, b.Type)
+	}
+	// whatever the name held, it is no longer the function that was declared with it
+	b.Signature = nil
+}
+
+func (c *Checker) VisitAssignExpr(expr *parser.AssignExpr) (any, error) {
+	value := c.infer(expr.Value)
+	c.checkAssign(expr.Name, value)
+	return value, nil
+}
+
+var compoundOperators = map[lexer.TokenType]lexer.TokenType{
+	lexer.PLUS_EQUAL:  lexer.PLUS,
+	lexer.MINUS_EQUAL: lexer.MINUS,
+	lexer.STAR_EQUAL:  lexer.STAR,
+	lexer.SLASH_EQUAL: lexer.SLASH,
+}
+
+func (c *Checker) VisitCompoundAssignExpr(expr *parser.CompoundAssignExpr) (any, error) {
+	current := anyType
+	if b := c.lookup(expr.Name.Lexeme); b != nil {
+		current = b.Type
+	}
+
+	result := c.binary(lexer.Token(expr.Operator), compoundOperators[expr.Operator.TokenType], current, c.infer(expr.Value))
+	c.checkAssign(expr.Name, result)
+	return result, nil
+}
+
+func (c *Checker) VisitIncrementExpr(expr *parser.IncrementExpr) (any, error) {
+	if b := c.lookup(expr.Name.Lexeme); b != nil {
+		c.requireNumber(lexer.Token(expr.Operator), b.Type)
+	}
+	return numberType, nil
+}
+
+func (c *Checker) VisitLogicalExpr(expr *parser.LogicalExpr) (any, error) {
+	left, right := c.infer(expr.Left), c.infer(expr.Right)
+
+	if expr.Operator.TokenType == lexer.QUESTION_QUESTION {
+		if left.Kind == Nil {
+			return right, nil
+		}
+		// the left side is only used when it isn't nil
+		left.Nullable = false
+	}
+	return join(left, right), nil
+}
+
+func (c *Checker) VisitConditionalExpr(expr *parser.ConditionalExpr) (any, error) {
+	c.infer(expr.Condition)
+	return join(c.infer(expr.ThenBranch), c.infer(expr.ElseBranch)), nil
+}
+
+func (c *Checker) VisitCallExpr(expr *parser.CallExpr) (any, error) {
+	callee := c.infer(expr.Callee)
+
+	args := make([]Type, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		args[i] = c.infer(arg)
+	}
+
+	if !expr.Optional && callee.mayBeNil() {
+		c.report(expr.Paren.Line, "nil is not callable, use ?.( to call something that may be nil.")
+		return anyType, nil
+	}
+	if callee.Kind != Any && callee.Kind != Function && callee.Kind != Nil {
+		c.report(expr.Paren.Line, "can only call functions, got %s.", callee)
+		return anyType, nil
+	}
+
+	variable, ok := expr.Callee.(*parser.VariableExpr)
+	if !ok {
+		return anyType, nil
+	}
+	b := c.lookup(variable.Name.Lexeme)
+	if b == nil || b.Signature == nil {
+		return anyType, nil
+	}
+
+	sig := b.Signature
+	result := sig.Return
+	if sig.Generator {
+		result = anyType
+	}
+
+	if sig.Arity >= 0 && sig.Arity != len(args) {
+		c.report(expr.Paren.Line, "'%s' expects %d arguments but got %d.", sig.Name, sig.Arity, len(args))
+		return result, nil
+	}
+	for i, param := range sig.Params {
+		if !args[i].assignableTo(param) {
+			c.report(expr.Paren.Line, "argument %d to '%s' must be a %s, got %s.", i+1, sig.Name, param, args[i])
+		}
+	}
+	return result, nil
+}
+
+func (c *Checker) VisitGetExpr(expr *parser.GetExpr) (any, error) {
+	object := c.infer(expr.Object)
+	if !expr.Optional && object.Kind == Nil {
+		c.report(expr.Name.Line, "nil has no property '%s', use ?. to read from something that may be nil.", expr.Name.Lexeme)
+	}
+	return anyType, nil
+}
+
+func (c *Checker) VisitOptionalChainExpr(expr *parser.OptionalChainExpr) (any, error) {
+	return c.infer(expr.Expr), nil
+}
+
+// a spawned call is checked like any other, but evaluates to a task handle the checker has no kind for
+func (c *Checker) VisitSpawnExpr(expr *parser.SpawnExpr) (any, error) {
+	c.infer(expr.Call)
+	return anyType, nil
+}
+
+func (c *Checker) VisitBadExpr(expr *parser.BadExpr) (any, error) {
+	return anyType, nil
+}
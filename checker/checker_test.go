@@ -0,0 +1,79 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+type CheckerTestCase struct {
+	ID       int
+	Source   string
+	Expected []string
+}
+
+func check(source string) []string {
+	stmts := parser.NewParser(lexer.NewScanner(source).ScanTokens()).Parse()
+
+	messages := []string{}
+	for _, diagnostic := range NewChecker(map[string]int{"len": 1, "join": -1}).Check(stmts) {
+		messages = append(messages, diagnostic.String())
+	}
+	return messages
+}
+
+var checkerCases = []CheckerTestCase{
+	// unannotated code is never flagged for what it might hold
+	{ID: 1, Source: `var a = "x"; a = 1; print a - 1; fun f(x) { return x + 1; } print f("s");`, Expected: []string{}},
+	{ID: 2, Source: `print "a" - 1;`, Expected: []string{"[line 1] Type error: operand of '-' must be a number, got string."}},
+	{ID: 3, Source: "var n = 1;\nprint n + \"s\";\nprint \"s\" + \"t\";", Expected: []string{}},
+	{ID: 4, Source: "var n: number = 1;\nprint n + \"s\";", Expected: []string{"[line 2] Type error: operands of '+' must be two numbers or two strings, got number and string."}},
+	{ID: 5, Source: `print -true; print !1; print 1 < "2";`, Expected: []string{
+		"[line 1] Type error: operand of '-' must be a number, got bool.",
+		"[line 1] Type error: operand of '<' must be a number, got string.",
+	}},
+	// arity of declared and native functions
+	{ID: 6, Source: "fun add(a, b) { return a + b; }\nadd(1);\nlen(1, 2);\njoin(1, 2, 3);", Expected: []string{
+		"[line 2] Type error: 'add' expects 2 arguments but got 1.",
+		"[line 3] Type error: 'len' expects 1 arguments but got 2.",
+	}},
+	{ID: 7, Source: `fun add(a: number, b: number): number { return a + b; } var s: string = add(1, "2");`, Expected: []string{
+		"[line 1] Type error: argument 2 to 'add' must be a number, got string.",
+		"[line 1] Type error: cannot initialize 's' of type string with a number.",
+	}},
+	{ID: 8, Source: `fun name(): string { return 1; } fun maybe(): string? { return; }`, Expected: []string{
+		"[line 1] Type error: 'name' returns string but this returns a number.",
+	}},
+	// misuse of nil
+	{ID: 9, Source: `print nil + 1; var n: number?; print n * 2; print (n ?? 0) * 2; var m: number;`, Expected: []string{
+		"[line 1] Type error: nil used as an operand of '+', got nil and number.",
+		"[line 1] Type error: operand of '*' may be nil, its type is number?.",
+		"[line 1] Type error: 'm' is declared as number but starts out nil, give it an initializer or make it number?.",
+	}},
+	{ID: 10, Source: `var n: number = 1; n = nil; n += "a"; var f: function? = nil; f(); f?.(); print nil.x; print nil?.x;`, Expected: []string{
+		"[line 1] Type error: cannot assign a nil to 'n' of type number.",
+		"[line 1] Type error: operands of '+' must be two numbers or two strings, got number and string.",
+		"[line 1] Type error: nil is not callable, use ?.( to call something that may be nil.",
+		"[line 1] Type error: nil has no property 'x', use ?. to read from something that may be nil.",
+	}},
+	// scopes: an annotated parameter shadows an outer declaration
+	{ID: 11, Source: `var x: string = "s"; fun f(x: number) { print x - 1; } { var x: number = 2; print x - 1; } print x - 1;`, Expected: []string{
+		"[line 1] Type error: operand of '-' must be a number, got string.",
+	}},
+	{ID: 12, Source: `var x: numbr = 1; var ok: bool = 1 > 2 ? true : false; print 1(2);`, Expected: []string{
+		"[line 1] Type error: unknown type 'numbr'.",
+		"[line 1] Type error: can only call functions, got number.",
+	}},
+	// a generator's annotation describes what it yields, calling it returns the generator
+	{ID: 13, Source: `fun* count(n: number): number { yield n; yield "s"; } var g: number = count(1);`, Expected: []string{
+		"[line 1] Type error: 'count' yields number but this yields a string.",
+	}},
+}
+
+func TestChecker(t *testing.T) {
+	for _, testCase := range checkerCases {
+		assert.Equal(t, testCase.Expected, check(testCase.Source), "test case %d failed", testCase.ID)
+	}
+}
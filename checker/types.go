@@ -0,0 +1,123 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+type Kind int
+
+const (
+	// Any is the type of everything the checker knows nothing about, it is compatible with every other type
+	Any Kind = iota
+	Number
+	String
+	Bool
+	Nil
+	Function
+	List
+	Map
+)
+
+var kindNames = map[string]Kind{
+	"any":      Any,
+	"number":   Number,
+	"string":   String,
+	"bool":     Bool,
+	"nil":      Nil,
+	"function": Function,
+	"list":     List,
+	"map":      Map,
+}
+
+func (k Kind) String() string {
+	for name, kind := range kindNames {
+		if kind == k {
+			return name
+		}
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Type is a Kind that may also be nil when Nullable, written `number?`
+type Type struct {
+	Kind     Kind
+	Nullable bool
+}
+
+var (
+	anyType    = Type{Kind: Any}
+	numberType = Type{Kind: Number}
+	stringType = Type{Kind: String}
+	boolType   = Type{Kind: Bool}
+	nilType    = Type{Kind: Nil}
+)
+
+func (t Type) String() string {
+	if t.Nullable {
+		return t.Kind.String() + "?"
+	}
+	return t.Kind.String()
+}
+
+// mayBeNil reports whether a value of this type is, or could be, nil. Any is given the benefit of the doubt
+func (t Type) mayBeNil() bool {
+	return t.Kind == Nil || t.Nullable
+}
+
+// assignableTo reports whether a value of type t can be stored somewhere declared as target
+func (t Type) assignableTo(target Type) bool {
+	switch {
+	case t.Kind == Any || target.Kind == Any:
+		return true
+	case t.Kind == Nil:
+		return target.Kind == Nil || target.Nullable
+	case t.Kind != target.Kind:
+		return false
+	default:
+		return !t.Nullable || target.Nullable
+	}
+}
+
+// join is the type of an expression that evaluates to either a or b
+func join(a, b Type) Type {
+	switch {
+	case a.Kind == Any || b.Kind == Any:
+		return anyType
+	case a.Kind == b.Kind:
+		return Type{Kind: a.Kind, Nullable: a.Nullable || b.Nullable}
+	case a.Kind == Nil:
+		return Type{Kind: b.Kind, Nullable: true}
+	case b.Kind == Nil:
+		return Type{Kind: a.Kind, Nullable: true}
+	default:
+		return anyType
+	}
+}
+
+// signature is what the checker knows about a function it can see the declaration of
+type signature struct {
+	Name   string
+	Arity  int    // -1 for variadic natives
+	Params []Type // empty when the parameter types are unknown
+	// Return is the type of what a generator yields, calling one always returns a generator
+	Return    Type
+	Generator bool
+}
+
+// fromAnnotation resolves a parsed annotation, unannotated values are Any
+func (c *Checker) fromAnnotation(annotation *parser.TypeAnnotation) Type {
+	if annotation == nil {
+		return anyType
+	}
+
+	kind, ok := kindNames[annotation.Name.Lexeme]
+	if !ok {
+		c.report(annotation.Name.Line, "unknown type '%s'.", annotation.Name.Lexeme)
+		return anyType
+	}
+
+	// `nil?` is just nil
+	return Type{Kind: kind, Nullable: annotation.Nullable && kind != Nil && kind != Any}
+}
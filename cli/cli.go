@@ -0,0 +1,574 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/brandonshearin/go-lox/lox"
+	"github.com/brandonshearin/go-lox/tester"
+)
+
+// exit codes follow BSD's sysexits.h, like the reference clox and jlox do
+const (
+	ExitOK = 0
+	// ExitUsage means the command line itself was wrong
+	ExitUsage = 64
+	// ExitDataErr means the source had syntax errors, check and lint found problems in it or tests failed
+	ExitDataErr = 65
+	// ExitNoInput means a source file couldn't be read
+	ExitNoInput = 66
+	// ExitSoftware means the program stopped on a runtime error
+	ExitSoftware = 70
+	// ExitIOErr means a file couldn't be written
+	ExitIOErr = 74
+)
+
+const usage = `usage: lox [flags] [command] [arguments]
+
+commands:
+  run [--profile out] [--coverage out] file [args...]
+                                  run a script, writing a pprof profile of it to out with --profile
+                                  and which of its statements and branches ran with --coverage
+  repl                            start an interactive session
+  tokens file                     print the tokens the scanner produces
+  ast [--json] file               print the parse tree
+  check [--types] file            report syntax errors, and type errors with --types
+  lint [--disable rule,rule] file report suspicious code
+  fmt [-w] file                   print the file formatted, or rewrite it in place with -w
+  test [--run regex] [--timeout d] [--format f] [--coverage out] [path...]
+                                  run the test blocks in *_test.lox files, under . by default
+
+a file of - reads the source from stdin. "lox file" is short for "lox run file", "lox -e code [args...]"
+runs code given inline and "lox" on its own starts the repl. scripts see the arguments after their name
+in args, and exit with the code they pass to exit(). --allow-read and --allow-write may also follow run,
+repl and test.
+
+flags:
+`
+
+// CLI runs lox commands against the streams it is given, so every command can be run in-process
+type CLI struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Main runs the command line args, without the program name, on the process's own streams
+func Main(args []string) int {
+	c := &CLI{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	return c.Run(args)
+}
+
+// dirsFlag collects every occurrence of a repeatable flag like `--allow-read=dir`
+type dirsFlag []string
+
+// fsFlags adds the flags that grant scripts file system access to a command that runs code, each one
+// adding a root to policy
+func fsFlags(flags *flag.FlagSet, policy *interpreter.FSPolicy) {
+	flags.Var((*dirsFlag)(&policy.ReadRoots), "allow-read", "directory scripts may read from, can be repeated")
+	flags.Var((*dirsFlag)(&policy.WriteRoots), "allow-write", "directory scripts may read from and write to, can be repeated")
+}
+
+func (d *dirsFlag) String() string { return strings.Join(*d, ",") }
+func (d *dirsFlag) Set(dir string) error {
+	*d = append(*d, dir)
+	return nil
+}
+
+// Run runs one command line and returns the process exit code
+func (c *CLI) Run(args []string) int {
+	flags := flag.NewFlagSet("lox", flag.ContinueOnError)
+	flags.SetOutput(c.Stderr)
+	flags.Usage = func() {
+		fmt.Fprint(c.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	var policy interpreter.FSPolicy
+	fsFlags(flags, &policy)
+	noOpt := flags.Bool("no-opt", false, "run the program exactly as parsed, skipping the AST optimizer")
+	noTCO := flags.Bool("no-tco", false, "disable tail-call optimization so every call keeps its frame")
+	inline := flags.String("e", "", "run `code` instead of a file")
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+
+	l := lox.NewLox()
+	l.Stdout, l.Stderr = c.Stdout, c.Stderr
+	l.Optimize = !*noOpt
+	l.Interpreter.DisableTailCalls = *noTCO
+	l.Interpreter.FS = policy
+
+	args = flags.Args()
+	if *inline != "" {
+		l.Interpreter.SetArgs(args)
+		return c.runSource(l, *inline)
+	}
+	if len(args) == 0 {
+		return c.repl(l, args)
+	}
+
+	switch args[0] {
+	case "run":
+		return c.run(l, args[1:])
+	case "repl":
+		return c.repl(l, args[1:])
+	case "tokens":
+		return c.tokens(l, args[1:])
+	case "ast":
+		return c.ast(l, args[1:])
+	case "check":
+		return c.check(l, args[1:])
+	case "lint":
+		return c.lint(l, args[1:])
+	case "fmt":
+		return c.fmt(l, args[1:])
+	case "test":
+		return c.test(l, args[1:])
+	case "help":
+		flags.Usage()
+		return ExitOK
+	default:
+		return c.run(l, args)
+	}
+}
+
+// parseFlags parses args into flags, reporting whether the command is already over and with what code
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK, true
+	} else if err != nil {
+		return ExitUsage, true
+	}
+	return ExitOK, false
+}
+
+// subcommand creates the flag set for a command, printing its usage line and flags on --help
+func (c *CLI) subcommand(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(c.Stderr, "usage: "+usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// usageError reports a command line the flags parsed but the command can't use
+func (c *CLI) usageError(flags *flag.FlagSet) int {
+	flags.Usage()
+	return ExitUsage
+}
+
+// read returns the source named by a command's file argument, with - meaning stdin
+func (c *CLI) read(name string) (string, int) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(c.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+
+	if err != nil {
+		fmt.Fprintf(c.Stderr, "there was an error reading %s: %s\n", name, err.Error())
+		return "", ExitNoInput
+	}
+	return string(data), ExitOK
+}
+
+// profileTop is how many functions and lines `lox run --profile` summarizes
+const profileTop = 10
+
+// run implements `lox run [--profile out] [--coverage out] file [args...]`
+func (c *CLI) run(l *lox.Lox, args []string) int {
+	flags := c.subcommand("run", "lox run [--profile out] [--coverage out [--coverage-format f]] file [args...]")
+	fsFlags(flags, &l.Interpreter.FS)
+	profile := flags.String("profile", "", "write a pprof profile of the run to `file` and summarize it on stderr")
+	cover, coverFormat := coverageFlags(flags)
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() < 1 {
+		return c.usageError(flags)
+	}
+	if !c.validCoverageFormat(*coverFormat) {
+		return ExitUsage
+	}
+
+	source, code := c.read(flags.Arg(0))
+	if code != ExitOK {
+		return code
+	}
+	l.Interpreter.SetArgs(flags.Args()[1:])
+	l.File = flags.Arg(0)
+
+	// Ctrl-C cancels the run, which also ends a script blocked forever on a channel or task
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	l.Interpreter.Context = ctx
+
+	var profiler *interpreter.Profiler
+	if *profile != "" {
+		profiler = interpreter.NewProfiler(flags.Arg(0))
+		l.Interpreter.Profiler = profiler
+	}
+	var coverage *interpreter.Coverage
+	if *cover != "" {
+		coverage = interpreter.NewCoverage()
+		l.Interpreter.Coverage = coverage
+	}
+
+	code = c.runSource(l, source)
+	l.Interpreter.Profiler, l.Interpreter.Coverage = nil, nil
+	// a source that doesn't parse never ran, so there's nothing to report
+	if l.HadError() {
+		return code
+	}
+
+	// a program that failed still ran up to the failure, which is worth seeing
+	if profiler != nil {
+		if err := c.writeProfile(profiler, *profile); err != nil {
+			fmt.Fprintf(c.Stderr, "there was an error writing %s: %s\n", *profile, err.Error())
+			return ExitIOErr
+		}
+		profiler.WriteSummary(c.Stderr, profileTop)
+	}
+	if coverage != nil {
+		if err := c.writeCoverage(coverage, *cover, *coverFormat); err != nil {
+			fmt.Fprintf(c.Stderr, "there was an error writing %s: %s\n", *cover, err.Error())
+			return ExitIOErr
+		}
+	}
+	return code
+}
+
+// coverageFlags adds the flags that turn on coverage to a command
+func coverageFlags(flags *flag.FlagSet) (out, format *string) {
+	out = flags.String("coverage", "", "write a report of the statements and branches that ran to `file`")
+	format = flags.String("coverage-format", "lcov", "write the coverage report as "+strings.Join(interpreter.CoverageFormats, ", "))
+	return out, format
+}
+
+func (c *CLI) validCoverageFormat(format string) bool {
+	if slices.Contains(interpreter.CoverageFormats, format) {
+		return true
+	}
+	fmt.Fprintf(c.Stderr, "unknown coverage format '%s', expected one of %s\n", format, strings.Join(interpreter.CoverageFormats, ", "))
+	return false
+}
+
+// writeCoverage writes the coverage report to name, and how much of each file ran to stderr
+func (c *CLI) writeCoverage(coverage *interpreter.Coverage, name, format string) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := coverage.Report(out, format); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	for _, file := range coverage.Files() {
+		fmt.Fprintf(c.Stderr, "coverage: %s: %s\n", file.Path, file.Summary())
+	}
+	return nil
+}
+
+func (c *CLI) writeProfile(profiler *interpreter.Profiler, name string) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := profiler.WritePprof(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (c *CLI) runSource(l *lox.Lox, source string) int {
+	l.RunSource(source)
+
+	if code, ok := l.ExitCode(); ok {
+		return code
+	}
+	if l.HadError() {
+		return ExitDataErr
+	}
+	if l.HadRuntimeError() {
+		return ExitSoftware
+	}
+	return ExitOK
+}
+
+// repl implements `lox repl`
+func (c *CLI) repl(l *lox.Lox, args []string) int {
+	flags := c.subcommand("repl", "lox repl")
+	fsFlags(flags, &l.Interpreter.FS)
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() != 0 {
+		return c.usageError(flags)
+	}
+
+	l.RunPrompt(c.Stdin)
+	if code, ok := l.ExitCode(); ok {
+		return code
+	}
+	return ExitOK
+}
+
+// tokens implements `lox tokens file`, printing one token per line
+func (c *CLI) tokens(l *lox.Lox, args []string) int {
+	flags := c.subcommand("tokens", "lox tokens file")
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() != 1 {
+		return c.usageError(flags)
+	}
+
+	source, code := c.read(flags.Arg(0))
+	if code != ExitOK {
+		return code
+	}
+
+	tokens, problems := l.Tokens(source)
+	for _, token := range tokens {
+		fmt.Fprintf(c.Stdout, "%-4d %-18s %s\n", token.Line, token.TokenType, token.Lexeme)
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(c.Stderr, problem)
+	}
+	if len(problems) > 0 {
+		return ExitDataErr
+	}
+	return ExitOK
+}
+
+// ast implements `lox ast [--json] file`, printing the parse tree for people or, as JSON, for other tools
+func (c *CLI) ast(l *lox.Lox, args []string) int {
+	flags := c.subcommand("ast", "lox ast [--json] file")
+	asJSON := flags.Bool("json", false, "print the tree in the versioned JSON schema instead")
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() != 1 {
+		return c.usageError(flags)
+	}
+
+	source, code := c.read(flags.Arg(0))
+	if code != ExitOK {
+		return code
+	}
+
+	var tree string
+	var err error
+	if *asJSON {
+		var data []byte
+		data, err = l.AST(source)
+		tree = string(data) + "\n"
+	} else {
+		tree, err = l.Tree(source)
+	}
+
+	if err != nil {
+		fmt.Fprintln(c.Stderr, err.Error())
+		return ExitDataErr
+	}
+	fmt.Fprint(c.Stdout, tree)
+	return ExitOK
+}
+
+// check implements `lox check [--types] file`, exiting non-zero when the file has problems
+func (c *CLI) check(l *lox.Lox, args []string) int {
+	flags := c.subcommand("check", "lox check [--types] file")
+	types := flags.Bool("types", false, "also run the gradual type checker")
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() != 1 {
+		return c.usageError(flags)
+	}
+
+	source, code := c.read(flags.Arg(0))
+	if code != ExitOK {
+		return code
+	}
+
+	problems := l.Check(source, *types)
+	for _, problem := range problems {
+		fmt.Fprintln(c.Stdout, problem)
+	}
+	if len(problems) > 0 {
+		return ExitDataErr
+	}
+	return ExitOK
+}
+
+// lint implements `lox lint [--disable rule,rule] file`, exiting non-zero when anything is reported
+func (c *CLI) lint(l *lox.Lox, args []string) int {
+	flags := c.subcommand("lint", "lox lint [--disable rule,rule] file")
+	disable := flags.String("disable", "", "comma separated lint rules to skip")
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() != 1 {
+		return c.usageError(flags)
+	}
+
+	source, code := c.read(flags.Arg(0))
+	if code != ExitOK {
+		return code
+	}
+
+	var disabled []string
+	if *disable != "" {
+		disabled = strings.Split(*disable, ",")
+	}
+
+	problems, err := l.Lint(source, disabled)
+	if err != nil {
+		fmt.Fprintln(c.Stderr, err.Error())
+		return ExitUsage
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(c.Stdout, problem)
+	}
+	if len(problems) > 0 {
+		return ExitDataErr
+	}
+	return ExitOK
+}
+
+// fmt implements `lox fmt [-w] file`
+func (c *CLI) fmt(l *lox.Lox, args []string) int {
+	flags := c.subcommand("fmt", "lox fmt [-w] file")
+	write := flags.Bool("w", false, "write the result back to the file instead of printing it")
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+	if flags.NArg() != 1 || (*write && flags.Arg(0) == "-") {
+		return c.usageError(flags)
+	}
+
+	source, code := c.read(flags.Arg(0))
+	if code != ExitOK {
+		return code
+	}
+
+	formatted, err := l.Format(source)
+	if err != nil {
+		fmt.Fprintln(c.Stderr, err.Error())
+		return ExitDataErr
+	}
+
+	if !*write {
+		fmt.Fprint(c.Stdout, formatted)
+		return ExitOK
+	}
+	if formatted == source {
+		return ExitOK
+	}
+	if err := os.WriteFile(flags.Arg(0), []byte(formatted), 0644); err != nil {
+		fmt.Fprintf(c.Stderr, "there was an error writing %s: %s\n", flags.Arg(0), err.Error())
+		return ExitIOErr
+	}
+	return ExitOK
+}
+
+// testTimeout is how long `lox test` lets a single test run by default
+const testTimeout = time.Minute
+
+// test implements `lox test [--run regex] [--timeout d] [--format text|tap|junit] [--coverage out] [path...]`,
+// exiting non-zero when any test fails or any test file doesn't parse. coverage is collected across
+// every test file
+func (c *CLI) test(l *lox.Lox, args []string) int {
+	flags := c.subcommand("test", "lox test [--run regex] [--timeout d] [--format text|tap|junit] [--coverage out [--coverage-format f]] [path...]")
+	fsFlags(flags, &l.Interpreter.FS)
+	run := flags.String("run", "", "only run the tests whose name matches `regex`")
+	timeout := flags.Duration("timeout", testTimeout, "fail a test that runs longer than `d`, 0 for no limit")
+	format := flags.String("format", "text", "report as "+strings.Join(tester.Formats, ", "))
+	cover, coverFormat := coverageFlags(flags)
+	if code, done := parseFlags(flags, args); done {
+		return code
+	}
+
+	if !slices.Contains(tester.Formats, *format) {
+		fmt.Fprintf(c.Stderr, "unknown report format '%s', expected one of %s\n", *format, strings.Join(tester.Formats, ", "))
+		return ExitUsage
+	}
+	if !c.validCoverageFormat(*coverFormat) {
+		return ExitUsage
+	}
+
+	// Ctrl-C fails the running test and every one after it, which still get reported
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	runner := &tester.Runner{FS: l.Interpreter.FS, Context: ctx, Timeout: *timeout}
+	if *cover != "" {
+		runner.Coverage = interpreter.NewCoverage()
+	}
+	if *run != "" {
+		filter, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(c.Stderr, "invalid --run pattern: %s\n", err.Error())
+			return ExitUsage
+		}
+		runner.Filter = filter
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := tester.Discover(paths...)
+	if err != nil {
+		fmt.Fprintln(c.Stderr, err.Error())
+		return ExitNoInput
+	}
+
+	code := ExitOK
+	results := []tester.Result{}
+	for _, file := range files {
+		fileResults, err := runner.RunFile(file)
+		if err != nil {
+			fmt.Fprintln(c.Stderr, err.Error())
+			code = ExitDataErr
+			continue
+		}
+		results = append(results, fileResults...)
+	}
+
+	if err := tester.Report(c.Stdout, *format, results); err != nil {
+		fmt.Fprintln(c.Stderr, err.Error())
+		return ExitUsage
+	}
+	if runner.Coverage != nil {
+		if err := c.writeCoverage(runner.Coverage, *cover, *coverFormat); err != nil {
+			fmt.Fprintf(c.Stderr, "there was an error writing %s: %s\n", *cover, err.Error())
+			return ExitIOErr
+		}
+	}
+	if tester.Failed(results) > 0 {
+		code = ExitDataErr
+	}
+	return code
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type CLITestCase struct {
+	ID     int
+	Args   []string
+	Stdin  string
+	Stdout string
+	// Partial only requires Stdout to be contained in stdout, for output too long to spell out
+	Partial bool
+	Stderr  string // only has to be contained in stderr
+	Code    int
+}
+
+func runCLI(args []string, stdin string) (string, string, int) {
+	var stdout, stderr bytes.Buffer
+	c := &CLI{Stdin: strings.NewReader(stdin), Stdout: &stdout, Stderr: &stderr}
+	code := c.Run(args)
+	return stdout.String(), stderr.String(), code
+}
+
+func writeScripts(t *testing.T) string {
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"hello.lox":     "print \"hello\";",
+		"syntax.lox":    "print ;",
+		"runtime.lox":   "print 1;\nprint -\"a\";",
+		"messy.lox":     "var  a=1;print a ;",
+		"lint.lox":      "fun f() { var unused = 1; }",
+		"types.lox":     "var a: number = \"s\";",
+		"args.lox":      "#!/usr/bin/env lox\nprint args;\nexit(args.length);",
+		"math_test.lox": "test \"adds\" { assertEqual(1 + 2, 3); }\ntest \"fails\" { assert(false); }",
+		"hang.lox":      "test \"hangs\" { while (true) {} }",
+		"read.lox":      "print readFile(args.get(0));",
+		"reads.lox":     "test \"reads\" { assert(exists(\"cli.go\")); }",
+	}
+	for name, source := range scripts {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(source), 0644))
+	}
+	return dir
+}
+
+func TestCLI(t *testing.T) {
+	dir := writeScripts(t)
+	script := func(name string) string { return filepath.Join(dir, name) }
+
+	cases := []CLITestCase{
+		{ID: 1, Args: []string{"run", script("hello.lox")}, Stdout: "hello\n", Code: ExitOK},
+		{ID: 2, Args: []string{script("hello.lox")}, Stdout: "hello\n", Code: ExitOK},
+		{ID: 3, Args: []string{"run", script("hello.lox"), "extra", "args"}, Stdout: "hello\n", Code: ExitOK},
+		{ID: 4, Args: []string{"-e", "print 1 + 2;"}, Stdout: "3\n", Code: ExitOK},
+		{ID: 5, Args: []string{"run", "-"}, Stdin: "print \"piped\";", Stdout: "piped\n", Code: ExitOK},
+		{ID: 6, Args: []string{"run", script("syntax.lox")}, Stderr: "[line 1] Error at ;: Expect expression.", Code: ExitDataErr},
+		{ID: 7, Args: []string{"run", script("runtime.lox")}, Stdout: "1\n", Stderr: "operand must be a number.\n[line 2]\n", Code: ExitSoftware},
+		{ID: 8, Args: []string{"run", script("missing.lox")}, Stderr: "there was an error reading", Code: ExitNoInput},
+		{ID: 9, Args: []string{"run"}, Stderr: "usage: lox run [--profile out] [--coverage out [--coverage-format f]] file [args...]", Code: ExitUsage},
+		{ID: 10, Args: []string{"--bogus"}, Stderr: "flag provided but not defined: -bogus", Code: ExitUsage},
+		{ID: 11, Args: []string{"--help"}, Stderr: "commands:", Code: ExitOK},
+		{ID: 12, Args: []string{"help"}, Stderr: "check [--types] file", Code: ExitOK},
+		{ID: 13, Args: []string{"fmt", "--help"}, Stderr: "usage: lox fmt [-w] file", Code: ExitOK},
+		{ID: 14, Args: []string{"tokens", "-"}, Stdin: "var a;", Stdout: "1    VAR                var\n1    IDENTIFIER         a\n1    SEMICOLON          ;\n1    EOF                \n", Code: ExitOK},
+		{ID: 15, Args: []string{"tokens", "-"}, Stdin: "@", Stdout: "1    EOF                \n", Stderr: "[line 1] Error unexpected character @", Code: ExitDataErr},
+		{ID: 16, Args: []string{"ast", "-"}, Stdin: "print 1 + a;", Stdout: "(print (+ 1.00 a))\n", Code: ExitOK},
+		{ID: 17, Args: []string{"ast", "--json", "-"}, Stdin: "a;", Stdout: `"kind": "expression"`, Partial: true, Code: ExitOK},
+		{ID: 18, Args: []string{"ast", script("syntax.lox")}, Stderr: "Expect expression.", Code: ExitDataErr},
+		{ID: 19, Args: []string{"check", script("hello.lox")}, Code: ExitOK},
+		{ID: 20, Args: []string{"check", script("syntax.lox")}, Stdout: "[line 1] Error at ;: Expect expression.\n", Code: ExitDataErr},
+		{ID: 21, Args: []string{"check", "--types", script("types.lox")}, Stdout: "Type error", Partial: true, Code: ExitDataErr},
+		{ID: 22, Args: []string{"lint", script("lint.lox")}, Stdout: "unused-variable", Partial: true, Code: ExitDataErr},
+		{ID: 23, Args: []string{"lint", "--disable", "nope", script("lint.lox")}, Stderr: "unknown lint rule 'nope'", Code: ExitUsage},
+		{ID: 24, Args: []string{"fmt", script("messy.lox")}, Stdout: "var a = 1;\nprint a;\n", Code: ExitOK},
+		{ID: 25, Args: []string{"repl"}, Stdin: "var a = 2;\nprint a * 3;\nprint -\"x\";\nprint a;\n", Stdout: "> > 6\n> > 2\n> \n", Stderr: "operand must be a number.", Code: ExitOK},
+		{ID: 26, Args: []string{"--no-opt", "-e", "print 2 * 3;"}, Stdout: "6\n", Code: ExitOK},
+		{ID: 27, Args: []string{"run", script("args.lox"), "a", "--b"}, Stdout: "[\"a\", \"--b\"]\n", Code: 2},
+		{ID: 28, Args: []string{script("args.lox")}, Stdout: "[]\n", Code: ExitOK},
+		{ID: 29, Args: []string{"-e", "print args; exit(9);", "x"}, Stdout: "[\"x\"]\n", Code: 9},
+		{ID: 30, Args: []string{"-e", "fun f() { print 1; } setTimeout(f, 0); exit(0);"}, Code: ExitOK},
+		{ID: 31, Args: []string{"repl"}, Stdin: "print 1;\nexit(4);\nprint 2;\n", Stdout: "> 1\n> ", Code: 4},
+		{ID: 32, Args: []string{"test", "--run", "adds", dir}, Stdout: "PASS  " + script("math_test.lox") + ":1  adds", Partial: true, Code: ExitOK},
+		{ID: 33, Args: []string{"test", dir}, Stdout: "2 tests, 1 passed, 1 failed", Partial: true, Code: ExitDataErr},
+		{ID: 34, Args: []string{"test", "--format", "tap", script("math_test.lox")}, Stdout: "not ok 2 - " + script("math_test.lox") + ": fails\n", Partial: true, Code: ExitDataErr},
+		{ID: 35, Args: []string{"test", "--format", "junit", "--run", "adds", dir}, Stdout: `<testsuites tests="1" failures="0"`, Partial: true, Code: ExitOK},
+		{ID: 36, Args: []string{"test", "--format", "xml", dir}, Stderr: "unknown report format 'xml'", Code: ExitUsage},
+		{ID: 37, Args: []string{"test", "--run", "(", dir}, Stderr: "invalid --run pattern", Code: ExitUsage},
+		{ID: 38, Args: []string{"test", script("syntax.lox")}, Stdout: "0 tests", Partial: true, Stderr: "Expect expression.", Code: ExitDataErr},
+		{ID: 39, Args: []string{"run", "--profile", filepath.Join(dir, "missing", "out.pprof"), script("hello.lox")}, Stdout: "hello\n", Stderr: "there was an error writing", Code: ExitIOErr},
+		{ID: 40, Args: []string{"run", "--coverage", filepath.Join(dir, "out.info"), "--coverage-format", "xml", script("hello.lox")}, Stderr: "unknown coverage format 'xml'", Code: ExitUsage},
+		{ID: 41, Args: []string{"test", "--coverage-format", "xml", dir}, Stderr: "unknown coverage format 'xml'", Code: ExitUsage},
+		{ID: 42, Args: []string{"run", "--coverage", filepath.Join(dir, "missing", "out.info"), script("hello.lox")}, Stdout: "hello\n", Stderr: "there was an error writing", Code: ExitIOErr},
+		{ID: 43, Args: []string{"test", "--timeout", "20ms", script("hang.lox")}, Stdout: "execution cancelled: context deadline exceeded.", Partial: true, Code: ExitDataErr},
+		{ID: 44, Args: []string{"repl"}, Stdin: "fun f() { print \"later\"; } setTimeout(f, 0);\nprint 1;\n", Stdout: "> later\n> 1\n> \n", Code: ExitOK},
+		// file system access can be granted before the command or after it, wherever code runs
+		{ID: 45, Args: []string{"run", script("read.lox"), script("hello.lox")}, Stderr: "permission denied", Code: ExitSoftware},
+		{ID: 46, Args: []string{"--allow-read", dir, "run", script("read.lox"), script("hello.lox")}, Stdout: "print \"hello\";\n", Code: ExitOK},
+		{ID: 47, Args: []string{"run", "--allow-read", dir, script("read.lox"), script("hello.lox")}, Stdout: "print \"hello\";\n", Code: ExitOK},
+		{ID: 48, Args: []string{"run", "--allow-write=" + dir, script("read.lox"), script("hello.lox")}, Stdout: "print \"hello\";\n", Code: ExitOK},
+		{ID: 49, Args: []string{"-e", "print readFile(args.get(0));", "--allow-read", dir, script("hello.lox")}, Stdout: "print \"hello\";\n", Code: ExitOK},
+		{ID: 50, Args: []string{"repl", "--allow-read", dir}, Stdin: "print readFile(\"" + script("hello.lox") + "\");\n", Stdout: "> print \"hello\";\n> \n", Code: ExitOK},
+		{ID: 51, Args: []string{"test", script("reads.lox")}, Stdout: "1 failed", Partial: true, Code: ExitDataErr},
+		{ID: 52, Args: []string{"test", "--allow-read", ".", script("reads.lox")}, Stdout: "1 passed", Partial: true, Code: ExitOK},
+	}
+
+	for _, testCase := range cases {
+		stdout, stderr, code := runCLI(testCase.Args, testCase.Stdin)
+
+		assert.Equal(t, testCase.Code, code, "test case %d failed", testCase.ID)
+		if testCase.Partial {
+			assert.Contains(t, stdout, testCase.Stdout, "test case %d failed", testCase.ID)
+		} else {
+			assert.Equal(t, testCase.Stdout, stdout, "test case %d failed", testCase.ID)
+		}
+		if testCase.Stderr == "" {
+			assert.Empty(t, stderr, "test case %d failed", testCase.ID)
+		} else {
+			assert.Contains(t, stderr, testCase.Stderr, "test case %d failed", testCase.ID)
+		}
+	}
+}
+
+func TestFmtWrite(t *testing.T) {
+	dir := writeScripts(t)
+	path := filepath.Join(dir, "messy.lox")
+
+	stdout, stderr, code := runCLI([]string{"fmt", "-w", path}, "")
+	assert.Equal(t, ExitOK, code)
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr)
+
+	formatted, _ := os.ReadFile(path)
+	assert.Equal(t, "var a = 1;\nprint a;\n", string(formatted))
+
+	_, _, code = runCLI([]string{"fmt", "-w", "-"}, "print 1;")
+	assert.Equal(t, ExitUsage, code)
+}
+
+func TestRunProfile(t *testing.T) {
+	dir := writeScripts(t)
+	out := filepath.Join(dir, "out.pprof")
+
+	stdout, stderr, code := runCLI([]string{"run", "--profile", out, filepath.Join(dir, "runtime.lox")}, "")
+	assert.Equal(t, ExitSoftware, code)
+	assert.Equal(t, "1\n", stdout)
+	assert.Contains(t, stderr, "operand must be a number.")
+	assert.Contains(t, stderr, "profile of "+filepath.Join(dir, "runtime.lox"))
+	assert.Contains(t, stderr, "runtime.lox:1 in top-level")
+
+	profile, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, profile)
+
+	// a source that doesn't parse never ran, so there's nothing to write
+	_, stderr, code = runCLI([]string{"run", "--profile", out + ".2", filepath.Join(dir, "syntax.lox")}, "")
+	assert.Equal(t, ExitDataErr, code)
+	assert.NotContains(t, stderr, "profile of")
+	assert.NoFileExists(t, out+".2")
+}
+
+func TestCoverage(t *testing.T) {
+	dir := writeScripts(t)
+	out := filepath.Join(dir, "out.info")
+
+	stdout, stderr, code := runCLI([]string{"run", "--coverage", out, filepath.Join(dir, "runtime.lox")}, "")
+	assert.Equal(t, ExitSoftware, code)
+	assert.Equal(t, "1\n", stdout)
+	assert.Contains(t, stderr, "coverage: "+filepath.Join(dir, "runtime.lox")+": 100.0% of 2 lines")
+
+	lcov, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, "TN:\nSF:"+filepath.Join(dir, "runtime.lox")+"\nBRF:0\nBRH:0\nDA:1,1\nDA:2,1\nLF:2\nLH:2\nend_of_record\n", string(lcov))
+
+	// every test file that ran is in the report
+	_, stderr, code = runCLI([]string{"test", "--coverage", out, "--coverage-format", "text", dir}, "")
+	assert.Equal(t, ExitDataErr, code)
+	assert.Contains(t, stderr, "coverage: "+filepath.Join(dir, "math_test.lox")+": 100.0% of 2 lines")
+
+	text, err := os.ReadFile(out)
+	assert.Nil(t, err)
+	assert.Contains(t, string(text), "        -:    0:Source:"+filepath.Join(dir, "math_test.lox")+"\n")
+}
@@ -0,0 +1,465 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+const indent = "    "
+
+// Formatter prints a program back out as source in the one canonical layout: one statement per line,
+// four space indents, spaces around binary operators and braces on the line that opens them.
+// comments are kept, each before the statement that follows it or at the end of the line it was on.
+// implements parser.ExprVisitor and parser.StmtVisitor
+type Formatter struct {
+	lines    []string // of the original source, to keep the blank lines that separate statements
+	comments []lexer.Comment
+
+	builder strings.Builder
+	depth   int
+}
+
+// NewFormatter creates a formatter for a program parsed from source, whose scanner found comments
+func NewFormatter(source string, comments []lexer.Comment) *Formatter {
+	return &Formatter{lines: strings.Split(source, "\n"), comments: comments}
+}
+
+// Format returns the formatted program
+func (f *Formatter) Format(stmts []parser.Stmt) string {
+	f.builder.Reset()
+	f.depth = 0
+
+	// the scanner skips a shebang line, which has to stay first for the script to remain executable
+	if strings.HasPrefix(f.lines[0], "#!") {
+		f.builder.WriteString(strings.TrimRight(f.lines[0], " \t\r") + "\n")
+	}
+
+	f.block(stmts)
+	f.comment(-1)
+	return f.builder.String()
+}
+
+// block writes each statement on its own line at the current depth
+func (f *Formatter) block(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		f.stmt(stmt)
+	}
+}
+
+// stmt writes one statement along with the comments before it and on its line
+func (f *Formatter) stmt(stmt parser.Stmt) {
+	line := parser.StmtLine(stmt)
+	if line > 0 {
+		f.comment(line)
+		f.blankLine(line)
+	}
+
+	f.builder.WriteString(strings.Repeat(indent, f.depth))
+	start := f.builder.Len()
+	f.write(stmt)
+
+	// a comment after a statement that fits on one line stays next to it
+	if line > 0 && len(f.comments) > 0 && f.comments[0].Line == line && !strings.Contains(f.builder.String()[start:], "\n") {
+		f.builder.WriteString(" //" + f.comments[0].Text)
+		f.comments = f.comments[1:]
+	}
+	f.builder.WriteString("\n")
+}
+
+// comment writes every comment before line on lines of their own, or all of them when line is -1
+func (f *Formatter) comment(line int) {
+	for len(f.comments) > 0 && (line == -1 || f.comments[0].Line < line) {
+		comment := f.comments[0]
+		f.comments = f.comments[1:]
+
+		f.blankLine(comment.Line)
+		f.builder.WriteString(strings.Repeat(indent, f.depth) + "//" + comment.Text + "\n")
+	}
+}
+
+// blankLine keeps the empty line the source had right above line, except at the start of a block
+func (f *Formatter) blankLine(line int) {
+	if line < 2 || line-2 >= len(f.lines) || strings.TrimSpace(f.lines[line-2]) != "" {
+		return
+	}
+
+	out := f.builder.String()
+	if out != "" && !strings.HasSuffix(out, "{\n") && !strings.HasSuffix(out, "\n\n") {
+		f.builder.WriteString("\n")
+	}
+}
+
+func (f *Formatter) write(stmt parser.Stmt) {
+	if stmt == nil {
+		return
+	}
+	stmt.Accept(f)
+}
+
+// Expr returns the source of a single expression in the canonical layout
+func Expr(expr parser.Expr) string {
+	return (&Formatter{}).expr(expr)
+}
+
+func (f *Formatter) expr(expr parser.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	str, _ := expr.Accept(f)
+	return str.(string)
+}
+
+// body writes a block's braces around its statements, or a single statement after a space
+func (f *Formatter) body(stmt parser.Stmt) {
+	if block, ok := stmt.(*parser.BlockStmt); ok && block.Brace.Line != 0 {
+		f.braces(block.Stmts)
+		return
+	}
+	f.builder.WriteString(" ")
+	f.write(stmt)
+}
+
+func (f *Formatter) braces(stmts []parser.Stmt) {
+	if len(stmts) == 0 {
+		f.builder.WriteString(" {}")
+		return
+	}
+
+	f.builder.WriteString(" {\n")
+	f.depth++
+	f.block(stmts)
+	f.depth--
+	f.builder.WriteString(strings.Repeat(indent, f.depth) + "}")
+}
+
+func annotation(t *parser.TypeAnnotation) string {
+	if t == nil {
+		return ""
+	}
+	return ": " + t.String()
+}
+
+// StmtVisitor implementation below ----------------------------------------------------------------
+func (f *Formatter) VisitPrintStmt(stmt *parser.PrintStmt) error {
+	f.builder.WriteString("print " + f.expr(stmt.Expr) + ";")
+	return nil
+}
+
+func (f *Formatter) VisitExpressionStmt(stmt *parser.ExpressionStmt) error {
+	f.builder.WriteString(f.expr(stmt.Expr) + ";")
+	return nil
+}
+
+func (f *Formatter) VisitVariableDeclStmt(stmt *parser.VariableDeclarationStmt) error {
+	f.builder.WriteString(f.varDecl(stmt) + ";")
+	return nil
+}
+
+func (f *Formatter) varDecl(stmt *parser.VariableDeclarationStmt) string {
+	decl := "var " + stmt.Name.Lexeme + annotation(stmt.Type)
+	if stmt.Initializer != nil {
+		decl += " = " + f.expr(stmt.Initializer)
+	}
+	return decl
+}
+
+func (f *Formatter) VisitBlockStmt(stmt *parser.BlockStmt) error {
+	// the parser wraps a for loop with an initializer in a block of its own
+	if stmt.Brace.Line == 0 && len(stmt.Stmts) == 2 {
+		if loop, ok := stmt.Stmts[1].(*parser.WhileStmt); ok && loop.Keyword.TokenType == lexer.FOR {
+			f.forLoop(stmt.Stmts[0], loop)
+			return nil
+		}
+	}
+
+	f.builder.WriteString("{")
+	if len(stmt.Stmts) > 0 {
+		f.builder.WriteString("\n")
+		f.depth++
+		f.block(stmt.Stmts)
+		f.depth--
+		f.builder.WriteString(strings.Repeat(indent, f.depth))
+	}
+	f.builder.WriteString("}")
+	return nil
+}
+
+func (f *Formatter) VisitIfStmt(stmt *parser.IfStmt) error {
+	f.builder.WriteString("if (" + f.expr(stmt.Condition) + ")")
+	f.body(stmt.ThenBranch)
+	if stmt.ElseBranch == nil {
+		return nil
+	}
+
+	if block, ok := stmt.ThenBranch.(*parser.BlockStmt); ok && block.Brace.Line != 0 {
+		f.builder.WriteString(" else")
+	} else {
+		f.builder.WriteString("\n" + strings.Repeat(indent, f.depth) + "else")
+	}
+	f.body(stmt.ElseBranch)
+	return nil
+}
+
+func (f *Formatter) VisitWhileStmt(stmt *parser.WhileStmt) error {
+	if stmt.Keyword.TokenType == lexer.FOR {
+		f.forLoop(nil, stmt)
+		return nil
+	}
+
+	f.builder.WriteString("while (" + f.expr(stmt.Condition) + ")")
+	f.body(stmt.Body)
+	return nil
+}
+
+// forLoop puts back together the for loop the parser desugared into loop
+func (f *Formatter) forLoop(initializer parser.Stmt, loop *parser.WhileStmt) {
+	init := ""
+	switch initializer := initializer.(type) {
+	case *parser.VariableDeclarationStmt:
+		init = f.varDecl(initializer)
+	case *parser.ExpressionStmt:
+		init = f.expr(initializer.Expr)
+	}
+
+	condition := ""
+	if literal, ok := loop.Condition.(*parser.LiteralExpr); !ok || literal.Token.Line != 0 {
+		condition = " " + f.expr(loop.Condition)
+	}
+
+	body, increment := loop.Body, ""
+	if block, ok := body.(*parser.BlockStmt); ok && block.Brace.Line == 0 && len(block.Stmts) == 2 {
+		if stmt, ok := block.Stmts[1].(*parser.ExpressionStmt); ok {
+			body, increment = block.Stmts[0], " "+f.expr(stmt.Expr)
+		}
+	}
+
+	f.builder.WriteString("for (" + init + ";" + condition + ";" + increment + ")")
+	f.body(body)
+}
+
+func (f *Formatter) VisitFunctionStmt(stmt *parser.FunctionStmt) error {
+	fun := "fun"
+	// a yield already makes the function a generator, the star is only needed without one
+	if stmt.Generator && !yields(stmt.Body) {
+		fun = "fun*"
+	}
+
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.Lexeme
+		if i < len(stmt.ParamTypes) {
+			params[i] += annotation(stmt.ParamTypes[i])
+		}
+	}
+
+	f.builder.WriteString(fun + " " + stmt.Name.Lexeme + "(" + strings.Join(params, ", ") + ")" + annotation(stmt.ReturnType))
+	f.braces(stmt.Body)
+	return nil
+}
+
+// yields reports whether stmts contain a yield of their own, not counting nested functions
+func yields(stmts []parser.Stmt) bool {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *parser.YieldStmt:
+			return true
+		case *parser.BlockStmt:
+			if yields(s.Stmts) {
+				return true
+			}
+		case *parser.IfStmt:
+			if yields([]parser.Stmt{s.ThenBranch, s.ElseBranch}) {
+				return true
+			}
+		case *parser.WhileStmt:
+			if yields([]parser.Stmt{s.Body}) {
+				return true
+			}
+		case *parser.MatchStmt:
+			for _, matchCase := range s.Cases {
+				if yields([]parser.Stmt{matchCase.Body}) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (f *Formatter) VisitReturnStmt(stmt *parser.ReturnStmt) error {
+	if stmt.Value == nil {
+		f.builder.WriteString("return;")
+	} else {
+		f.builder.WriteString("return " + f.expr(stmt.Value) + ";")
+	}
+	return nil
+}
+
+func (f *Formatter) VisitYieldStmt(stmt *parser.YieldStmt) error {
+	if stmt.Value == nil {
+		f.builder.WriteString("yield;")
+	} else {
+		f.builder.WriteString("yield " + f.expr(stmt.Value) + ";")
+	}
+	return nil
+}
+
+func (f *Formatter) VisitTestStmt(stmt *parser.TestStmt) error {
+	f.builder.WriteString("test \"" + stmt.Name + "\"")
+	f.braces(stmt.Body)
+	return nil
+}
+
+// a statement with syntax errors has no canonical layout, so its source lines are kept as they were
+func (f *Formatter) VisitBadStmt(stmt *parser.BadStmt) error {
+	from, to := stmt.From.Line, stmt.To.Line
+	if from < 1 || to < from || to > len(f.lines) {
+		return nil
+	}
+
+	for i, line := range f.lines[from-1 : to] {
+		if i == 0 {
+			line = strings.TrimLeft(line, " \t")
+		} else {
+			f.builder.WriteString("\n")
+		}
+		f.builder.WriteString(strings.TrimRight(line, " \t\r"))
+	}
+	return nil
+}
+
+func (f *Formatter) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	f.builder.WriteString("match (" + f.expr(stmt.Subject) + ") {\n")
+
+	f.depth++
+	for _, matchCase := range stmt.Cases {
+		patterns := make([]string, len(matchCase.Patterns))
+		for i, pattern := range matchCase.Patterns {
+			switch {
+			case pattern.Kind != parser.LiteralPattern:
+				patterns[i] = pattern.Token.Lexeme
+			case pattern.Token.TokenType == lexer.MINUS:
+				patterns[i] = "-" + pattern.Literal.Token.Lexeme
+			default:
+				patterns[i] = f.expr(pattern.Literal)
+			}
+		}
+
+		f.comment(matchCase.Keyword.Line)
+		f.builder.WriteString(strings.Repeat(indent, f.depth) + "case " + strings.Join(patterns, ", "))
+		if matchCase.Guard != nil {
+			f.builder.WriteString(" if " + f.expr(matchCase.Guard))
+		}
+		f.builder.WriteString(" =>")
+		f.body(matchCase.Body)
+		f.builder.WriteString("\n")
+	}
+	f.depth--
+
+	f.builder.WriteString(strings.Repeat(indent, f.depth) + "}")
+	return nil
+}
+
+// ExprVisitor implementation below ----------------------------------------------------------------
+func (f *Formatter) VisitLiteralExpr(expr *parser.LiteralExpr) (any, error) {
+	// the token keeps the number as it was written, like `1.50`
+	if expr.Token.Lexeme != "" {
+		return expr.Token.Lexeme, nil
+	}
+
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case string:
+		return `"` + value + `"`, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	}
+	return "", nil
+}
+
+func (f *Formatter) VisitGroupingExpr(expr *parser.GroupingExpr) (any, error) {
+	return "(" + f.expr(expr.Expr) + ")", nil
+}
+
+func (f *Formatter) VisitVariableExpr(expr *parser.VariableExpr) (any, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (f *Formatter) VisitUnaryExpr(expr *parser.UnaryExpr) (any, error) {
+	operand := f.expr(expr.Expr)
+	// `- -x` would scan as a decrement without the space
+	if strings.HasPrefix(operand, expr.Operator.Lexeme) {
+		return expr.Operator.Lexeme + " " + operand, nil
+	}
+	return expr.Operator.Lexeme + operand, nil
+}
+
+func (f *Formatter) VisitBinaryExpr(expr *parser.BinaryExpr) (any, error) {
+	// the comma operator reads like an argument list, `a, b`
+	if expr.Operator.TokenType == lexer.COMMA {
+		return f.expr(expr.LeftExpr) + ", " + f.expr(expr.RightExpr), nil
+	}
+	return f.expr(expr.LeftExpr) + " " + expr.Operator.Lexeme + " " + f.expr(expr.RightExpr), nil
+}
+
+func (f *Formatter) VisitLogicalExpr(expr *parser.LogicalExpr) (any, error) {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right), nil
+}
+
+func (f *Formatter) VisitConditionalExpr(expr *parser.ConditionalExpr) (any, error) {
+	return f.expr(expr.Condition) + " ? " + f.expr(expr.ThenBranch) + " : " + f.expr(expr.ElseBranch), nil
+}
+
+func (f *Formatter) VisitAssignExpr(expr *parser.AssignExpr) (any, error) {
+	return expr.Name.Lexeme + " = " + f.expr(expr.Value), nil
+}
+
+func (f *Formatter) VisitCompoundAssignExpr(expr *parser.CompoundAssignExpr) (any, error) {
+	return expr.Name.Lexeme + " " + expr.Operator.Lexeme + " " + f.expr(expr.Value), nil
+}
+
+func (f *Formatter) VisitIncrementExpr(expr *parser.IncrementExpr) (any, error) {
+	if expr.Prefix {
+		return expr.Operator.Lexeme + expr.Name.Lexeme, nil
+	}
+	return expr.Name.Lexeme + expr.Operator.Lexeme, nil
+}
+
+func (f *Formatter) VisitCallExpr(expr *parser.CallExpr) (any, error) {
+	args := make([]string, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		args[i] = f.expr(arg)
+	}
+
+	open := "("
+	if expr.Optional {
+		open = "?.("
+	}
+	return f.expr(expr.Callee) + open + strings.Join(args, ", ") + ")", nil
+}
+
+func (f *Formatter) VisitGetExpr(expr *parser.GetExpr) (any, error) {
+	if expr.Optional {
+		return f.expr(expr.Object) + "?." + expr.Name.Lexeme, nil
+	}
+	return f.expr(expr.Object) + "." + expr.Name.Lexeme, nil
+}
+
+func (f *Formatter) VisitOptionalChainExpr(expr *parser.OptionalChainExpr) (any, error) {
+	return f.expr(expr.Expr), nil
+}
+
+func (f *Formatter) VisitSpawnExpr(expr *parser.SpawnExpr) (any, error) {
+	return "spawn " + f.expr(expr.Call), nil
+}
+
+// the token a bad expression points at is where one was expected, which the enclosing statement writes
+func (f *Formatter) VisitBadExpr(expr *parser.BadExpr) (any, error) {
+	return "", nil
+}
@@ -0,0 +1,52 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func format(source string) (string, []parser.Stmt) {
+	s := lexer.NewScanner(source)
+	stmts := parser.NewParser(s.ScanTokens()).Parse()
+	return NewFormatter(source, s.Comments).Format(stmts), stmts
+}
+
+type FormatterTestCase struct {
+	ID       int
+	Source   string
+	Expected string
+}
+
+var formatterCases = []FormatterTestCase{
+	{ID: 1, Source: "var   a=1+2*3;print a ;", Expected: "var a = 1 + 2 * 3;\nprint a;\n"},
+	{ID: 2, Source: "var x: number? = (1.50);x+=2;x++;--x;", Expected: "var x: number? = (1.50);\nx += 2;\nx++;\n--x;\n"},
+	{ID: 3, Source: "if(a)print 1;else print 2;", Expected: "if (a) print 1;\nelse print 2;\n"},
+	{ID: 4, Source: "if (a) { print 1; } else if (b) { print 2; } else {}", Expected: "if (a) {\n    print 1;\n} else if (b) {\n    print 2;\n} else {}\n"},
+	{ID: 5, Source: "while(i<3){i=i+1;}", Expected: "while (i < 3) {\n    i = i + 1;\n}\n"},
+	{ID: 6, Source: "for(var i=0;i<3;i=i+1){print i;}", Expected: "for (var i = 0; i < 3; i = i + 1) {\n    print i;\n}\n"},
+	{ID: 7, Source: "for(;;)print 1; for (i = 0; i < 1;) {}", Expected: "for (;;) print 1;\nfor (i = 0; i < 1;) {}\n"},
+	{ID: 8, Source: "fun add(a:number,b):number{return a+b;} fun*g(){} fun h(){yield;}", Expected: "fun add(a: number, b): number {\n    return a + b;\n}\nfun* g() {}\nfun h() {\n    yield;\n}\n"},
+	{ID: 9, Source: "print a?.b.c(1,2) ?? f?.(x); var t = spawn f(); print !a and - -b or c ? d : e;", Expected: "print a?.b.c(1, 2) ?? f?.(x);\nvar t = spawn f();\nprint !a and - -b or c ? d : e;\n"},
+	{ID: 10, Source: "match (x) { case 1, -2, \"s\" if y => print 1; case n => { print n; } case _ => {} }", Expected: "match (x) {\n    case 1, -2, \"s\" if y => print 1;\n    case n => {\n        print n;\n    }\n    case _ => {}\n}\n"},
+	// comments stay where they were, and single blank lines between statements are kept
+	{ID: 11, Source: "// header\n\nvar a = 1; // trailing\n\n\n// about b\nvar b = 2;\nfun f() {\n  // inside\n  return;\n}\n// at the end", Expected: "// header\n\nvar a = 1; // trailing\n\n// about b\nvar b = 2;\nfun f() {\n    // inside\n    return;\n}\n// at the end\n"},
+	{ID: 12, Source: "{\n\n  print 1;\n\n  print 2;\n}", Expected: "{\n    print 1;\n\n    print 2;\n}\n"},
+	{ID: 13, Source: "test \"adds\"{assertEqual(1+2,3);} test \"empty\" {}", Expected: "test \"adds\" {\n    assertEqual(1 + 2, 3);\n}\ntest \"empty\" {}\n"},
+	{ID: 14, Source: "#!/usr/bin/env lox\n\n// go\nprint args;", Expected: "#!/usr/bin/env lox\n\n// go\nprint args;\n"},
+	{ID: 15, Source: "print x ? 1 : 2 , 3; for (i = 0,j = 1; i < 3; i++ ,j++) {}", Expected: "print x ? 1 : 2, 3;\nfor (i = 0, j = 1; i < 3; i++, j++) {}\n"},
+}
+
+func TestFormatter(t *testing.T) {
+	for _, testCase := range formatterCases {
+		formatted, stmts := format(testCase.Source)
+		assert.Equal(t, testCase.Expected, formatted, "test case %d failed", testCase.ID)
+
+		// formatting is idempotent and doesn't change what the program means
+		again, reparsed := format(formatted)
+		assert.Equal(t, formatted, again, "test case %d failed", testCase.ID)
+		assert.Equal(t, (&parser.ASTPrinter{}).PrintProgram(stmts), (&parser.ASTPrinter{}).PrintProgram(reparsed), "test case %d failed", testCase.ID)
+	}
+}
@@ -0,0 +1,103 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+var concurrencyCases = []NativeTestCase{
+	{ID: 1, Source: `fun square(n) { return n * n; } var t = spawn square(7); print wait(t); print wait(t);`, Expected: "49\n49\n"},
+	{ID: 2, Source: `fun nothing() {} print wait(spawn nothing()) == nil;`, Expected: "true\n"},
+	// unbuffered channels hand values over one at a time, in order
+	{ID: 3, Source: `fun produce(c, n) { for (var i = 0; i < n; i++) c.send(i); c.close(); } var c = chan(); spawn produce(c, 3); var v = c.recv(); while (v != nil) { print v; v = c.recv(); }`, Expected: "0\n1\n2\n"},
+	{ID: 4, Source: `var c = chan(2); c.send("a"); c.send("b"); c.close(); print c.recv(); print c.recv(); print c.recv() == nil;`, Expected: "a\nb\ntrue\n"},
+	// tasks see the globals and closures they were spawned from
+	{ID: 5, Source: `var total = 0; fun adder(c) { var n = c.recv(); while (n != nil) { total = total + n; n = c.recv(); } } var c = chan(); var t = spawn adder(c); for (var i = 1; i <= 4; i++) c.send(i); c.close(); wait(t); print total;`, Expected: "10\n"},
+	{ID: 6, Source: `fun worker(id, results) { results.send(id * 10); } var results = chan(3); var tasks = list(); for (var i = 1; i <= 3; i++) tasks.push(spawn worker(i, results)); for (var i = 0; i < 3; i++) wait(tasks.get(i)); var sum = 0; for (var i = 0; i < 3; i++) sum += results.recv(); print sum;`, Expected: "60\n"},
+	// select reports which channel was ready, a closed one is ready with nil
+	{ID: 7, Source: `var a = chan(1); var b = chan(1); b.send("hi"); var r = select(a, b); print r.get(0); print r.get(1); a.close(); r = select(a); print r.get(0); print r.get(1) == nil;`, Expected: "1\nhi\n0\ntrue\n"},
+	{ID: 8, Source: `fun ping(c) { print "from task"; c.send(true); } var c = chan(); spawn ping(c); c.recv(); print "after";`, Expected: "from task\nafter\n"},
+	{ID: 9, Source: `print chan(4); fun f() {} print spawn f();`, Expected: "<chan 4>\n<task>\n"},
+}
+
+func TestConcurrency(t *testing.T) {
+	for _, testCase := range concurrencyCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var concurrencyErrorCases = []struct {
+	ID      int
+	Source  string
+	Message string
+	Line    int
+}{
+	// a task's error is raised by wait, at the line the task failed on
+	{ID: 1, Source: "fun bad() {\n return -\"a\";\n}\nvar t = spawn bad();\nwait(t);", Message: "operand must be a number.", Line: 2},
+	{ID: 2, Source: "var c = chan();\nc.close();\nc.send(1);", Message: "send: channel is closed.", Line: 3},
+	{ID: 3, Source: "var c = chan();\nc.close();\nc.close();", Message: "close: channel is already closed.", Line: 3},
+	{ID: 4, Source: "wait(1);", Message: "wait: argument 1 must be a task, got number.", Line: 1},
+	{ID: 5, Source: "select(chan(), 2);", Message: "select: argument 2 must be a channel, got number.", Line: 1},
+	{ID: 6, Source: "chan(-1);", Message: "chan: capacity must not be negative, got -1.", Line: 1},
+	// the call is checked before anything is spawned
+	{ID: 7, Source: "fun f(a) {}\nspawn f();", Message: "expected 1 arguments, got 0", Line: 2},
+	// a generator's body runs on the task that created it
+	{ID: 8, Source: "fun* count() { yield 1; }\nfun take(gen) { return gen.next(); }\nvar gen = count();\nwait(spawn take(gen));", Message: "generator belongs to another task.", Line: 2},
+}
+
+func TestConcurrencyErrors(t *testing.T) {
+	for _, testCase := range concurrencyErrorCases {
+		_, err := interpretSource(testCase.Source)
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Message, err.Message, "test case %d failed", testCase.ID)
+			assert.Equal(t, testCase.Line, err.Token.Line, "test case %d failed", testCase.ID)
+		}
+	}
+}
+
+// run with -race: tasks share the list and map they were spawned with
+func TestSharedCollections(t *testing.T) {
+	i, err := interpretSource(`var items = list(); var seen = dict();
+fun fill(name) { for (var n = 0; n < 100; n++) { items.push(n); seen.set(name + jsonStringify(n), true); } }
+var a = spawn fill("a"); var b = spawn fill("b"); wait(a); wait(b);
+print items.length; print seen.length;`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "200\n200\n", i.Output.String())
+}
+
+// blocking natives give up once the interpreter's Context is cancelled instead of hanging the program
+var cancelledCases = []string{
+	`chan().recv();`,
+	`chan().send(1);`,
+	`select(chan(), chan());`,
+	`fun stuck(c) { c.recv(); } wait(spawn stuck(chan()));`,
+}
+
+func TestCancelBlockedChannels(t *testing.T) {
+	for idx, source := range cancelledCases {
+		stmts := ast.NewParser(lexer.NewScanner(source).ScanTokens()).Parse()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		i := NewInterpreter()
+		i.Context = ctx
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		err := i.Interpret(stmts)
+		if assert.NotNil(t, err, "test case %d failed", idx) {
+			assert.Equal(t, "execution cancelled: context canceled.", err.Message, "test case %d failed", idx)
+			assert.True(t, errors.Is(err, context.Canceled), "test case %d failed", idx)
+		}
+		cancel()
+	}
+}
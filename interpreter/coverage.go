@@ -0,0 +1,230 @@
+package interpreter
+
+import (
+	"sort"
+	"sync"
+
+	ast "github.com/brandonshearin/go-lox/parser"
+)
+
+// Coverage records which statements ran and which way each branch went: the two sides of an `if`,
+// entering or leaving a loop, and whether `and`, `or` and `??` were decided by their left operand.
+// programs are registered under the path of their file before they run, and counts for the same
+// path add up, so one Coverage can collect every run of a test suite. it is safe to share between
+// interpreters on different goroutines
+type Coverage struct {
+	mu    sync.Mutex
+	files map[string]*FileCoverage
+
+	// the counters of the nodes of every registered program
+	statements map[ast.Stmt]*int
+	branches   map[any]*BranchCoverage
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		files:      map[string]*FileCoverage{},
+		statements: map[ast.Stmt]*int{},
+		branches:   map[any]*BranchCoverage{},
+	}
+}
+
+// FileCoverage is what ran of one file
+type FileCoverage struct {
+	Path   string
+	Source string
+
+	// counters are keyed by line and by which of the statements or branches on that line they are,
+	// so parsing a file again finds the same ones
+	statements map[coveragePoint]*int
+	branches   map[coveragePoint]*BranchCoverage
+}
+
+type coveragePoint struct {
+	line    int
+	ordinal int
+}
+
+// BranchCoverage counts the two ways a branch went
+type BranchCoverage struct {
+	Line int
+	// Kind is the keyword or operator that branches: if, while, for, and, or or ??
+	Kind string
+	// Taken counts the first and second way: then and else for an if, running the body and leaving
+	// for a loop, and stopping at the left operand and going on to the right for a logical operator
+	Taken [2]int
+
+	ordinal int
+}
+
+// Ways names the two ways the branch can go
+func (b *BranchCoverage) Ways() [2]string {
+	switch b.Kind {
+	case "if":
+		return [2]string{"then", "else"}
+	case "while", "for":
+		return [2]string{"body", "exit"}
+	default:
+		return [2]string{"left", "right"}
+	}
+}
+
+// Register records the statements and branches of a program parsed from the source of file, which
+// is what lets a report list the ones that never ran
+func (c *Coverage) Register(file, source string, stmts []ast.Stmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.file(file)
+	f.Source = source
+
+	statements, branches := map[int]int{}, map[int]int{}
+	ast.Inspect(stmts, func(node any) {
+		if stmt, ok := node.(ast.Stmt); ok {
+			// statements the parser makes up when desugaring have no line of their own
+			if line := ast.StmtLine(stmt); line > 0 {
+				point := coveragePoint{line, statements[line]}
+				statements[line]++
+				if f.statements[point] == nil {
+					f.statements[point] = new(int)
+				}
+				c.statements[stmt] = f.statements[point]
+			}
+		}
+
+		var line int
+		var kind string
+		switch n := node.(type) {
+		case *ast.IfStmt:
+			line, kind = n.Keyword.Line, n.Keyword.Lexeme
+		case *ast.WhileStmt:
+			line, kind = n.Keyword.Line, n.Keyword.Lexeme
+		case *ast.LogicalExpr:
+			line, kind = n.Operator.Line, n.Operator.Lexeme
+		default:
+			return
+		}
+
+		point := coveragePoint{line, branches[line]}
+		branches[line]++
+		if f.branches[point] == nil {
+			f.branches[point] = &BranchCoverage{Line: line, Kind: kind, ordinal: point.ordinal}
+		}
+		c.branches[node] = f.branches[point]
+	})
+}
+
+func (c *Coverage) file(path string) *FileCoverage {
+	f, ok := c.files[path]
+	if !ok {
+		f = &FileCoverage{
+			Path:       path,
+			statements: map[coveragePoint]*int{},
+			branches:   map[coveragePoint]*BranchCoverage{},
+		}
+		c.files[path] = f
+	}
+	return f
+}
+
+// statement counts a run of stmt, statements from programs that weren't registered aren't counted
+func (c *Coverage) statement(stmt ast.Stmt) {
+	c.mu.Lock()
+	if hits := c.statements[stmt]; hits != nil {
+		*hits++
+	}
+	c.mu.Unlock()
+}
+
+func (c *Coverage) branch(node any, first bool) {
+	c.mu.Lock()
+	if branch := c.branches[node]; branch != nil {
+		if first {
+			branch.Taken[0]++
+		} else {
+			branch.Taken[1]++
+		}
+	}
+	c.mu.Unlock()
+}
+
+// branch records which way a branch point went when coverage is on, and returns first
+func (s *Interpreter) branch(node any, first bool) bool {
+	if s.Coverage != nil {
+		s.Coverage.branch(node, first)
+	}
+	return first
+}
+
+// Merge adds the counts other collected to c, file by file
+func (c *Coverage) Merge(other *Coverage) {
+	if other == c {
+		return
+	}
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path, theirs := range other.files {
+		ours := c.file(path)
+		if ours.Source == "" {
+			ours.Source = theirs.Source
+		}
+		for point, hits := range theirs.statements {
+			if ours.statements[point] == nil {
+				ours.statements[point] = new(int)
+			}
+			*ours.statements[point] += *hits
+		}
+		for point, branch := range theirs.branches {
+			if ours.branches[point] == nil {
+				ours.branches[point] = &BranchCoverage{Line: branch.Line, Kind: branch.Kind, ordinal: branch.ordinal}
+			}
+			ours.branches[point].Taken[0] += branch.Taken[0]
+			ours.branches[point].Taken[1] += branch.Taken[1]
+		}
+	}
+}
+
+// Files returns the coverage of every registered file, ordered by path. its counts keep changing
+// while programs run, so read them once the runs are over
+func (c *Coverage) Files() []*FileCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make([]*FileCoverage, 0, len(c.files))
+	for _, f := range c.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// Lines maps every line that has a statement on it to how many times it ran: as often as the
+// statement on it that ran the most, so `if (x) print x;` ran once when x was false
+func (f *FileCoverage) Lines() map[int]int {
+	lines := map[int]int{}
+	for point, hits := range f.statements {
+		if most, ok := lines[point.line]; !ok || *hits > most {
+			lines[point.line] = *hits
+		}
+	}
+	return lines
+}
+
+// Branches lists the file's branches by line, and in the order they appear on the same line
+func (f *FileCoverage) Branches() []*BranchCoverage {
+	branches := make([]*BranchCoverage, 0, len(f.branches))
+	for _, branch := range f.branches {
+		copied := *branch
+		branches = append(branches, &copied)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		if branches[i].Line != branches[j].Line {
+			return branches[i].Line < branches[j].Line
+		}
+		return branches[i].ordinal < branches[j].ordinal
+	})
+	return branches
+}
@@ -0,0 +1,229 @@
+package interpreter
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CoverageFormats are the formats Coverage.Report can write
+var CoverageFormats = []string{"lcov", "text", "html"}
+
+// Report writes the coverage of every file in one of CoverageFormats
+func (c *Coverage) Report(w io.Writer, format string) error {
+	switch format {
+	case "lcov":
+		return c.reportLCOV(w)
+	case "text":
+		return c.reportText(w)
+	case "html":
+		return c.reportHTML(w)
+	default:
+		return fmt.Errorf("unknown coverage format '%s', expected one of %s", format, strings.Join(CoverageFormats, ", "))
+	}
+}
+
+// CoverageSummary counts what a file has and how much of it ran. every branch has two ways, which are
+// counted separately
+type CoverageSummary struct {
+	Lines, LinesHit       int
+	Branches, BranchesHit int
+}
+
+func percent(hit, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(total))
+}
+
+func (s CoverageSummary) String() string {
+	return fmt.Sprintf("%s of %d lines, %s of %d branches", percent(s.LinesHit, s.Lines), s.Lines, percent(s.BranchesHit, s.Branches), s.Branches)
+}
+
+func (f *FileCoverage) Summary() CoverageSummary {
+	var summary CoverageSummary
+	for _, hits := range f.Lines() {
+		summary.Lines++
+		if hits > 0 {
+			summary.LinesHit++
+		}
+	}
+	for _, branch := range f.Branches() {
+		for _, taken := range branch.Taken {
+			summary.Branches++
+			if taken > 0 {
+				summary.BranchesHit++
+			}
+		}
+	}
+	return summary
+}
+
+func sortedLines(lines map[int]int) []int {
+	numbers := make([]int, 0, len(lines))
+	for line := range lines {
+		numbers = append(numbers, line)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// reportLCOV writes the tracefile format of LCOV, which genhtml and most CI services read. branches are
+// numbered in the order they appear in the file, and a branch that was never reached is taken `-` times
+func (c *Coverage) reportLCOV(w io.Writer) error {
+	for _, f := range c.Files() {
+		fmt.Fprintf(w, "TN:\nSF:%s\n", f.Path)
+
+		summary := f.Summary()
+		for block, branch := range f.Branches() {
+			for way, taken := range branch.Taken {
+				count := "-"
+				if branch.Taken[0]+branch.Taken[1] > 0 {
+					count = fmt.Sprint(taken)
+				}
+				fmt.Fprintf(w, "BRDA:%d,%d,%d,%s\n", branch.Line, block, way, count)
+			}
+		}
+		fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", summary.Branches, summary.BranchesHit)
+
+		lines := f.Lines()
+		for _, line := range sortedLines(lines) {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, lines[line])
+		}
+		if _, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", summary.Lines, summary.LinesHit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportText annotates each file's source the way gcov does: every line starts with how many times
+// it ran, ##### when it never did and - when there's nothing on it to run, and is followed by the
+// branches on it
+func (c *Coverage) reportText(w io.Writer) error {
+	for n, f := range c.Files() {
+		if n > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%9s:%5d:Source:%s\n", "-", 0, f.Path)
+		fmt.Fprintf(w, "%9s:%5d:Coverage:%s\n", "-", 0, f.Summary())
+
+		lines := f.Lines()
+		branches := map[int][]*BranchCoverage{}
+		for _, branch := range f.Branches() {
+			branches[branch.Line] = append(branches[branch.Line], branch)
+		}
+
+		for idx, text := range strings.Split(strings.TrimSuffix(f.Source, "\n"), "\n") {
+			line := idx + 1
+			count := "-"
+			if hits, ok := lines[line]; ok && hits > 0 {
+				count = fmt.Sprint(hits)
+			} else if ok {
+				count = "#####"
+			}
+			fmt.Fprintf(w, "%9s:%5d:%s\n", count, line, text)
+
+			for _, branch := range branches[line] {
+				ways := branch.Ways()
+				for way, taken := range branch.Taken {
+					if branch.Taken[0]+branch.Taken[1] == 0 {
+						fmt.Fprintf(w, "branch %s %s never executed\n", branch.Kind, ways[way])
+					} else {
+						fmt.Fprintf(w, "branch %s %s taken %d\n", branch.Kind, ways[way], taken)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type htmlFile struct {
+	Path    string
+	Summary CoverageSummary
+	Lines   []htmlLine
+}
+
+type htmlLine struct {
+	Number int
+	Text   string
+	Hits   string
+	// Class is covered, uncovered or partial, when only some of the line's branches went both ways
+	Class    string
+	Branches string
+}
+
+var coverageHTML = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lox coverage</title>
+<style>
+body { font-family: sans-serif; }
+table.summary td { padding: 0 1em; }
+pre { line-height: 1.3; }
+.hits { display: inline-block; width: 5em; text-align: right; color: #666; }
+.number { display: inline-block; width: 4em; text-align: right; color: #999; padding-right: 1em; }
+.covered { background: #dfd; }
+.uncovered { background: #fdd; }
+.partial { background: #ffc; }
+</style>
+</head>
+<body>
+<h1>Lox coverage</h1>
+<table class="summary">
+{{range $idx, $file := .}}<tr><td><a href="#file{{$idx}}">{{$file.Path}}</a></td><td>{{$file.Summary}}</td></tr>
+{{end}}</table>
+{{range $idx, $file := .}}
+<h2 id="file{{$idx}}">{{$file.Path}}</h2>
+<pre>
+{{range $file.Lines}}<span class="{{.Class}}"{{if .Branches}} title="{{.Branches}}"{{end}}><span class="hits">{{.Hits}}</span><span class="number">{{.Number}}</span>{{.Text}}</span>
+{{end}}</pre>
+{{end}}
+</body>
+</html>
+`))
+
+// reportHTML writes a single page that summarizes every file and lists its source with the lines
+// that ran in green, the ones that didn't in red and those with a branch that only went one way in
+// yellow. hovering a line shows its branches
+func (c *Coverage) reportHTML(w io.Writer) error {
+	files := []htmlFile{}
+	for _, f := range c.Files() {
+		lines := f.Lines()
+		branches := map[int][]string{}
+		partial := map[int]bool{}
+		for _, branch := range f.Branches() {
+			ways := branch.Ways()
+			for way, taken := range branch.Taken {
+				branches[branch.Line] = append(branches[branch.Line], fmt.Sprintf("%s %s taken %d", branch.Kind, ways[way], taken))
+				if taken == 0 {
+					partial[branch.Line] = true
+				}
+			}
+		}
+
+		file := htmlFile{Path: f.Path, Summary: f.Summary()}
+		for idx, text := range strings.Split(strings.TrimSuffix(f.Source, "\n"), "\n") {
+			line := htmlLine{Number: idx + 1, Text: text, Branches: strings.Join(branches[idx+1], ", ")}
+			if hits, ok := lines[line.Number]; ok {
+				line.Hits = fmt.Sprint(hits)
+				switch {
+				case hits == 0:
+					line.Class = "uncovered"
+				case partial[line.Number]:
+					line.Class = "partial"
+				default:
+					line.Class = "covered"
+				}
+			}
+			file.Lines = append(file.Lines, line)
+		}
+		files = append(files, file)
+	}
+	return coverageHTML.Execute(w, files)
+}
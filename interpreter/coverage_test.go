@@ -0,0 +1,150 @@
+package interpreter
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// cover parses source as file, registers it with c and runs it
+func cover(t *testing.T, c *Coverage, file, source string) {
+	stmts := ast.NewParser(lexer.NewScanner(source).ScanTokens()).Parse()
+	c.Register(file, source, stmts)
+
+	i := NewInterpreter()
+	i.Stdout = io.Discard
+	i.Coverage = c
+	assert.Nil(t, i.Interpret(stmts))
+}
+
+const coverageScript = `fun classify(n) {
+  if (n < 0) {
+    return "negative";
+  } else if (n == 0 or n == 1) {
+    return "small";
+  }
+  return "big";
+}
+
+var i = 0;
+while (i < 3) {
+  print classify(i);
+  i = i + 1;
+}
+`
+
+func TestCoverage(t *testing.T) {
+	c := NewCoverage()
+	cover(t, c, "script.lox", coverageScript)
+
+	files := c.Files()
+	if !assert.Len(t, files, 1) {
+		return
+	}
+	f := files[0]
+	assert.Equal(t, "script.lox", f.Path)
+	assert.Equal(t, map[int]int{1: 1, 2: 3, 3: 0, 4: 3, 5: 2, 7: 1, 10: 1, 11: 3, 12: 3, 13: 3}, f.Lines())
+
+	assert.Equal(t, []*BranchCoverage{
+		{Line: 2, Kind: "if", Taken: [2]int{0, 3}},
+		{Line: 4, Kind: "if", Taken: [2]int{2, 1}},
+		{Line: 4, Kind: "or", Taken: [2]int{1, 2}, ordinal: 1},
+		{Line: 11, Kind: "while", Taken: [2]int{3, 1}},
+	}, f.Branches())
+	assert.Equal(t, CoverageSummary{Lines: 10, LinesHit: 9, Branches: 8, BranchesHit: 7}, f.Summary())
+	assert.Equal(t, "90.0% of 10 lines, 87.5% of 8 branches", f.Summary().String())
+}
+
+func TestCoverageLogicalAndLoops(t *testing.T) {
+	c := NewCoverage()
+	cover(t, c, "script.lox", "var a = false and 1;\nvar b = nil ?? 2;\nfor (var i = 0; i < 2; i = i + 1) {}\nif (true) print 1;")
+
+	assert.Equal(t, []*BranchCoverage{
+		{Line: 1, Kind: "and", Taken: [2]int{1, 0}},
+		{Line: 2, Kind: "??", Taken: [2]int{0, 1}},
+		{Line: 3, Kind: "for", Taken: [2]int{2, 1}},
+		{Line: 4, Kind: "if", Taken: [2]int{1, 0}},
+	}, c.Files()[0].Branches())
+	assert.Equal(t, [2]string{"body", "exit"}, c.Files()[0].Branches()[2].Ways())
+}
+
+func TestCoverageMerge(t *testing.T) {
+	// running the same file again adds to its counts, running another adds a file
+	c := NewCoverage()
+	cover(t, c, "a.lox", "var x = 1;\nif (x > 0) print x;")
+	cover(t, c, "a.lox", "var x = 1;\nif (x > 0) print x;")
+	cover(t, c, "b.lox", "print 2;")
+
+	files := c.Files()
+	if assert.Len(t, files, 2) {
+		assert.Equal(t, "a.lox", files[0].Path)
+		assert.Equal(t, map[int]int{1: 2, 2: 2}, files[0].Lines())
+		assert.Equal(t, [2]int{2, 0}, files[0].Branches()[0].Taken)
+		assert.Equal(t, "b.lox", files[1].Path)
+	}
+
+	// coverage collected separately, e.g. by another process, can be merged in
+	other := NewCoverage()
+	cover(t, other, "a.lox", "var x = -1;\nif (x > 0) print x;")
+	cover(t, other, "c.lox", "print 3;")
+	c.Merge(other)
+
+	files = c.Files()
+	if assert.Len(t, files, 3) {
+		assert.Equal(t, map[int]int{1: 3, 2: 3}, files[0].Lines())
+		assert.Equal(t, [2]int{2, 1}, files[0].Branches()[0].Taken)
+		assert.Equal(t, "c.lox", files[2].Path)
+		assert.Equal(t, map[int]int{1: 1}, files[2].Lines())
+	}
+
+	// programs that weren't registered aren't counted
+	i := NewInterpreter()
+	i.Stdout = io.Discard
+	i.Coverage = c
+	assert.Nil(t, i.Interpret(ast.NewParser(lexer.NewScanner("print 4;").ScanTokens()).Parse()))
+	assert.Len(t, c.Files(), 3)
+}
+
+func TestCoverageReport(t *testing.T) {
+	c := NewCoverage()
+	cover(t, c, "script.lox", "var x = 1;\nif (x > 1) {\n  print x;\n}\n")
+
+	var lcov bytes.Buffer
+	assert.Nil(t, c.Report(&lcov, "lcov"))
+	assert.Equal(t, `TN:
+SF:script.lox
+BRDA:2,0,0,0
+BRDA:2,0,1,1
+BRF:2
+BRH:1
+DA:1,1
+DA:2,1
+DA:3,0
+LF:3
+LH:2
+end_of_record
+`, lcov.String())
+
+	var text bytes.Buffer
+	assert.Nil(t, c.Report(&text, "text"))
+	assert.Equal(t, `        -:    0:Source:script.lox
+        -:    0:Coverage:66.7% of 3 lines, 50.0% of 2 branches
+        1:    1:var x = 1;
+        1:    2:if (x > 1) {
+branch if then taken 0
+branch if else taken 1
+    #####:    3:  print x;
+        -:    4:}
+`, text.String())
+
+	var html bytes.Buffer
+	assert.Nil(t, c.Report(&html, "html"))
+	assert.Contains(t, html.String(), `<span class="partial" title="if then taken 0, if else taken 1"><span class="hits">1</span><span class="number">2</span>if (x &gt; 1) {</span>`)
+	assert.Contains(t, html.String(), `<span class="uncovered"><span class="hits">0</span><span class="number">3</span>  print x;</span>`)
+
+	assert.EqualError(t, c.Report(io.Discard, "xml"), "unknown coverage format 'xml', expected one of lcov, text, html")
+}
@@ -2,13 +2,18 @@ package interpreter
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/brandonshearin/go-lox/lexer"
 )
 
+// Environment is safe to share between spawned tasks: each read and write of a single variable is
+// atomic, but `x = x + 1` still races with another task doing the same
 type Environment struct {
 	Values    map[string]any
 	Enclosing *Environment // implements scope
+
+	mu sync.RWMutex
 }
 
 // factory for the global scope
@@ -28,17 +33,22 @@ func NewEnvironment(e *Environment) *Environment {
 }
 
 func (e *Environment) Define(name string, value any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.Values[name] = value
 }
 
 func (e *Environment) Get(name lexer.Token) (any, error) {
-	if val, ok := e.Values[name.Lexeme]; ok {
+	e.mu.RLock()
+	val, ok := e.Values[name.Lexeme]
+	e.mu.RUnlock()
+	if ok {
 		return val, nil
 	}
 
 	// name wasn't found in current scope, check one level up
 	if e.Enclosing != nil {
-		return e.Get(name)
+		return e.Enclosing.Get(name)
 	} else {
 		// once we reach the global scope, return a runtime error if name never found
 		return nil, &RuntimeError{
@@ -50,8 +60,13 @@ func (e *Environment) Get(name lexer.Token) (any, error) {
 }
 
 func (e *Environment) Assign(name lexer.Token, value any) error {
-	if _, ok := e.Values[name.Lexeme]; ok {
+	e.mu.Lock()
+	_, ok := e.Values[name.Lexeme]
+	if ok {
 		e.Values[name.Lexeme] = value
+	}
+	e.mu.Unlock()
+	if ok {
 		return nil
 	}
 
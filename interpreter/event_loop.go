@@ -0,0 +1,296 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// EventLoop runs timer callbacks and host callbacks one at a time, after the main program finishes.
+// scheduling is safe from any goroutine, callbacks always run on the goroutine that called Run
+type EventLoop struct {
+	mu sync.Mutex
+
+	timers []*timer
+	nextID int
+	queue  []func(*Interpreter) error
+	// outstanding holds keep the loop alive while host code may still enqueue something
+	holds int
+	// signalled whenever work arrives, so a loop waiting on a timer or a hold can pick it up
+	wake chan struct{}
+
+	// a virtual loop never sleeps: it jumps its clock straight to the next timer that is due
+	virtual bool
+	now     time.Time
+}
+
+type timer struct {
+	id       int
+	deadline time.Time
+	interval time.Duration // zero for timeouts
+	callback LoxCallable
+}
+
+// NewEventLoop creates a loop that waits on the wall clock
+func NewEventLoop() *EventLoop {
+	return &EventLoop{wake: make(chan struct{}, 1)}
+}
+
+// NewVirtualEventLoop creates a loop whose clock starts at zero and only moves when the loop skips
+// ahead to a due timer, so programs using timers run instantly and in a deterministic order
+func NewVirtualEventLoop() *EventLoop {
+	return &EventLoop{wake: make(chan struct{}, 1), virtual: true, now: time.Unix(0, 0)}
+}
+
+// Now is the loop's current time, which only differs from the wall clock for virtual loops
+func (l *EventLoop) Now() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.clock()
+}
+
+func (l *EventLoop) clock() time.Time {
+	if l.virtual {
+		return l.now
+	}
+	return time.Now()
+}
+
+// Enqueue schedules callback to run on the loop ahead of any timer. safe to call from any goroutine
+func (l *EventLoop) Enqueue(callback func(*Interpreter) error) {
+	l.mu.Lock()
+	l.queue = append(l.queue, callback)
+	l.mu.Unlock()
+	l.signal()
+}
+
+// Hold keeps the loop running while host code has callbacks left to enqueue, even once no timers remain.
+// the returned release function must be called exactly once when the host is done
+func (l *EventLoop) Hold() (release func()) {
+	l.mu.Lock()
+	l.holds++
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.holds--
+			l.mu.Unlock()
+			l.signal()
+		})
+	}
+}
+
+func (l *EventLoop) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *EventLoop) schedule(callback LoxCallable, delay, interval time.Duration) int {
+	l.mu.Lock()
+	l.nextID++
+	t := &timer{id: l.nextID, deadline: l.clock().Add(delay), interval: interval, callback: callback}
+	l.timers = append(l.timers, t)
+	l.mu.Unlock()
+
+	l.signal()
+	return t.id
+}
+
+func (l *EventLoop) clear(id int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remove(id)
+}
+
+// callers hold mu
+func (l *EventLoop) remove(id int) {
+	for idx, t := range l.timers {
+		if t.id == id {
+			l.timers = append(l.timers[:idx], l.timers[idx+1:]...)
+			return
+		}
+	}
+}
+
+// earliest is the timer due first, ties going to the one scheduled first. callers hold mu
+func (l *EventLoop) earliest() *timer {
+	var next *timer
+	for _, t := range l.timers {
+		if next == nil || t.deadline.Before(next.deadline) || (t.deadline.Equal(next.deadline) && t.id < next.id) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Run executes callbacks until no timers, queued callbacks or holds remain. the first callback to fail
+// stops the loop and its error is returned, as does cancelling the interpreter's Context. a loop cancelled
+// between callbacks isn't anywhere in the program, so that error has no location
+func (l *EventLoop) Run(i *Interpreter) *RuntimeError {
+	var cancel <-chan struct{}
+	if i.Context != nil {
+		cancel = i.Context.Done()
+	}
+
+	for {
+		if err := i.cancelled(noLocation); err != nil {
+			return err.(*RuntimeError)
+		}
+
+		callback, done := l.nextCallback(cancel)
+		if done {
+			return nil
+		}
+		if callback == nil {
+			continue
+		}
+		if err := callback(i); err != nil {
+			return asRuntimeError(err)
+		}
+	}
+}
+
+// nextCallback waits for the next piece of work. it returns a nil callback when it was woken without
+// anything being due yet, and done once the loop has nothing left to wait for
+func (l *EventLoop) nextCallback(cancel <-chan struct{}) (callback func(*Interpreter) error, done bool) {
+	l.mu.Lock()
+
+	if len(l.queue) > 0 {
+		callback = l.queue[0]
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+		return callback, false
+	}
+
+	next := l.earliest()
+	if next == nil && l.holds == 0 {
+		l.mu.Unlock()
+		return nil, true
+	}
+
+	if next != nil && l.virtual && next.deadline.After(l.now) {
+		l.now = next.deadline
+	}
+	if next != nil && !next.deadline.After(l.clock()) {
+		l.fire(next)
+		l.mu.Unlock()
+		return func(i *Interpreter) error {
+			_, err := next.callback.Call(i, nil)
+			return err
+		}, false
+	}
+	l.mu.Unlock()
+
+	if next == nil {
+		select {
+		case <-l.wake:
+		case <-cancel:
+		}
+		return nil, false
+	}
+
+	// something scheduled while sleeping may be due sooner, so any new work wakes the loop up early
+	wait := time.NewTimer(time.Until(next.deadline))
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+	case <-l.wake:
+	case <-cancel:
+	}
+	return nil, false
+}
+
+// fire reschedules an interval for its next tick or removes a timeout. callers hold mu
+func (l *EventLoop) fire(t *timer) {
+	if t.interval > 0 {
+		t.deadline = t.deadline.Add(t.interval)
+		return
+	}
+	l.remove(t.id)
+}
+
+// asRuntimeError gives errors from natives run by the loop, which have no call site, the same type
+// as errors raised by lox code
+func asRuntimeError(err error) *RuntimeError {
+	var runtimeErr *RuntimeError
+	if errors.As(err, &runtimeErr) {
+		return runtimeErr
+	}
+	return &RuntimeError{Message: err.Error(), Err: err}
+}
+
+func defineTimerNatives(env *Environment) {
+	defineNative(env, "setTimeout", 2, nativeSetTimeout)
+	defineNative(env, "setInterval", 2, nativeSetInterval)
+	defineNative(env, "clearTimer", 1, nativeClearTimer)
+}
+
+// setTimeout(fn, ms) calls fn once, ms milliseconds after the main program has had a chance to finish,
+// and returns the id to cancel it with
+func nativeSetTimeout(i *Interpreter, args []any) (any, error) {
+	return startTimer("setTimeout", i, args, false)
+}
+
+// setInterval(fn, ms) calls fn every ms milliseconds until it is cleared
+func nativeSetInterval(i *Interpreter, args []any) (any, error) {
+	return startTimer("setInterval", i, args, true)
+}
+
+// maxTimerDelay is the longest delay a timer can have, in milliseconds
+const maxTimerDelay = float64(math.MaxInt64 / time.Millisecond)
+
+func startTimer(fn string, i *Interpreter, args []any, repeat bool) (any, error) {
+	if i.Loop == nil {
+		return nil, fmt.Errorf("%s: no event loop is running.", fn)
+	}
+	callback, err := callableArg(fn, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if callback.Arity() > 0 {
+		return nil, fmt.Errorf("%s: callback must take no arguments, takes %d.", fn, callback.Arity())
+	}
+	ms, err := numberArg(fn, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if ms < 0 {
+		return nil, fmt.Errorf("%s: delay must not be negative, got %v.", fn, ms)
+	}
+	// anything longer doesn't fit in a time.Duration. written so NaN fails it too
+	if !(ms <= maxTimerDelay) {
+		return nil, fmt.Errorf("%s: delay must be at most %.0f, got %v.", fn, maxTimerDelay, ms)
+	}
+	// an interval of 0 would starve everything else on the loop
+	if repeat && ms == 0 {
+		return nil, fmt.Errorf("%s: interval must be positive.", fn)
+	}
+
+	delay := time.Duration(ms * float64(time.Millisecond))
+	var interval time.Duration
+	if repeat {
+		// so would one that rounds down to 0
+		interval = max(delay, time.Nanosecond)
+		delay = interval
+	}
+	return float64(i.Loop.schedule(callback, delay, interval)), nil
+}
+
+// clearTimer(id) cancels a timeout or interval, ids that already fired or never existed are ignored
+func nativeClearTimer(i *Interpreter, args []any) (any, error) {
+	if i.Loop == nil {
+		return nil, errors.New("clearTimer: no event loop is running.")
+	}
+	id, err := intArg("clearTimer", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	i.Loop.clear(id)
+	return nil, nil
+}
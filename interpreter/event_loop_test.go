@@ -0,0 +1,127 @@
+package interpreter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// interpretWithLoop runs source and then its timers on loop
+func interpretWithLoop(source string, loop *EventLoop) (*Interpreter, *RuntimeError) {
+	tokens := lexer.NewScanner(source).ScanTokens()
+	stmts := ast.NewParser(tokens).Parse()
+
+	i := NewInterpreter()
+	i.Loop = loop
+	if err := i.Interpret(stmts); err != nil {
+		return i, err
+	}
+	return i, loop.Run(i)
+}
+
+var eventLoopCases = []NativeTestCase{
+	{ID: 1, Source: `fun a() { print "a"; } fun b() { print "b"; } setTimeout(a, 20); setTimeout(b, 10); print "main";`, Expected: "main\nb\na\n"},
+	// timers due at the same time fire in the order they were set
+	{ID: 2, Source: `fun a() { print "a"; } fun b() { print "b"; } setTimeout(a, 5); setTimeout(b, 5); setTimeout(a, 0);`, Expected: "a\na\nb\n"},
+	{ID: 3, Source: `var n = 0; var id; fun tick() { n++; print n; if (n == 3) clearTimer(id); } id = setInterval(tick, 100);`, Expected: "1\n2\n3\n"},
+	{ID: 4, Source: `fun never() { print "never"; } var id = setTimeout(never, 10); clearTimer(id); clearTimer(id); clearTimer(99); print "done";`, Expected: "done\n"},
+	// virtual time only moves when the loop skips to the next timer
+	{ID: 5, Source: `var start = clock(); fun later() { print clock() - start; } setTimeout(later, 1500); print clock() - start;`, Expected: "0\n1.5\n"},
+	// callbacks can schedule more callbacks, relative to when they run
+	{ID: 6, Source: `var start = clock(); fun second() { print clock() - start; } fun first() { print clock() - start; setTimeout(second, 250); } setTimeout(first, 250);`, Expected: "0.25\n0.5\n"},
+	{ID: 7, Source: `var ticks = 0; var fast; fun f() { ticks++; } fun stop() { clearTimer(fast); print ticks; } fast = setInterval(f, 10); setTimeout(stop, 55);`, Expected: "5\n"},
+	// an interval too short for a time.Duration still moves time forward, by a nanosecond
+	{ID: 8, Source: `var ticks = 0; var fast; fun f() { ticks++; } fun stop() { clearTimer(fast); print ticks; } fast = setInterval(f, 0.0000001); setTimeout(stop, 0.0000055);`, Expected: "5\n"},
+}
+
+func TestEventLoop(t *testing.T) {
+	for _, testCase := range eventLoopCases {
+		i, err := interpretWithLoop(testCase.Source, NewVirtualEventLoop())
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+func TestEventLoopErrors(t *testing.T) {
+	cases := []NativeTestCase{
+		{ID: 1, Source: `fun f(x) {} setTimeout(f, 1);`, Expected: "setTimeout: callback must take no arguments, takes 1."},
+		{ID: 2, Source: `fun f() {} setTimeout(f, -1);`, Expected: "setTimeout: delay must not be negative, got -1."},
+		{ID: 3, Source: `fun f() {} setInterval(f, 0);`, Expected: "setInterval: interval must be positive."},
+		{ID: 4, Source: `setTimeout(1, 1);`, Expected: "setTimeout: argument 1 must be a function, got number."},
+		{ID: 6, Source: `fun f() {} setTimeout(f, 10000000000 * 1000);`, Expected: "setTimeout: delay must be at most 9223372036854, got 1e+13."},
+		{ID: 7, Source: `fun f() {} setInterval(f, 0/0);`, Expected: "setInterval: delay must be at most 9223372036854, got NaN."},
+		// the first callback to fail stops the loop
+		{ID: 5, Source: "fun bad() { print -\"a\"; }\nfun after() { print \"after\"; }\nsetTimeout(bad, 1); setTimeout(after, 2);", Expected: "operand must be a number."},
+	}
+
+	for _, testCase := range cases {
+		i, err := interpretWithLoop(testCase.Source, NewVirtualEventLoop())
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, err.Message, "test case %d failed", testCase.ID)
+		}
+		assert.Empty(t, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+
+	_, err := interpretSource(`fun f() {} setTimeout(f, 1);`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "setTimeout: no event loop is running.", err.Message)
+	}
+}
+
+func TestEventLoopHostCallbacks(t *testing.T) {
+	loop := NewEventLoop()
+	release := loop.Hold()
+
+	// host goroutines hand values to the program through the loop, which keeps running until released
+	var wg sync.WaitGroup
+	for n := 1; n <= 3; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(n) * 5 * time.Millisecond)
+			loop.Enqueue(func(i *Interpreter) error {
+				handler, err := i.Environment.Get(lexer.Token{Lexeme: "onValue"})
+				if err != nil {
+					return err
+				}
+				_, err = handler.(LoxCallable).Call(i, []any{float64(n)})
+				return err
+			})
+		}(n)
+	}
+	go func() {
+		wg.Wait()
+		release()
+	}()
+
+	i, err := interpretWithLoop(`var total = 0; fun onValue(n) { total += n; } fun report() { print "timer"; } setTimeout(report, 1);`, loop)
+	assert.Nil(t, err)
+	assert.Equal(t, "timer\n", i.Output.String())
+
+	total, _ := i.Environment.Get(lexer.Token{Lexeme: "total"})
+	assert.Equal(t, 6.0, total)
+}
+
+func TestEventLoopCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	tokens := lexer.NewScanner(`fun tick() {} setInterval(tick, 60000);`).ScanTokens()
+	i := NewInterpreter()
+	i.Context, i.Loop = ctx, NewEventLoop()
+	assert.Nil(t, i.Interpret(ast.NewParser(tokens).Parse()))
+
+	// the loop is cancelled while it waits for the next tick, which isn't on any line
+	err := i.Loop.Run(i)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "execution cancelled: context deadline exceeded.", err.Message)
+		assert.Equal(t, 0, err.Token.Line)
+	}
+}
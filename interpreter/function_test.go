@@ -0,0 +1,92 @@
+package interpreter
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+var returnCases = []NativeTestCase{
+	{ID: 1, Source: `fun add(a, b) { return a + b; } print add(1, 2);`, Expected: "3\n"},
+	{ID: 2, Source: `fun nothing() { return; } print nothing() == nil;`, Expected: "true\n"},
+	// return unwinds out of loops and nested blocks
+	{ID: 3, Source: `fun first(n) { for (var i = 0; i < 10; i++) { if (i * i > n) { return i; } } } print first(20);`, Expected: "5\n"},
+	{ID: 4, Source: `fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(10);`, Expected: "55\n"},
+	// annotations are only for the type checker
+	{ID: 5, Source: `fun greet(name: string, times: number?): string { var s: string = "hi " + name; return s; } print greet("lox", nil);`, Expected: "hi lox\n"},
+}
+
+func TestReturnStmt(t *testing.T) {
+	for _, testCase := range returnCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+const tailRecursive = `
+fun countdown(n) { if (n == 0) return "done"; return countdown(n - 1); }
+fun isEven(n) { if (n == 0) return true; return isOdd(n - 1); }
+fun isOdd(n) { if (n == 0) return false; return isEven(n - 1); }
+fun sum(n, acc) { if (n == 0) return acc; return sum(n - 1, acc + n); }
+`
+
+func TestTailCalls(t *testing.T) {
+	// without tail calls a million nested lox calls needs far more than this. if the trampoline breaks,
+	// the test binary dies with a stack overflow instead of failing quietly
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	i, err := interpretSource(tailRecursive + `print countdown(1000000); print isEven(100001); print sum(1000, 0);`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "done\nfalse\n500500\n", i.Output.String())
+}
+
+func TestTailCallsDisabled(t *testing.T) {
+	source := tailRecursive + `print countdown(100); print isOdd(7); print sum(10, 0);
+fun native(s) { return upper(s); } print native("x");
+fun wrong(n) { return countdown(n, 1); } wrong(1);`
+
+	for _, disabled := range []bool{false, true} {
+		tokens := lexer.NewScanner(source).ScanTokens()
+		i := NewInterpreter()
+		i.DisableTailCalls = disabled
+
+		err := i.Interpret(ast.NewParser(tokens).Parse())
+
+		assert.Equal(t, "done\ntrue\n55\nX\n", i.Output.String())
+		if assert.NotNil(t, err) {
+			assert.Equal(t, "expected 1 arguments, got 2", err.Message)
+			assert.Equal(t, 8, err.Token.Line)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	// running out of Go stack kills the process, so the limit has to be reached well before that
+	defer debug.SetMaxStack(debug.SetMaxStack(64 << 20))
+
+	sources := []string{
+		"fun f(n) {\n  if (n > 0) f(n - 1);\n}\nf(10000000);",
+		tailRecursive + "countdown(10000000);",
+	}
+	for _, source := range sources {
+		tokens := lexer.NewScanner(source).ScanTokens()
+		i := NewInterpreter()
+		i.DisableTailCalls = true
+
+		err := i.Interpret(ast.NewParser(tokens).Parse())
+		if assert.NotNil(t, err, source) {
+			assert.Equal(t, "Stack overflow.", err.Message)
+		}
+
+		// the calls that overflowed have all returned, so the program can call functions again
+		err = i.Interpret(ast.NewParser(lexer.NewScanner(tailRecursive + "print countdown(10);").ScanTokens()).Parse())
+		assert.Nil(t, err)
+		assert.Equal(t, "done\n", i.Output.String())
+	}
+}
@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+var generatorCases = []NativeTestCase{
+	{ID: 1, Source: `fun* count(n) { for (var i = 0; i < n; i++) yield i; } var g = count(3); while (!g.done()) print g.next(); print g.done();`, Expected: "0\n1\n2\ntrue\n"},
+	// next() past the end keeps returning nil
+	{ID: 2, Source: `fun one() { yield 1; } var g = one(); print g.next(); print g.next() == nil; print g.next() == nil;`, Expected: "1\ntrue\ntrue\n"},
+	// bodies only run as values are asked for
+	{ID: 3, Source: `fun noisy() { print "a"; yield 1; print "b"; yield 2; print "c"; } var g = noisy(); print "start"; print g.next(); print g.done(); print g.next(); print g.done();`, Expected: "start\na\n1\nb\nfalse\n2\nc\ntrue\n"},
+	// fun* makes a generator even without a yield, return ends it early
+	{ID: 4, Source: `fun* empty() {} print empty().done(); fun early() { yield 1; return 5; yield 2; } var g = early(); print g.next(); print g.done();`, Expected: "true\n1\ntrue\n"},
+	// closures over the enclosing function, and the generator's own locals survive between values
+	{ID: 5, Source: `fun counter(step) { var total = 0; fun* tick() { while (true) { total += step; yield total; } } return tick(); } var c = counter(5); c.next(); print c.next(); var d = counter(1); print d.next(); print c.next();`, Expected: "10\n1\n15\n"},
+	// generators consuming generators
+	{ID: 6, Source: `fun* count(n) { for (var i = 0; i < n; i++) yield i; } fun* squares(g) { while (!g.done()) { var n = g.next(); yield n * n; } } var s = squares(count(4)); while (!s.done()) print s.next();`, Expected: "0\n1\n4\n9\n"},
+	// the consumer's scope is untouched by the generator's
+	{ID: 7, Source: `var x = "outer"; fun shadow() { var x = "inner"; yield x; yield x; } var g = shadow(); { var y = "block"; print g.next(); print x; print y; } print g.next();`, Expected: "inner\nouter\nblock\ninner\n"},
+	{ID: 8, Source: `fun* bare() { yield; } print bare().next() == nil; print bare().done();`, Expected: "true\nfalse\n"},
+	{ID: 9, Source: `fun* g() { yield 1; } print g();`, Expected: "<generator g>\n"},
+}
+
+func TestGenerators(t *testing.T) {
+	for _, testCase := range generatorCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	// errors in the body surface from whichever call made it run, with the body's line
+	i, err := interpretSource("fun bad() { yield 1;\n yield -\"a\"; }\nvar g = bad(); print g.next(); print g.done();")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "operand must be a number.", err.Message)
+		assert.Equal(t, 2, err.Token.Line)
+	}
+	assert.Equal(t, "1\n", i.Output.String())
+
+	// a failed generator is finished
+	i, err = interpretSource(`fun bad() { yield nope; } var g = bad(); g.next();`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "undefined variable 'nope'.", err.Message)
+	}
+
+	_, err = interpretSource(`var g; fun loop() { yield g.next(); } g = loop(); g.next();`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "generator is already running.", err.Message)
+	}
+
+	_, err = interpretSource(`fun* g() {} g().resume();`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "undefined property 'resume'.", err.Message)
+	}
+}
+
+func TestAbandonedGenerators(t *testing.T) {
+	// a long running program like the REPL never shuts down, the generators it drops have to unwind
+	// without that
+	i := NewInterpreter()
+	i.Stdout = io.Discard
+	run := func(source string) {
+		err := i.Interpret(ast.NewParser(lexer.NewScanner(source).ScanTokens()).Parse())
+		assert.Nil(t, err)
+	}
+	run(`fun* naturals() { var n = 0; while (true) { yield n; n++; } }
+fun take() { var g = naturals(); g.next(); return g.next(); }`)
+
+	before := runtime.NumGoroutine()
+	for n := 0; n < 1000; n++ {
+		run("print take();")
+	}
+	assert.Equal(t, 1000, strings.Count(i.Output.String(), "1\n"))
+
+	// finalizers run after a collection finds the generators, and unwinding takes a moment more
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
@@ -2,8 +2,13 @@ package interpreter
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"sync"
 
 	"github.com/brandonshearin/go-lox/lexer"
 	ast "github.com/brandonshearin/go-lox/parser"
@@ -11,28 +16,104 @@ import (
 
 // Interpreter implements `ExprVisitor` interface and `StmtVisitor` interface
 type Interpreter struct {
-	Environment Environment
+	Environment *Environment
 	Output      bytes.Buffer
+	// Stdout receives everything printed as it is printed, os.Stdout when nil
+	Stdout io.Writer
+
+	// Context stops the program at the next loop iteration or call once it is cancelled, nil never cancels
+	Context context.Context
+
+	// FS limits what the file system natives may touch, nothing is accessible by default
+	FS FSPolicy
+
+	// Loop runs timers once the program finishes, the timer natives fail when it is nil
+	Loop *EventLoop
+
+	// DisableTailCalls makes `return f(...)` a plain nested call, keeping every frame for debugging
+	DisableTailCalls bool
+	// MaxCallDepth fails a call with "Stack overflow." once this many are running, DefaultMaxCallDepth
+	// when 0. much deeper than the default can exhaust the Go stack, which no recover survives
+	MaxCallDepth int
+
+	// Profiler times every statement and call while it is set, see Profiler
+	Profiler *Profiler
+	// Coverage counts the statements and branches of registered programs while it is set, see Coverage
+	Coverage *Coverage
+	// the frames and statements being profiled on this interpreter, nil until the first one
+	profile *profileStack
+
+	// the generator whose body this interpreter runs, nil on the interpreters of tasks
+	generator *generatorState
+	// how many Lox function calls are running on this interpreter, see MaxCallDepth
+	callDepth int
+
+	// compiled patterns so `regex` calls inside loops don't recompile
+	regexCache regexCache
+
+	// spawned tasks print into the Output of the interpreter that started the program, taking turns
+	// under outputMu. both are nil until the first spawn
+	output   *bytes.Buffer
+	outputMu *sync.Mutex
+	// the goroutines of the program's tasks and generators, shared with its tasks and nil until the first
+	goroutines *goroutineGroup
+}
+
+// goroutineGroup tracks the goroutines a program starts so it can stop them once it is done. tasks run
+// under ctx, which is cancelled along with the interpreter's own Context, and paused generators unwind
+// once closing is
+type goroutineGroup struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	tasks      sync.WaitGroup
+	closing    chan struct{}
+	generators sync.WaitGroup
+}
+
+// group returns the program's goroutineGroup, starting it if this is its first goroutine
+func (s *Interpreter) group() *goroutineGroup {
+	if s.goroutines == nil {
+		parent := s.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithCancel(parent)
+		s.goroutines = &goroutineGroup{ctx: ctx, cancel: cancel, closing: make(chan struct{})}
+	}
+	return s.goroutines
 }
 
 func NewInterpreter() *Interpreter {
 	globals := NewGlobalEnvironment()
-	globals.Define("clock", Clock{})
+	globals.Define("clock", &Clock{})
+	defineStringNatives(globals)
+	defineRegexNatives(globals)
+	defineCollectionNatives(globals)
+	defineJSONNatives(globals)
+	defineFSNatives(globals)
+	defineConcurrencyNatives(globals)
+	defineTimerNatives(globals)
+	defineProcessNatives(globals)
+	defineAssertNatives(globals)
 	return &Interpreter{
-		Environment: *globals,
+		Environment: globals,
 	}
 }
 
 func (s *Interpreter) Interpret(stmts []ast.Stmt) *RuntimeError {
 	for _, stmt := range stmts {
 		if err := s.execute(stmt); err != nil {
-			e := err.(*RuntimeError)
-			return e
+			return asRuntimeError(err)
 		}
 	}
 	return nil
 }
 
+// Evaluate computes a single expression in the current environment
+func (s *Interpreter) Evaluate(expr ast.Expr) (any, error) {
+	return s.evaluate(expr)
+}
+
 // ExprVisitor implementation below ----------------------------------------------------------------
 func (s *Interpreter) evaluate(expr ast.Expr) (any, error) {
 	return expr.Accept(s)
@@ -59,6 +140,12 @@ func (s *Interpreter) VisitUnaryExpr(expr *ast.UnaryExpr) (any, error) {
 			} else {
 				return -right.(float64), nil
 			}
+		case lexer.TILDE:
+			if operand, err := checkIntegerOperand(lexer.Token(expr.Operator), right); err != nil {
+				return nil, err
+			} else {
+				return float64(^operand), nil
+			}
 		}
 	}
 
@@ -71,66 +158,99 @@ func (s *Interpreter) VisitBinaryExpr(expr *ast.BinaryExpr) (any, error) {
 	} else if right, err := s.evaluate(expr.RightExpr); err != nil {
 		return nil, err
 	} else {
-		switch expr.Operator.TokenType {
-		case lexer.MINUS:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) - right.(float64), nil
-		case lexer.SLASH:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) / right.(float64), nil
-		case lexer.STAR:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) * right.(float64), nil
-		case lexer.PLUS:
-			leftNum, leftIsNumber := left.(float64)
-			rightNum, rightIsNumber := right.(float64)
+		return applyBinary(lexer.Token(expr.Operator), expr.Operator.TokenType, left, right)
+	}
+}
 
-			if leftIsNumber && rightIsNumber {
-				return leftNum + rightNum, nil
-			}
+// applyBinary evaluates `left op right`. errors are reported at operator, which for compound assignments
+// like `+=` is a different token than op.
+func applyBinary(operator lexer.Token, op lexer.TokenType, left, right any) (any, error) {
+	switch op {
+	case lexer.MINUS:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return left.(float64) - right.(float64), nil
+	case lexer.SLASH:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return left.(float64) / right.(float64), nil
+	case lexer.STAR:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return left.(float64) * right.(float64), nil
+	case lexer.COMMA:
+		// both operands have already been evaluated in order, the comma operator yields the right one
+		return right, nil
+	case lexer.STAR_STAR:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return math.Pow(left.(float64), right.(float64)), nil
+	case lexer.PERCENT:
+		// the remainder takes the sign of the dividend, as in C and javascript: -7 % 3 is -1 and 7 % -3 is 1.
+		// together with `~/` this keeps a == (a ~/ b) * b + a % b
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		} else if err := checkNonZeroDivisor(operator, right); err != nil {
+			return nil, err
+		}
+		return math.Mod(left.(float64), right.(float64)), nil
+	case lexer.TILDE_SLASH:
+		// integer division truncates toward zero: -7 ~/ 2 is -3
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		} else if err := checkNonZeroDivisor(operator, right); err != nil {
+			return nil, err
+		}
+		return math.Trunc(left.(float64) / right.(float64)), nil
+	case lexer.AMPERSAND, lexer.PIPE, lexer.CARET, lexer.LESS_LESS, lexer.GREATER_GREATER:
+		return evaluateBitwise(operator, op, left, right)
+	case lexer.PLUS:
+		leftNum, leftIsNumber := left.(float64)
+		rightNum, rightIsNumber := right.(float64)
+
+		if leftIsNumber && rightIsNumber {
+			return leftNum + rightNum, nil
+		}
 
-			leftStr, leftIsString := left.(string)
-			rightStr, rightIsString := right.(string)
+		leftStr, leftIsString := left.(string)
+		rightStr, rightIsString := right.(string)
 
-			if leftIsString && rightIsString {
-				return leftStr + rightStr, nil
-			}
+		if leftIsString && rightIsString {
+			return leftStr + rightStr, nil
+		}
 
-			return nil, &RuntimeError{
-				Token:   lexer.Token(expr.Operator),
-				Message: "operands must be two numbers or two strings.",
-			}
-		case lexer.GREATER:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) > right.(float64), nil
-		case lexer.GREATER_EQUAL:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) >= right.(float64), nil
-		case lexer.LESS:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) < right.(float64), nil
-		case lexer.LESS_EQUAL:
-			if err := checkNumberOperands(lexer.Token(expr.Operator), left, right); err != nil {
-				return nil, err
-			}
-			return left.(float64) <= right.(float64), nil
-		case lexer.BANG_EQUAL:
-			return !isEqual(left, right), nil
-		case lexer.EQUAL_EQUAL:
-			return isEqual(left, right), nil
+		return nil, &RuntimeError{
+			Token:   operator,
+			Message: "operands must be two numbers or two strings.",
+		}
+	case lexer.GREATER:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
 		}
+		return left.(float64) > right.(float64), nil
+	case lexer.GREATER_EQUAL:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return left.(float64) >= right.(float64), nil
+	case lexer.LESS:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return left.(float64) < right.(float64), nil
+	case lexer.LESS_EQUAL:
+		if err := checkNumberOperands(operator, left, right); err != nil {
+			return nil, err
+		}
+		return left.(float64) <= right.(float64), nil
+	case lexer.BANG_EQUAL:
+		return !isEqual(left, right), nil
+	case lexer.EQUAL_EQUAL:
+		return isEqual(left, right), nil
 	}
 
 	return nil, nil
@@ -150,16 +270,87 @@ func (s *Interpreter) VisitAssignExpr(expr *ast.AssignExpr) (any, error) {
 	}
 }
 
+// compound assignments look the variable up once and reuse the checks of the matching binary operator,
+// so `s += "x"` concatenates strings just like `s = s + "x"`
+var compoundOperators = map[lexer.TokenType]lexer.TokenType{
+	lexer.PLUS_EQUAL:  lexer.PLUS,
+	lexer.MINUS_EQUAL: lexer.MINUS,
+	lexer.STAR_EQUAL:  lexer.STAR,
+	lexer.SLASH_EQUAL: lexer.SLASH,
+}
+
+func (s *Interpreter) VisitCompoundAssignExpr(expr *ast.CompoundAssignExpr) (any, error) {
+	current, err := s.Environment.Get(expr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.evaluate(expr.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := applyBinary(lexer.Token(expr.Operator), compoundOperators[expr.Operator.TokenType], current, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Environment.Assign(expr.Name, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Interpreter) VisitIncrementExpr(expr *ast.IncrementExpr) (any, error) {
+	current, err := s.Environment.Get(expr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNumberOperand(lexer.Token(expr.Operator), current); err != nil {
+		return nil, err
+	}
+
+	updated := current.(float64) + 1
+	if expr.Operator.TokenType == lexer.MINUS_MINUS {
+		updated = current.(float64) - 1
+	}
+
+	if err := s.Environment.Assign(expr.Name, updated); err != nil {
+		return nil, err
+	}
+
+	if expr.Prefix {
+		return updated, nil
+	}
+	return current, nil
+}
+
+func (s *Interpreter) VisitConditionalExpr(expr *ast.ConditionalExpr) (any, error) {
+	if condition, err := s.evaluate(expr.Condition); err != nil {
+		return nil, err
+	} else if isTruthy(condition) {
+		return s.evaluate(expr.ThenBranch)
+	} else {
+		return s.evaluate(expr.ElseBranch)
+	}
+}
+
 func (s *Interpreter) VisitLogicalExpr(expr *ast.LogicalExpr) (any, error) {
 	if left, err := s.evaluate(expr.Left); err != nil {
 		return nil, err
 	} else {
-		if expr.Operator.TokenType == lexer.OR {
-			if isTruthy(left) {
+		if expr.Operator.TokenType == lexer.QUESTION_QUESTION {
+			// unlike `or`, `??` only falls through to the right operand on nil, so `false ?? x` is false
+			if s.branch(expr, left != nil) {
+				return left, nil
+			}
+		} else if expr.Operator.TokenType == lexer.OR {
+			if s.branch(expr, isTruthy(left)) {
 				return left, nil
 			}
 		} else {
-			if !isTruthy(left) {
+			if s.branch(expr, !isTruthy(left)) {
 				return left, nil
 			}
 		}
@@ -169,34 +360,154 @@ func (s *Interpreter) VisitLogicalExpr(expr *ast.LogicalExpr) (any, error) {
 }
 
 func (s *Interpreter) VisitCallExpr(expr *ast.CallExpr) (any, error) {
-	if callee, err := s.evaluate(expr.Callee); err != nil {
+	callee, args, err := s.evaluateCall(expr)
+	if err != nil {
 		return nil, err
-	} else {
-		args := []any{}
-		for _, arg := range expr.Arguments {
-			if arg, err := s.evaluate(arg); err != nil {
-				return nil, err
-			} else {
-				args = append(args, arg)
-			}
-		}
+	}
 
-		if c, ok := callee.(LoxCallable); !ok {
-			return nil, &RuntimeError{
-				Token:   expr.Paren,
-				Message: fmt.Sprintf("can only call functions and classes."),
-			}
-		} else if c.Arity() != len(args) {
-			return nil, &RuntimeError{
-				Token:   expr.Paren,
-				Message: fmt.Sprintf("expected %d arguments, got %d", c.Arity(), len(args)),
-			}
+	value, err := s.call(callee, args, expr.Paren)
+	recordAssertion(err, expr)
+	return value, err
+}
+
+// evaluateCall evaluates the callee and arguments of a call, checking that the callee can take them
+func (s *Interpreter) evaluateCall(expr *ast.CallExpr) (LoxCallable, []any, error) {
+	callee, err := s.evaluate(expr.Callee)
+	if err != nil {
+		return nil, nil, err
+	} else if callee == nil && expr.Optional {
+		return nil, nil, errNilChain
+	}
+
+	args := []any{}
+	for _, arg := range expr.Arguments {
+		if arg, err := s.evaluate(arg); err != nil {
+			return nil, nil, err
 		} else {
-			return c.Call(*s, args), nil
+			args = append(args, arg)
+		}
+	}
+
+	if c, ok := callee.(LoxCallable); !ok {
+		return nil, nil, &RuntimeError{
+			Token:   expr.Paren,
+			Message: fmt.Sprintf("can only call functions and classes."),
+		}
+	} else if c.Arity() >= 0 && c.Arity() != len(args) {
+		return nil, nil, &RuntimeError{
+			Token:   expr.Paren,
+			Message: fmt.Sprintf("expected %d arguments, got %d", c.Arity(), len(args)),
+		}
+	} else {
+		return c, args, nil
+	}
+}
+
+func (s *Interpreter) call(callee LoxCallable, args []any, paren lexer.Token) (any, error) {
+	value, err := callee.Call(s, args)
+	if err != nil {
+		// errors raised by lox code already carry a token, anything else came from a native
+		// function and is reported at the call site
+		if _, ok := err.(*RuntimeError); ok {
+			return nil, err
 		}
+		return nil, &RuntimeError{
+			Token:   paren,
+			Message: err.Error(),
+			Err:     err,
+		}
+	}
+	return value, nil
+}
+
+// spawn runs the call on its own goroutine with its own interpreter, sharing only the variables both can see
+func (s *Interpreter) VisitSpawnExpr(expr *ast.SpawnExpr) (any, error) {
+	callee, args, err := s.evaluateCall(expr.Call)
+	if err != nil {
+		return nil, err
+	}
+
+	task := newTask()
+	child := s.fork()
+	child.goroutines.tasks.Add(1)
+	go func() {
+		defer child.goroutines.tasks.Done()
+		value, err := child.call(callee, args, expr.Call.Paren)
+		task.finish(value, err)
+	}()
+	return task, nil
+}
+
+// fork creates the interpreter a spawned task runs on
+func (s *Interpreter) fork() *Interpreter {
+	if s.outputMu == nil {
+		s.output, s.outputMu = &s.Output, &sync.Mutex{}
+	}
+	group := s.group()
+
+	return &Interpreter{
+		Environment:      s.Environment,
+		Stdout:           s.Stdout,
+		Context:          group.ctx,
+		FS:               s.FS,
+		Loop:             s.Loop,
+		DisableTailCalls: s.DisableTailCalls,
+		MaxCallDepth:     s.MaxCallDepth,
+		Profiler:         s.Profiler,
+		Coverage:         s.Coverage,
+		output:           s.output,
+		outputMu:         s.outputMu,
+		goroutines:       group,
+	}
+}
+
+// Shutdown cancels the tasks the program spawned and never waited for, unwinds the generators it left
+// paused, and returns once all of their goroutines have. a program ends when its main code and timers
+// do, like a go program ends with main, so hosts call this before reading Output or reusing the
+// interpreter's values
+func (s *Interpreter) Shutdown() {
+	if s.goroutines == nil {
+		return
+	}
+	s.goroutines.cancel()
+	s.goroutines.tasks.Wait()
+
+	// with every task gone no generator is running, so each one left paused unwinds
+	close(s.goroutines.closing)
+	s.goroutines.generators.Wait()
+	s.goroutines = nil
+}
+
+func (s *Interpreter) VisitGetExpr(expr *ast.GetExpr) (any, error) {
+	object, err := s.evaluate(expr.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	if object == nil && expr.Optional {
+		return nil, errNilChain
+	}
+
+	if obj, ok := object.(LoxObject); ok {
+		return obj.Get(expr.Name)
+	}
 
+	return nil, &RuntimeError{
+		Token:   expr.Name,
+		Message: fmt.Sprintf("only objects have properties, got %s.", typeName(object)),
 	}
+}
 
+// errNilChain unwinds an optional chain like `a?.b.c()` from the link that found a nil receiver up to the
+// enclosing OptionalChainExpr, skipping everything in between
+var errNilChain = errors.New("nil receiver in optional chain")
+
+func (s *Interpreter) VisitOptionalChainExpr(expr *ast.OptionalChainExpr) (any, error) {
+	value, err := s.evaluate(expr.Expr)
+	if err == errNilChain {
+		return nil, nil
+	}
+	return value, err
 }
 
 func isTruthy(obj any) bool {
@@ -247,40 +558,120 @@ func checkNumberOperands(operator lexer.Token, left, right any) error {
 	}
 }
 
+// bitwise operators work on the 64 bit two's complement representation of integral numbers
+func evaluateBitwise(operator lexer.Token, op lexer.TokenType, left, right any) (any, error) {
+	l, r, err := checkIntegerOperands(operator, left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case lexer.AMPERSAND:
+		return float64(l & r), nil
+	case lexer.PIPE:
+		return float64(l | r), nil
+	case lexer.CARET:
+		return float64(l ^ r), nil
+	}
+
+	if r < 0 || r > 63 {
+		return nil, &RuntimeError{
+			Token:   operator,
+			Message: "shift count must be between 0 and 63.",
+		}
+	}
+	if op == lexer.LESS_LESS {
+		return float64(l << r), nil
+	}
+	// `>>` is an arithmetic shift, so negative numbers stay negative
+	return float64(l >> r), nil
+}
+
+func checkIntegerOperand(operator lexer.Token, operand any) (int64, error) {
+	if num, ok := operand.(float64); ok && num == math.Trunc(num) && num >= -(1<<63) && num < 1<<63 {
+		return int64(num), nil
+	}
+
+	return 0, &RuntimeError{
+		Token:   operator,
+		Message: "operand must be an integer.",
+	}
+}
+
+func checkIntegerOperands(operator lexer.Token, left, right any) (int64, int64, error) {
+	l, lErr := checkIntegerOperand(operator, left)
+	r, rErr := checkIntegerOperand(operator, right)
+	if lErr != nil || rErr != nil {
+		return 0, 0, &RuntimeError{
+			Token:   operator,
+			Message: "operands must be integers.",
+		}
+	}
+
+	return l, r, nil
+}
+
+func checkNonZeroDivisor(operator lexer.Token, divisor any) error {
+	if divisor.(float64) != 0 {
+		return nil
+	}
+
+	return &RuntimeError{
+		Token:   operator,
+		Message: "division by zero.",
+	}
+}
+
 type RuntimeError struct {
+	// Token is where the error happened, or noLocation for an error outside any statement
 	Token   lexer.Token
 	Message string
+	Err     error // the error returned by a native function, if that's where this came from
 }
 
 func (e *RuntimeError) Error() string {
 	return fmt.Sprintf("Operator: %s, Message: %s", e.Token.Lexeme, e.Message)
 }
 
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+// noLocation is the zero Token, whose Line of 0 tells reporters there is no line to show
+var noLocation = lexer.Token{}
+
 // StmtVisitor implementation below ----------------------------------------------------------------
 func (s *Interpreter) execute(stmt ast.Stmt) error {
+	if s.Coverage != nil {
+		s.Coverage.statement(stmt)
+	}
+	if s.Profiler != nil {
+		return s.executeProfiled(stmt)
+	}
 	return stmt.Accept(s)
 }
 
-// todo: this feels like it wont work
 func (s *Interpreter) VisitWhileStmt(stmt *ast.WhileStmt) error {
+	for {
+		if err := s.cancelled(stmt.Keyword); err != nil {
+			return err
+		}
 
-	if val, err := s.evaluate(stmt.Condition); err != nil {
-		return err
-	} else {
-		for isTruthy(val) {
-			if err := s.execute(stmt.Body); err != nil {
-				return err
-			}
+		// the condition is re-evaluated before every iteration
+		if val, err := s.evaluate(stmt.Condition); err != nil {
+			return err
+		} else if !s.branch(stmt, isTruthy(val)) {
+			return nil
 		}
-	}
 
-	return nil
+		if err := s.execute(stmt.Body); err != nil {
+			return err
+		}
+	}
 }
 
 func (s *Interpreter) VisitIfStmt(stmt *ast.IfStmt) error {
 	if val, err := s.evaluate(stmt.Condition); err != nil {
 		return err
-	} else if isTruthy(val) {
+	} else if s.branch(stmt, isTruthy(val)) {
 		if err := s.execute(stmt.ThenBranch); err != nil {
 			return err
 		}
@@ -297,23 +688,57 @@ func (s *Interpreter) VisitPrintStmt(stmt *ast.PrintStmt) error {
 	if val, err := s.evaluate(stmt.Expr); err != nil {
 		return err
 	} else {
-		fmt.Fprintf(os.Stdout, fmt.Sprintln(val))
-		fmt.Fprintf(&s.Output, fmt.Sprintln(val))
+		s.print(val)
 	}
 	return nil
 }
 
-func (s *Interpreter) VisitBlockStmt(stmt *ast.BlockStmt) error {
-	s.executeBlock(stmt.Stmts, NewEnvironment(&s.Environment))
+func (s *Interpreter) print(val any) {
+	output := s.output
+	if output == nil {
+		output = &s.Output
+	}
+	if s.outputMu != nil {
+		s.outputMu.Lock()
+		defer s.outputMu.Unlock()
+	}
+
+	stdout := s.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	fmt.Fprintln(stdout, val)
+	fmt.Fprintln(output, val)
+}
+
+func (s *Interpreter) maxCallDepth() int {
+	if s.MaxCallDepth > 0 {
+		return s.MaxCallDepth
+	}
+	return DefaultMaxCallDepth
+}
+
+// cancelled turns a cancelled Context into a runtime error at token
+func (s *Interpreter) cancelled(token lexer.Token) error {
+	if s.Context == nil {
+		return nil
+	}
+	if err := s.Context.Err(); err != nil {
+		return &RuntimeError{Token: token, Message: fmt.Sprintf("execution cancelled: %s.", err.Error()), Err: err}
+	}
 	return nil
 }
 
+func (s *Interpreter) VisitBlockStmt(stmt *ast.BlockStmt) error {
+	return s.executeBlock(stmt.Stmts, NewEnvironment(s.Environment))
+}
+
 func (s *Interpreter) executeBlock(stmts []ast.Stmt, env *Environment) error {
 	prev := s.Environment
 	defer func() { s.Environment = prev }()
 
 	// before executing these statements, replace the interpreters environment with the new
-	s.Environment = *env
+	s.Environment = env
 	for _, stmt := range stmts {
 		// TODO: error handling??
 		if err := s.execute(stmt); err != nil {
@@ -335,7 +760,7 @@ func (s *Interpreter) VisitExpressionStmt(stmt *ast.ExpressionStmt) error {
 func (s *Interpreter) VisitVariableDeclStmt(stmt *ast.VariableDeclarationStmt) error {
 	if stmt.Initializer != nil {
 		if value, err := s.evaluate(stmt.Initializer); err != nil {
-			return fmt.Errorf("error evaluating initializer expression: %v", err)
+			return err
 		} else {
 			s.Environment.Define(stmt.Name.Lexeme, value)
 		}
@@ -347,9 +772,160 @@ func (s *Interpreter) VisitVariableDeclStmt(stmt *ast.VariableDeclarationStmt) e
 }
 
 func (s *Interpreter) VisitFunctionStmt(stmt *ast.FunctionStmt) error {
-	function := NewLoxFunction(*stmt)
+	function := NewLoxFunction(*stmt, s.Environment)
 
 	s.Environment.Define(stmt.Name.Lexeme, function)
 
 	return nil
 }
+
+// returnSignal unwinds every statement between a `return` and the LoxFunction.Call that is running it
+type returnSignal struct {
+	Value any
+}
+
+func (r *returnSignal) Error() string { return "return outside of a function" }
+
+// tailCall asks the LoxFunction.Call running the current function to run Function next, in its own Go
+// frame, instead of growing the stack with a nested call. see LoxFunction.Call
+type tailCall struct {
+	Function  *LoxFunction
+	Arguments []any
+}
+
+func (t *tailCall) Error() string { return "tail call outside of a function" }
+
+func (s *Interpreter) VisitReturnStmt(stmt *ast.ReturnStmt) error {
+	// `return f(...)` is the last thing the function does, so its frame can be reused for f
+	if call, ok := stmt.Value.(*ast.CallExpr); ok && !call.Optional && !s.DisableTailCalls {
+		callee, args, err := s.evaluateCall(call)
+		if err != nil {
+			return err
+		}
+		if function, ok := callee.(*LoxFunction); ok {
+			return &tailCall{Function: function, Arguments: args}
+		}
+
+		value, err := s.call(callee, args, call.Paren)
+		if err != nil {
+			recordAssertion(err, call)
+			return err
+		}
+		return &returnSignal{Value: value}
+	}
+
+	var value any
+	if stmt.Value != nil {
+		var err error
+		if value, err = s.evaluate(stmt.Value); err != nil {
+			return err
+		}
+	}
+
+	return &returnSignal{Value: value}
+}
+
+// bad statements and expressions only exist in programs with syntax errors, which are never run.
+// reaching one means a caller skipped the parser's diagnostics
+func (s *Interpreter) VisitBadStmt(stmt *ast.BadStmt) error {
+	return &RuntimeError{Token: stmt.From, Message: "can't run code with syntax errors."}
+}
+
+func (s *Interpreter) VisitBadExpr(expr *ast.BadExpr) (any, error) {
+	return nil, &RuntimeError{Token: expr.From, Message: "can't run code with syntax errors."}
+}
+
+// tests only run when asked for by RunTest
+func (s *Interpreter) VisitTestStmt(stmt *ast.TestStmt) error {
+	return nil
+}
+
+// RunTest runs the body of a test in its own scope on top of the current environment. `return` ends
+// the test early
+func (s *Interpreter) RunTest(test *ast.TestStmt) *RuntimeError {
+	err := s.executeBlock(test.Body, NewEnvironment(s.Environment))
+	if tail, ok := err.(*tailCall); ok {
+		_, err = tail.Function.Call(s, tail.Arguments)
+	}
+
+	switch err.(type) {
+	case nil, *returnSignal:
+		return nil
+	default:
+		return asRuntimeError(err)
+	}
+}
+
+func (s *Interpreter) VisitYieldStmt(stmt *ast.YieldStmt) error {
+	var value any
+	if stmt.Value != nil {
+		var err error
+		if value, err = s.evaluate(stmt.Value); err != nil {
+			return err
+		}
+	}
+
+	// the parser makes every function containing a yield a generator, so this can only be nil if the
+	// statement was executed outside of any function
+	if s.generator == nil {
+		return &RuntimeError{Token: stmt.Keyword, Message: "can't yield outside of a generator."}
+	}
+	return s.generator.yield(value)
+}
+
+func (s *Interpreter) VisitMatchStmt(stmt *ast.MatchStmt) error {
+	subject, err := s.evaluate(stmt.Subject)
+	if err != nil {
+		return err
+	}
+
+	for _, matchCase := range stmt.Cases {
+		// every case gets a fresh scope for its bindings, visible to the guard and the body
+		env := NewEnvironment(s.Environment)
+		if !matchPatterns(matchCase.Patterns, subject, env) {
+			continue
+		}
+
+		if matchCase.Guard != nil {
+			if guard, err := s.evaluateIn(matchCase.Guard, env); err != nil {
+				return err
+			} else if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return s.executeBlock([]ast.Stmt{matchCase.Body}, env)
+	}
+
+	return &RuntimeError{
+		Token:   stmt.Keyword,
+		Message: fmt.Sprintf("no case matched %s.", stringifyElement(subject)),
+	}
+}
+
+// matchPatterns reports whether any pattern matches value, defining the matching pattern's binding in env
+func matchPatterns(patterns []ast.MatchPattern, value any, env *Environment) bool {
+	for _, pattern := range patterns {
+		switch pattern.Kind {
+		case ast.WildcardPattern:
+			return true
+		case ast.BindingPattern:
+			env.Define(pattern.Token.Lexeme, value)
+			return true
+		case ast.LiteralPattern:
+			if isEqual(pattern.Literal.Value, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// evaluateIn evaluates expr with env as the current environment
+func (s *Interpreter) evaluateIn(expr ast.Expr, env *Environment) (any, error) {
+	prev := s.Environment
+	defer func() { s.Environment = prev }()
+
+	s.Environment = env
+	return s.evaluate(expr)
+}
@@ -12,7 +12,7 @@ import (
 
 func TestInterpreter(t *testing.T) {
 
-	source := "a = 1;"
+	source := "var a; a = 1;"
 	tokens := lexer.NewScanner(source).ScanTokens()
 	stmts := ast.NewParser(tokens).Parse()
 
@@ -71,7 +71,6 @@ func TestVariableDecl(t *testing.T) {
 
 }
 
-// TODO: parser is spinning forever on the scope test case
 func TestAssignmentExpr(t *testing.T) {
 	// assign a number to a variable
 	source := "var a; a = 1234;"
@@ -88,7 +87,7 @@ func TestAssignmentExpr(t *testing.T) {
 	assert.Equal(t, float64(1234), value)
 
 	// reassignment
-	source = "var a \"before\"; a = \"after\"; print a;"
+	source = "var a = \"before\"; a = \"after\"; print a;"
 	tokens = lexer.NewScanner(source).ScanTokens()
 	stmts = ast.NewParser(tokens).Parse()
 
@@ -100,7 +99,7 @@ func TestAssignmentExpr(t *testing.T) {
 	assert.Equal(t, "after\n", output)
 
 	// scope
-	source = "{var a \"first\"; print a;} {var a \"second\"; print a;}"
+	source = "{var a = \"first\"; print a;} {var a = \"second\"; print a;}"
 	tokens = lexer.NewScanner(source).ScanTokens()
 	p := ast.NewParser(tokens)
 	stmts = p.Parse()
@@ -110,6 +109,6 @@ func TestAssignmentExpr(t *testing.T) {
 	err = i.Interpret(stmts)
 	assert.Nil(t, err)
 	output = i.Output.String()
-	assert.Equal(t, "first\n second\n", output)
+	assert.Equal(t, "first\nsecond\n", output)
 
 }
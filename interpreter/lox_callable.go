@@ -1,6 +1,6 @@
 package interpreter
 
 type LoxCallable interface {
-	Call(interpreter Interpreter, arguments []any) any
+	Call(interpreter *Interpreter, arguments []any) (any, error)
 	Arity() int
 }
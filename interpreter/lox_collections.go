@@ -0,0 +1,293 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+// LoxList is a growable, zero indexed sequence of runtime values. it is safe to share between spawned
+// tasks: each call on it is atomic, but `l.set(0, l.get(0) + 1)` still races with another task
+type LoxList struct {
+	items []any
+
+	mu sync.RWMutex
+}
+
+func NewLoxList(items []any) *LoxList {
+	if items == nil {
+		items = []any{}
+	}
+	return &LoxList{items: items}
+}
+
+func (l *LoxList) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.items)
+}
+
+// Values returns a copy of the items, so callers can range over them while other tasks change the list
+func (l *LoxList) Values() []any {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	values := make([]any, len(l.items))
+	copy(values, l.items)
+	return values
+}
+
+// Append adds value to the end of the list and returns the new length
+func (l *LoxList) Append(value any) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = append(l.items, value)
+	return len(l.items)
+}
+
+func (l *LoxList) String() string { return l.format(map[any]bool{}) }
+
+// format prints the list, showing `[...]` where it contains itself
+func (l *LoxList) format(visiting map[any]bool) string {
+	if visiting[l] {
+		return "[...]"
+	}
+	visiting[l] = true
+	defer delete(visiting, l)
+
+	items := l.Values()
+	parts := make([]string, len(items))
+	for idx, item := range items {
+		parts[idx] = formatElement(item, visiting)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func (l *LoxList) Get(name lexer.Token) (any, error) {
+	switch name.Lexeme {
+	case "length":
+		return float64(l.Len()), nil
+	case "get":
+		return &NativeFunction{Name: "get", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			l.mu.RLock()
+			defer l.mu.RUnlock()
+			idx, err := l.index("get", args)
+			if err != nil {
+				return nil, err
+			}
+			return l.items[idx], nil
+		}}, nil
+	case "set":
+		return &NativeFunction{Name: "set", ArgCount: 2, Fn: func(i *Interpreter, args []any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			idx, err := l.index("set", args)
+			if err != nil {
+				return nil, err
+			}
+			l.items[idx] = args[1]
+			return args[1], nil
+		}}, nil
+	case "push":
+		return &NativeFunction{Name: "push", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			return float64(l.Append(args[0])), nil
+		}}, nil
+	case "pop":
+		return &NativeFunction{Name: "pop", ArgCount: 0, Fn: func(i *Interpreter, args []any) (any, error) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if len(l.items) == 0 {
+				return nil, fmt.Errorf("pop: list is empty.")
+			}
+			last := l.items[len(l.items)-1]
+			l.items = l.items[:len(l.items)-1]
+			return last, nil
+		}}, nil
+	}
+
+	return nil, undefinedProperty(name)
+}
+
+// index checks the index argument of get and set against the list, which the caller has locked
+func (l *LoxList) index(fn string, args []any) (int, error) {
+	idx, err := intArg(fn, args, 0)
+	if err != nil {
+		return 0, err
+	}
+	if idx < 0 || idx >= len(l.items) {
+		return 0, fmt.Errorf("%s: index %d out of bounds for list of length %d.", fn, idx, len(l.items))
+	}
+	return idx, nil
+}
+
+// LoxMap maps string keys to runtime values, remembering the order keys were first inserted in. like
+// LoxList, each call on it is atomic
+type LoxMap struct {
+	keys   []string
+	values map[string]any
+
+	mu sync.RWMutex
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{
+		keys:   []string{},
+		values: map[string]any{},
+	}
+}
+
+// Keys returns a copy of the keys in insertion order
+func (m *LoxMap) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+func (m *LoxMap) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.keys)
+}
+
+func (m *LoxMap) Lookup(key string) (any, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func (m *LoxMap) Set(key string, value any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *LoxMap) Remove(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; !ok {
+		return false
+	}
+	delete(m.values, key)
+	for idx, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:idx], m.keys[idx+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (m *LoxMap) String() string { return m.format(map[any]bool{}) }
+
+// format prints the map, showing `{...}` where it contains itself
+func (m *LoxMap) format(visiting map[any]bool) string {
+	if visiting[m] {
+		return "{...}"
+	}
+	visiting[m] = true
+	defer delete(visiting, m)
+
+	keys := m.Keys()
+	parts := make([]string, len(keys))
+	for idx, key := range keys {
+		value, _ := m.Lookup(key)
+		parts[idx] = fmt.Sprintf("%q: %s", key, formatElement(value, visiting))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func (m *LoxMap) Get(name lexer.Token) (any, error) {
+	switch name.Lexeme {
+	case "length":
+		return float64(m.Len()), nil
+	case "get":
+		// get(key) returns the value stored under key, or nil
+		return &NativeFunction{Name: "get", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			key, err := stringArg("get", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			value, _ := m.Lookup(key)
+			return value, nil
+		}}, nil
+	case "set":
+		return &NativeFunction{Name: "set", ArgCount: 2, Fn: func(i *Interpreter, args []any) (any, error) {
+			key, err := stringArg("set", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			m.Set(key, args[1])
+			return args[1], nil
+		}}, nil
+	case "has":
+		return &NativeFunction{Name: "has", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			key, err := stringArg("has", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			_, ok := m.Lookup(key)
+			return ok, nil
+		}}, nil
+	case "remove":
+		// remove(key) deletes key, reporting whether it was present
+		return &NativeFunction{Name: "remove", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			key, err := stringArg("remove", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return m.Remove(key), nil
+		}}, nil
+	case "keys":
+		return &NativeFunction{Name: "keys", ArgCount: 0, Fn: func(i *Interpreter, args []any) (any, error) {
+			mapKeys := m.Keys()
+			keys := make([]any, len(mapKeys))
+			for idx, key := range mapKeys {
+				keys[idx] = key
+			}
+			return NewLoxList(keys), nil
+		}}, nil
+	}
+
+	return nil, undefinedProperty(name)
+}
+
+// stringifyElement formats values nested inside a collection, quoting strings so `["1"]` and `[1]` print differently
+func stringifyElement(value any) string {
+	return formatElement(value, map[any]bool{})
+}
+
+// formatElement is stringifyElement for collections already being printed, which are listed in visiting
+// so cycles through them end instead of recursing forever
+func formatElement(value any, visiting map[any]bool) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case *LoxList:
+		return v.format(visiting)
+	case *LoxMap:
+		return v.format(visiting)
+	}
+	return fmt.Sprint(value)
+}
+
+func defineCollectionNatives(env *Environment) {
+	// list(items...) builds a list out of its arguments
+	defineNative(env, "list", -1, func(i *Interpreter, args []any) (any, error) {
+		items := make([]any, len(args))
+		copy(items, args)
+		return NewLoxList(items), nil
+	})
+	// dict() builds an empty map
+	defineNative(env, "dict", 0, func(i *Interpreter, args []any) (any, error) {
+		return NewLoxMap(), nil
+	})
+}
@@ -1,6 +1,7 @@
 package interpreter
 
 import (
+	"errors"
 	"fmt"
 
 	ast "github.com/brandonshearin/go-lox/parser"
@@ -9,26 +10,69 @@ import (
 // implements LoxCallable
 type LoxFunction struct {
 	Declaration ast.FunctionStmt
+	Closure     *Environment // the environment the function was declared in
 }
 
-func NewLoxFunction(decl ast.FunctionStmt) *LoxFunction {
+func NewLoxFunction(decl ast.FunctionStmt, closure *Environment) *LoxFunction {
 	return &LoxFunction{
 		Declaration: decl,
+		Closure:     closure,
 	}
 }
 
-func (s *LoxFunction) Call(interpreter Interpreter, arguments []any) any {
-	env := NewEnvironment(&interpreter.Environment)
+// DefaultMaxCallDepth bounds how many calls can be running at once, unless an Interpreter's MaxCallDepth
+// says otherwise. every call nests the Go calls that run it, and running out of Go stack kills the
+// process instead of failing the program. tail calls on the trampoline don't count, they reuse the call
+// that made them
+const DefaultMaxCallDepth = 10000
 
-	for i, param := range s.Declaration.Params {
-		env.Define(param.Lexeme, arguments[i])
-	}
+var errStackOverflow = errors.New("Stack overflow.")
 
-	if err := interpreter.executeBlock(s.Declaration.Body, env); err != nil {
-		return err
+// Call runs the function as a trampoline: a tail call from its body hands back the next function to
+// run instead of calling it, and it runs here, so tail recursion of any depth uses constant Go stack
+func (s *LoxFunction) Call(interpreter *Interpreter, arguments []any) (any, error) {
+	if interpreter.callDepth >= interpreter.maxCallDepth() {
+		return nil, errStackOverflow
 	}
+	interpreter.callDepth++
+	defer func() { interpreter.callDepth-- }()
+
+	function := s
+	for {
+		if err := interpreter.cancelled(function.Declaration.Name); err != nil {
+			return nil, err
+		}
+
+		// calling a generator function only creates the generator, its body runs as values are asked for
+		if function.Declaration.Generator {
+			return newGenerator(interpreter, function, arguments), nil
+		}
+
+		env := NewEnvironment(function.Closure)
 
-	return nil
+		for i, param := range function.Declaration.Params {
+			env.Define(param.Lexeme, arguments[i])
+		}
+
+		if interpreter.Profiler != nil {
+			interpreter.enterProfiled(function)
+		}
+		err := interpreter.executeBlock(function.Declaration.Body, env)
+		if interpreter.Profiler != nil {
+			interpreter.exitProfiled()
+		}
+
+		switch signal := err.(type) {
+		case nil:
+			return nil, nil
+		case *returnSignal:
+			return signal.Value, nil
+		case *tailCall:
+			function, arguments = signal.Function, signal.Arguments
+		default:
+			return nil, signal
+		}
+	}
 }
 
 func (s *LoxFunction) Arity() int {
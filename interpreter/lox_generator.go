@@ -0,0 +1,195 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+// generatorEvent is what a generator's goroutine hands back each time it pauses: a yielded value, or
+// done with the error that ended it, if any
+type generatorEvent struct {
+	value any
+	done  bool
+	err   error
+}
+
+// Generator is returned by calling a generator function. its body runs in its own goroutine on an
+// interpreter of its own, but only ever while a consumer waits on it in next() or done(). it belongs to
+// the task that created it, so no other task may resume it. a generator that is dropped before it
+// finishes is unwound once the garbage collector finds it, or when the program shuts down, whichever
+// comes first
+type Generator struct {
+	// the body's goroutine only holds the state, never the Generator, so a paused body doesn't keep the
+	// program's handle on it alive
+	*generatorState
+}
+
+type generatorState struct {
+	owner     *Interpreter
+	function  *LoxFunction
+	arguments []any
+
+	resume chan struct{}
+	events chan generatorEvent
+	// closed once the Generator is garbage collected
+	abandoned chan struct{}
+	// the interpreter the body runs on and the goroutines of the program that started it, nil until it starts
+	body  *Interpreter
+	group *goroutineGroup
+
+	started  bool
+	running  bool
+	finished bool
+	// done() has to run the body ahead to the next yield to answer, the value waits here for next()
+	buffered bool
+	value    any
+}
+
+func newGenerator(owner *Interpreter, function *LoxFunction, arguments []any) *Generator {
+	g := &Generator{&generatorState{
+		owner:     owner.task(),
+		function:  function,
+		arguments: arguments,
+		resume:    make(chan struct{}),
+		events:    make(chan generatorEvent),
+		abandoned: make(chan struct{}),
+	}}
+	runtime.SetFinalizer(g, func(g *Generator) { close(g.abandoned) })
+	return g
+}
+
+func (g *Generator) String() string {
+	return fmt.Sprintf("<generator %s>", g.function.Declaration.Name.Lexeme)
+}
+
+func (g *Generator) Get(name lexer.Token) (any, error) {
+	switch name.Lexeme {
+	case "next":
+		// next() returns the next yielded value, or nil once the generator is done
+		return &NativeFunction{Name: "next", ArgCount: 0, Fn: func(i *Interpreter, args []any) (any, error) {
+			if err := g.fill(i); err != nil || g.finished {
+				return nil, err
+			}
+			g.buffered = false
+			return g.value, nil
+		}}, nil
+	case "done":
+		// done() reports whether next() has anything left to return
+		return &NativeFunction{Name: "done", ArgCount: 0, Fn: func(i *Interpreter, args []any) (any, error) {
+			if err := g.fill(i); err != nil {
+				return nil, err
+			}
+			return g.finished, nil
+		}}, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+// task is the interpreter of the task s runs in, the generators it resumes run on interpreters of their own
+func (s *Interpreter) task() *Interpreter {
+	for s.generator != nil {
+		s = s.generator.owner
+	}
+	return s
+}
+
+// fill runs the body until it yields the next value into the buffer, or finishes
+func (g *generatorState) fill(i *Interpreter) error {
+	if i.task() != g.owner {
+		return errors.New("generator belongs to another task.")
+	}
+	if g.buffered || g.finished {
+		return nil
+	}
+	if g.running {
+		return errors.New("generator is already running.")
+	}
+
+	g.running = true
+	if i.Profiler != nil {
+		defer i.resumeProfiled()()
+	}
+
+	if g.started {
+		select {
+		case g.resume <- struct{}{}:
+		case <-g.group.closing:
+			g.running, g.finished = false, true
+			return errGeneratorClosed
+		}
+	} else {
+		g.started = true
+		// the body starts from its closure, the consumer's environment may be all that holds the Generator
+		g.body = i.fork()
+		g.body.Environment, g.body.generator = g.function.Closure, g
+		g.group = g.body.goroutines
+		g.group.generators.Add(1)
+		go g.run()
+	}
+	event := <-g.events
+	g.running = false
+
+	if event.done {
+		g.finished = true
+		return event.err
+	}
+	g.buffered, g.value = true, event.value
+	return nil
+}
+
+// errGeneratorClosed unwinds the body of a generator that was paused when it was dropped or its program
+// shut down
+var errGeneratorClosed = errors.New("generator was closed before it finished.")
+
+// run executes the body on the generator's goroutine
+func (g *generatorState) run() {
+	defer g.group.generators.Done()
+	i := g.body
+
+	env := NewEnvironment(g.function.Closure)
+	for idx, param := range g.function.Declaration.Params {
+		env.Define(param.Lexeme, g.arguments[idx])
+	}
+
+	if i.Profiler != nil {
+		i.enterProfiled(g.function)
+	}
+	err := i.executeBlock(g.function.Declaration.Body, env)
+	if errors.Is(err, errGeneratorClosed) {
+		// nobody is waiting for the body to finish
+		return
+	}
+	if i.Profiler != nil {
+		i.exitProfiled()
+	}
+
+	switch signal := err.(type) {
+	case *returnSignal:
+		// a generator's return value has nowhere to go
+		err = nil
+	case *tailCall:
+		_, err = signal.Function.Call(i, signal.Arguments)
+	}
+
+	g.events <- generatorEvent{done: true, err: err}
+}
+
+// yield pauses the body until the consumer asks for another value, or fails with errGeneratorClosed
+// once nobody can. the body unwinds on its own interpreter, so it can do so while its owner runs
+func (g *generatorState) yield(value any) error {
+	if g.body.Profiler != nil {
+		defer g.body.pauseProfiled()()
+	}
+
+	g.events <- generatorEvent{value: value}
+	select {
+	case <-g.resume:
+		return nil
+	case <-g.group.closing:
+	case <-g.abandoned:
+	}
+	return errGeneratorClosed
+}
@@ -0,0 +1,19 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+// LoxObject is implemented by runtime values that expose properties through `object.name` access
+type LoxObject interface {
+	Get(name lexer.Token) (any, error)
+}
+
+func undefinedProperty(name lexer.Token) error {
+	return &RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("undefined property '%s'.", name.Lexeme),
+	}
+}
@@ -0,0 +1,63 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const router = `
+fun route(value) {
+	match (value) {
+		case 1, 2 => print "one or two";
+		case "x" => print "the letter x";
+		case true, false => print "a boolean";
+		case nil => print "nothing";
+		case n if n > 10 => print "big " + upper("number");
+		case -5 => print "minus five";
+		case _ => print "something else";
+	}
+}
+`
+
+var matchCases = []NativeTestCase{
+	{ID: 1, Source: router + `route(1); route(2);`, Expected: "one or two\none or two\n"},
+	{ID: 2, Source: router + `route("x"); route(false); route(nil);`, Expected: "the letter x\na boolean\nnothing\n"},
+	{ID: 3, Source: router + `route(11); route(-5); route(3);`, Expected: "big NUMBER\nminus five\nsomething else\n"},
+	// bindings live in a fresh scope visible to the guard and the body
+	{ID: 4, Source: `var n = "outer"; match (42) { case n if n > 40 => print n; } print n;`, Expected: "42\nouter\n"},
+	// a failed guard falls through to the next case
+	{ID: 5, Source: `match (3) { case n if n > 5 => print "big"; case n => print n * 2; }`, Expected: "6\n"},
+	// only the first matching case runs, and the subject is evaluated once
+	{ID: 6, Source: `var count = 0; match (count++) { case 0 => print "zero"; case _ => print "other"; } print count;`, Expected: "zero\n1\n"},
+	{ID: 7, Source: `match ("a") { case "a" => { var local = 1; print local; } }`, Expected: "1\n"},
+}
+
+func TestMatchStmt(t *testing.T) {
+	for _, testCase := range matchCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+func TestMatchStmtErrors(t *testing.T) {
+	_, err := interpretSource(`match ("nope") { case 1 => print 1; case n if n == "x" => print n; }`)
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, `no case matched "nope".`, err.Message)
+		assert.Equal(t, "match", err.Token.Lexeme)
+	}
+
+	// errors in guards and bodies propagate
+	_, err = interpretSource(`match (1) { case n if n > "a" => print n; }`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "operands must be numbers.", err.Message)
+	}
+
+	_, err = interpretSource(`match (1) { case 1 => print -"a"; }`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "operand must be a number.", err.Message)
+	}
+}
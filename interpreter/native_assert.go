@@ -0,0 +1,156 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+
+	ast "github.com/brandonshearin/go-lox/parser"
+)
+
+// AssertionError is the error a failed assert or assertEqual stops the program with. it reaches the
+// caller wrapped in a RuntimeError whose Message is this error's
+type AssertionError struct {
+	// Call is the assert or assertEqual call that failed, without assert's message
+	Call *ast.CallExpr
+	// Source is Call as it would be formatted, like `assertEqual(add(1, 2), 4)`. formatting is up to the
+	// host, see QuoteAssertion, until then the message only names the native
+	Source string
+	// Message is the optional message given to assert
+	Message string
+
+	// Got and Want are assertEqual's arguments, Equality reports whether they were given at all
+	Got, Want any
+	Equality  bool
+}
+
+func (e *AssertionError) Error() string {
+	source := e.Source
+	if source == "" {
+		source = "assert"
+		if e.Equality {
+			source = "assertEqual"
+		}
+	}
+
+	if e.Equality {
+		return fmt.Sprintf("assertion failed: %s: got %s, want %s.", source, stringifyElement(e.Got), stringifyElement(e.Want))
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("assertion failed: %s: %s", source, e.Message)
+	}
+	return fmt.Sprintf("assertion failed: %s.", source)
+}
+
+// recordAssertion fills in the call an assertion failed in, which only the interpreter knows
+func recordAssertion(err error, call *ast.CallExpr) {
+	var failure *AssertionError
+	if !errors.As(err, &failure) || failure.Call != nil {
+		return
+	}
+
+	// assert's message is reported on its own
+	if !failure.Equality && len(call.Arguments) > 1 {
+		condition := *call
+		condition.Arguments = call.Arguments[:1]
+		call = &condition
+	}
+	failure.Call = call
+}
+
+// QuoteAssertion rewrites the message of a failed assertion to quote the call that failed, printed with
+// format. hosts pass formatter.Expr, which the interpreter leaves to them. any other error is left alone
+func QuoteAssertion(err *RuntimeError, format func(ast.Expr) string) {
+	var failure *AssertionError
+	if err == nil || !errors.As(err, &failure) || failure.Call == nil {
+		return
+	}
+	failure.Source = format(failure.Call)
+	err.Message = failure.Error()
+}
+
+func defineAssertNatives(env *Environment) {
+	defineNative(env, "assert", -1, nativeAssert)
+	defineNative(env, "assertEqual", 2, nativeAssertEqual)
+}
+
+// assert(cond, message?) fails when cond is falsey
+func nativeAssert(i *Interpreter, args []any) (any, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("assert: expected 1 or 2 arguments but got %d.", len(args))
+	}
+
+	failure := &AssertionError{}
+	if len(args) == 2 {
+		message, err := stringArg("assert", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		failure.Message = message
+	}
+
+	if !isTruthy(args[0]) {
+		return nil, failure
+	}
+	return nil, nil
+}
+
+// assertEqual(got, want) fails unless got and want are equal. lists and maps are compared by contents
+func nativeAssertEqual(i *Interpreter, args []any) (any, error) {
+	if !deepEqual(args[0], args[1]) {
+		return nil, &AssertionError{Got: args[0], Want: args[1], Equality: true}
+	}
+	return nil, nil
+}
+
+// deepEqual is `==` extended to compare lists item by item and maps key by key, ignoring key order
+func deepEqual(left, right any) bool {
+	return deepEqualVisiting(left, right, map[[2]any]bool{})
+}
+
+// deepEqualVisiting carries the (left, right) pairs of collections already being compared. a pair met
+// again is part of a cycle and is taken to be equal, the rest of the comparison decides the result
+func deepEqualVisiting(left, right any, visiting map[[2]any]bool) bool {
+	switch l := left.(type) {
+	case *LoxList:
+		r, ok := right.(*LoxList)
+		if !ok {
+			return false
+		}
+		pair := [2]any{l, r}
+		if l == r || visiting[pair] {
+			return true
+		}
+		visiting[pair] = true
+
+		leftItems, rightItems := l.Values(), r.Values()
+		if len(leftItems) != len(rightItems) {
+			return false
+		}
+		for idx := range leftItems {
+			if !deepEqualVisiting(leftItems[idx], rightItems[idx], visiting) {
+				return false
+			}
+		}
+		return true
+	case *LoxMap:
+		r, ok := right.(*LoxMap)
+		if !ok || l.Len() != r.Len() {
+			return false
+		}
+		pair := [2]any{l, r}
+		if l == r || visiting[pair] {
+			return true
+		}
+		visiting[pair] = true
+
+		for _, key := range l.Keys() {
+			mine, _ := l.Lookup(key)
+			value, found := r.Lookup(key)
+			if !found || !deepEqualVisiting(mine, value, visiting) {
+				return false
+			}
+		}
+		return true
+	}
+	return isEqual(left, right)
+}
@@ -0,0 +1,97 @@
+package interpreter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/formatter"
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertNatives(t *testing.T) {
+	i, err := interpretSource(`
+assert(true);
+assert(1, "numbers are truthy");
+assertEqual(1 + 1, 2);
+assertEqual(list(1, list("a")), list(1, list("a")));
+var a = dict(); a.set("x", 1); a.set("y", nil);
+var b = dict(); b.set("y", nil); b.set("x", 1);
+assertEqual(a, b);
+var c = list(1); c.push(c);
+var d = list(1); d.push(d);
+assertEqual(c, d);
+assertEqual(c, c);
+var e = dict(); e.set("self", e);
+var f = dict(); f.set("self", f);
+assertEqual(e, f);
+print "passed";`)
+	assert.Nil(t, err)
+	assert.Equal(t, "passed\n", i.Output.String())
+
+	cases := []struct {
+		ID      int
+		Source  string
+		Message string
+		Line    int
+	}{
+		{ID: 1, Source: `var x = 1; assert(x > 1);`, Message: "assertion failed: assert(x > 1).", Line: 1},
+		{ID: 2, Source: `assert(nil, "must be set");`, Message: "assertion failed: assert(nil): must be set", Line: 1},
+		{ID: 3, Source: `fun add(a, b) { return a - b; }` + "\n" + `assertEqual(add(1, 2), 3);`, Message: "assertion failed: assertEqual(add(1, 2), 3): got -1, want 3.", Line: 2},
+		{ID: 4, Source: `assertEqual("1", 1);`, Message: `assertion failed: assertEqual("1", 1): got "1", want 1.`, Line: 1},
+		{ID: 5, Source: `assertEqual(list(1, 2), list(1));`, Message: "assertion failed: assertEqual(list(1, 2), list(1)): got [1, 2], want [1].", Line: 1},
+		// a failure inside a helper quotes the assertion, not the call to the helper
+		{ID: 6, Source: "fun check(v) {\n  assert(v);\n}\ncheck(false);", Message: "assertion failed: assert(v).", Line: 2},
+		{ID: 7, Source: `fun check() { return assertEqual(1, 2); } check();`, Message: "assertion failed: assertEqual(1, 2): got 1, want 2.", Line: 1},
+		{ID: 8, Source: `assert();`, Message: "assert: expected 1 or 2 arguments but got 0.", Line: 1},
+		{ID: 9, Source: `assert(false, 1);`, Message: "assert: argument 2 must be a string, got number.", Line: 1},
+		{ID: 10, Source: `var a = list(1); a.push(a); var b = list(2); b.push(b); assertEqual(a, b);`, Message: "assertion failed: assertEqual(a, b): got [1, [...]], want [2, [...]].", Line: 1},
+	}
+
+	// hosts quote the failed call with the formatter
+	for _, testCase := range cases {
+		_, err := interpretSource(testCase.Source)
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			QuoteAssertion(err, formatter.Expr)
+			assert.Equal(t, testCase.Message, err.Message, "test case %d failed", testCase.ID)
+			assert.Equal(t, testCase.Line, err.Token.Line, "test case %d failed", testCase.ID)
+		}
+	}
+
+	// until then the message only names the native
+	_, err = interpretSource(`assertEqual(1, 2);`)
+	var failure *AssertionError
+	if assert.True(t, errors.As(err, &failure)) {
+		assert.Equal(t, "assertion failed: assertEqual: got 1, want 2.", err.Message)
+		assert.Equal(t, "assertEqual", failure.Call.Callee.(*ast.VariableExpr).Name.Lexeme)
+		QuoteAssertion(err, formatter.Expr)
+		assert.Equal(t, "assertEqual(1, 2)", failure.Source)
+		assert.Equal(t, 1.0, failure.Got)
+		assert.Equal(t, 2.0, failure.Want)
+	}
+}
+
+func TestRunTest(t *testing.T) {
+	stmts := ast.NewParser(lexer.NewScanner(`
+var total = 0;
+fun add(n) { total = total + n; return total; }
+test "returns early" { add(1); return; add(100); }
+test "tail call" { return add(10); }
+test "fails" { assertEqual(total, 0); }`).ScanTokens()).Parse()
+
+	i := NewInterpreter()
+	// running the file normally skips its tests
+	assert.Nil(t, i.Interpret(stmts))
+
+	assert.Nil(t, i.RunTest(stmts[2].(*ast.TestStmt)))
+	assert.Nil(t, i.RunTest(stmts[3].(*ast.TestStmt)))
+	total, _ := i.Environment.Get(lexer.Token{Lexeme: "total"})
+	assert.Equal(t, 11.0, total)
+
+	err := i.RunTest(stmts[4].(*ast.TestStmt))
+	if assert.NotNil(t, err) {
+		QuoteAssertion(err, formatter.Expr)
+		assert.Equal(t, "assertion failed: assertEqual(total, 0): got 11, want 0.", err.Message)
+	}
+}
@@ -0,0 +1,170 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+// values cross between tasks as they are. lists and maps lock around each call on them, while a
+// generator can only be resumed by the task that created it
+func defineConcurrencyNatives(env *Environment) {
+	defineNative(env, "chan", -1, nativeChan)
+	defineNative(env, "wait", 1, nativeWait)
+	defineNative(env, "select", -1, nativeSelect)
+}
+
+// Task is the handle `spawn` returns for the call it started
+type Task struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+func newTask() *Task {
+	return &Task{done: make(chan struct{})}
+}
+
+func (t *Task) String() string { return "<task>" }
+
+func (t *Task) finish(value any, err error) {
+	t.value, t.err = value, err
+	close(t.done)
+}
+
+// wait(task) blocks until the task finishes and returns what its call returned. an error that ended the
+// task is raised again by every wait on it, at the line the task failed on
+func nativeWait(i *Interpreter, args []any) (any, error) {
+	task, ok := args[0].(*Task)
+	if !ok {
+		return nil, fmt.Errorf("wait: argument 1 must be a task, got %s.", typeName(args[0]))
+	}
+	select {
+	case <-task.done:
+		return task.value, task.err
+	case <-cancelSignal(i):
+		return nil, cancelledError(i)
+	}
+}
+
+// cancelSignal is closed once the interpreter's Context is cancelled. without a Context it is nil, which
+// is never ready, so natives can block on it next to their channel either way
+func cancelSignal(i *Interpreter) <-chan struct{} {
+	if i.Context == nil {
+		return nil
+	}
+	return i.Context.Done()
+}
+
+// cancelledError is what a native blocked on a channel or task returns once cancelSignal fires
+func cancelledError(i *Interpreter) error {
+	return fmt.Errorf("execution cancelled: %w.", i.Context.Err())
+}
+
+// chan(capacity?) makes a channel that holds up to capacity values before send blocks, unbuffered by default
+func nativeChan(i *Interpreter, args []any) (any, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("chan: expected 0 or 1 arguments, got %d.", len(args))
+	}
+	capacity := 0
+	if len(args) == 1 {
+		var err error
+		if capacity, err = intArg("chan", args, 0); err != nil {
+			return nil, err
+		}
+		if capacity < 0 {
+			return nil, fmt.Errorf("chan: capacity must not be negative, got %d.", capacity)
+		}
+	}
+	return &Channel{ch: make(chan any, capacity)}, nil
+}
+
+// select(channels...) blocks until one of the channels has a value and returns [index, value], where
+// index is the channel's position in the arguments. a closed channel is always ready with nil
+func nativeSelect(i *Interpreter, args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, errors.New("select: expected at least 1 channel.")
+	}
+
+	// the last case is the interpreter being cancelled
+	cases := make([]reflect.SelectCase, len(args)+1)
+	for idx, arg := range args {
+		channel, ok := arg.(*Channel)
+		if !ok {
+			return nil, fmt.Errorf("select: argument %d must be a channel, got %s.", idx+1, typeName(arg))
+		}
+		cases[idx] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel.ch)}
+	}
+
+	cases[len(args)] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(cancelSignal(i))}
+
+	chosen, value, ok := reflect.Select(cases)
+	if chosen == len(args) {
+		return nil, cancelledError(i)
+	}
+	var received any
+	if ok {
+		received = value.Interface()
+	}
+	return NewLoxList([]any{float64(chosen), received}), nil
+}
+
+// Channel passes values between tasks
+type Channel struct {
+	ch chan any
+}
+
+func (c *Channel) String() string { return fmt.Sprintf("<chan %d>", cap(c.ch)) }
+
+func (c *Channel) Get(name lexer.Token) (any, error) {
+	switch name.Lexeme {
+	case "send":
+		// send(value) blocks until there is room for value
+		return &NativeFunction{Name: "send", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			return nil, c.send(i, args[0])
+		}}, nil
+	case "recv":
+		// recv() blocks until a value arrives, and returns nil once the channel is closed and drained
+		return &NativeFunction{Name: "recv", ArgCount: 0, Fn: func(i *Interpreter, args []any) (any, error) {
+			select {
+			case value := <-c.ch:
+				return value, nil
+			case <-cancelSignal(i):
+				return nil, cancelledError(i)
+			}
+		}}, nil
+	case "close":
+		// close() wakes every task waiting in recv once the buffered values are gone
+		return &NativeFunction{Name: "close", ArgCount: 0, Fn: func(i *Interpreter, args []any) (any, error) {
+			return nil, c.close()
+		}}, nil
+	}
+	return nil, undefinedProperty(name)
+}
+
+// send and close turn go's panics on closed channels into lox errors
+func (c *Channel) send(i *Interpreter, value any) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("send: channel is closed.")
+		}
+	}()
+	select {
+	case c.ch <- value:
+		return nil
+	case <-cancelSignal(i):
+		return cancelledError(i)
+	}
+}
+
+func (c *Channel) close() (err error) {
+	defer func() {
+		if recover() != nil {
+			err = errors.New("close: channel is already closed.")
+		}
+	}()
+	close(c.ch)
+	return nil
+}
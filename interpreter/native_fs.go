@@ -0,0 +1,290 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrPermissionDenied is wrapped by the RuntimeError raised when a file system native touches a path outside
+// of the interpreter's FSPolicy
+var ErrPermissionDenied = errors.New("permission denied")
+
+// FSPolicy grants scripts access to the file system. the zero value denies everything. a path is readable
+// when it is inside one of ReadRoots or WriteRoots, and writable only when it is inside one of WriteRoots.
+// a root is a container, so the natives that act on an entry itself, like remove, can't act on a root.
+//
+// paths are checked with every symlink in them resolved, then opened without following a symlink in their
+// final component, so a file swapped for a link after the check fails to open. a directory further up the
+// path swapped for a link in that window is still followed, which only something else writing inside a
+// root at the same time can do.
+type FSPolicy struct {
+	ReadRoots  []string
+	WriteRoots []string
+}
+
+// check resolves path and returns it if the policy allows the access
+func (p FSPolicy) check(fn string, path string, write bool) (string, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s.", fn, err.Error())
+	}
+	return p.allow(fn, path, resolved, write, false)
+}
+
+// checkEntry is check for natives that act on a directory entry itself rather than on what it points to.
+// only the parent directory is resolved, so a symlink is never followed to its target.
+func (p FSPolicy) checkEntry(fn string, path string, write bool) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s.", fn, err.Error())
+	}
+	parent, err := resolvePath(filepath.Dir(abs))
+	if err != nil {
+		return "", fmt.Errorf("%s: %s.", fn, err.Error())
+	}
+	return p.allow(fn, path, filepath.Join(parent, filepath.Base(abs)), write, true)
+}
+
+// allow returns resolved if it is inside one of the roots the access needs. an entry has to be strictly
+// inside, a root's contents may be removed but not the root
+func (p FSPolicy) allow(fn string, path string, resolved string, write bool, entry bool) (string, error) {
+	roots := p.WriteRoots
+	if !write {
+		roots = append(append([]string{}, p.ReadRoots...), p.WriteRoots...)
+	}
+
+	for _, root := range roots {
+		resolvedRoot, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(resolvedRoot, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || (entry && rel == ".") {
+			continue
+		}
+		return resolved, nil
+	}
+
+	access := "read"
+	if write {
+		access = "write"
+	}
+	return "", fmt.Errorf("%s: %w: %s access to '%s' is not allowed.", fn, ErrPermissionDenied, access, path)
+}
+
+// maxSymlinks bounds how many links resolvePath follows, like the kernel's ELOOP limit
+const maxSymlinks = 40
+
+// resolvePath makes path absolute and follows symlinks so a link can't escape an allowed root. paths that
+// don't exist yet are resolved through their closest existing ancestor, and a dangling symlink is resolved
+// to the file creating it would make.
+func resolvePath(path string) (string, error) {
+	return resolvePathDepth(path, 0)
+}
+
+func resolvePathDepth(path string, depth int) (string, error) {
+	if depth > maxSymlinks {
+		return "", errors.New("too many levels of symbolic links")
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := []string{}
+	current := abs
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		// current exists but doesn't resolve, so it is a link to something missing
+		if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(current)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(current), target)
+			}
+			resolved, err := resolvePathDepth(target, depth+1)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs, nil
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
+
+func defineFSNatives(env *Environment) {
+	defineNative(env, "readFile", 1, nativeReadFile)
+	defineNative(env, "writeFile", 2, nativeWriteFile)
+	defineNative(env, "appendFile", 2, nativeAppendFile)
+	defineNative(env, "listDir", 1, nativeListDir)
+	defineNative(env, "exists", 1, nativeExists)
+	defineNative(env, "remove", 1, nativeRemove)
+}
+
+// openResolved opens a path check returned without following a symlink in its final component, which
+// check already resolved, so one there was swapped in after the check
+func openResolved(resolved string, flags int) (*os.File, error) {
+	return os.OpenFile(resolved, flags|noFollow, 0o644)
+}
+
+// fsError strips the resolved path go includes in its errors down to the underlying reason
+func fsError(fn string, path string, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return fmt.Errorf("%s: '%s': %s.", fn, path, err.Error())
+}
+
+// readFile(path) returns the contents of a file as a string
+func nativeReadFile(i *Interpreter, args []any) (any, error) {
+	path, err := stringArg("readFile", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := i.FS.check("readFile", path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openResolved(resolved, os.O_RDONLY)
+	if err != nil {
+		return nil, fsError("readFile", path, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fsError("readFile", path, err)
+	}
+	return string(data), nil
+}
+
+// writeFile(path, content) creates or truncates a file
+func nativeWriteFile(i *Interpreter, args []any) (any, error) {
+	return writeFile("writeFile", i, args, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+}
+
+// appendFile(path, content) creates a file or appends to the end of it
+func nativeAppendFile(i *Interpreter, args []any) (any, error) {
+	return writeFile("appendFile", i, args, os.O_CREATE|os.O_APPEND|os.O_WRONLY)
+}
+
+func writeFile(fn string, i *Interpreter, args []any, flags int) (any, error) {
+	path, err := stringArg(fn, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	content, err := stringArg(fn, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := i.FS.check(fn, path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openResolved(resolved, flags)
+	if err != nil {
+		return nil, fsError(fn, path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		return nil, fsError(fn, path, err)
+	}
+	return nil, nil
+}
+
+// listDir(path) returns a sorted list of the names of the entries in a directory
+func nativeListDir(i *Interpreter, args []any) (any, error) {
+	path, err := stringArg("listDir", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := i.FS.check("listDir", path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := openResolved(resolved, os.O_RDONLY)
+	if err != nil {
+		return nil, fsError("listDir", path, err)
+	}
+	defer dir.Close()
+
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return nil, fsError("listDir", path, err)
+	}
+
+	names := make([]string, len(entries))
+	for idx, entry := range entries {
+		names[idx] = entry.Name()
+	}
+	sort.Strings(names)
+
+	items := make([]any, len(names))
+	for idx, name := range names {
+		items[idx] = name
+	}
+	return NewLoxList(items), nil
+}
+
+// exists(path) reports whether a file or directory exists
+func nativeExists(i *Interpreter, args []any) (any, error) {
+	path, err := stringArg("exists", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := i.FS.check("exists", path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(resolved); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return nil, fsError("exists", path, err)
+	}
+	return true, nil
+}
+
+// remove(path) deletes a file, a symlink or an empty directory. a symlink is removed itself, never its target
+func nativeRemove(i *Interpreter, args []any) (any, error) {
+	path, err := stringArg("remove", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := i.FS.checkEntry("remove", path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(resolved); err != nil {
+		return nil, fsError("remove", path, err)
+	}
+	return nil, nil
+}
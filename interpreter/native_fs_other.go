@@ -0,0 +1,6 @@
+//go:build !unix
+
+package interpreter
+
+// noFollow has no equivalent here, so the final component of a path is followed like the rest of it
+const noFollow = 0
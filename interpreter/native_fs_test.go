@@ -0,0 +1,197 @@
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// the script sees the directories it works with as the `dir` and `outside` globals
+func interpretWithPolicy(source string, policy FSPolicy, dir string, outside string) (*Interpreter, *RuntimeError) {
+	tokens := lexer.NewScanner(source).ScanTokens()
+	stmts := ast.NewParser(tokens).Parse()
+
+	i := NewInterpreter()
+	i.FS = policy
+	i.Environment.Define("dir", dir)
+	i.Environment.Define("outside", outside)
+
+	return i, i.Interpret(stmts)
+}
+
+func TestFSNatives(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	policy := FSPolicy{WriteRoots: []string{dir}}
+
+	i, err := interpretWithPolicy(`
+var path = dir + "/notes.txt";
+print exists(path);
+writeFile(path, "one");
+appendFile(path, ", two");
+print readFile(path);
+writeFile(dir + "/b.txt", "");
+print listDir(dir);
+remove(path);
+print exists(path);`, policy, dir, outside)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "false\none, two\n[\"b.txt\", \"notes.txt\"]\nfalse\n", i.Output.String())
+}
+
+func TestFSPolicy(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "data.txt"), []byte("data"), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644))
+
+	readOnly := FSPolicy{ReadRoots: []string{dir}}
+
+	// reading inside a read root is fine
+	i, err := interpretWithPolicy(`print readFile(dir + "/data.txt");`, readOnly, dir, outside)
+	assert.Nil(t, err)
+	assert.Equal(t, "data\n", i.Output.String())
+
+	cases := []struct {
+		Source string
+		Policy FSPolicy
+	}{
+		{`writeFile(dir + "/data.txt", "x");`, readOnly},
+		{`remove(dir + "/data.txt");`, readOnly},
+		{`readFile(dir + "/../" + "escape.txt");`, readOnly},
+		{`readFile(dir + "/data.txt");`, FSPolicy{}},
+		{`exists(dir);`, FSPolicy{}},
+	}
+
+	for idx, testCase := range cases {
+		_, err := interpretWithPolicy(testCase.Source, testCase.Policy, dir, outside)
+
+		if assert.NotNil(t, err, "test case %d failed", idx) {
+			assert.True(t, errors.Is(err, ErrPermissionDenied), "test case %d failed", idx)
+			assert.Equal(t, lexer.RIGHT_PAREN, err.Token.TokenType, "test case %d failed", idx)
+			assert.Contains(t, err.Message, "permission denied", "test case %d failed", idx)
+		}
+	}
+
+	// a symlink inside an allowed root can't be used to reach outside of it
+	assert.Nil(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	_, err = interpretWithPolicy(`readFile(dir + "/link/secret.txt");`, readOnly, dir, outside)
+	if assert.NotNil(t, err) {
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+	}
+
+	// ordinary failures are not permission errors
+	_, err = interpretWithPolicy(`readFile(dir + "/missing.txt");`, readOnly, dir, outside)
+	if assert.NotNil(t, err) {
+		assert.False(t, errors.Is(err, ErrPermissionDenied))
+		assert.Equal(t, "readFile: '"+dir+"/missing.txt': no such file or directory.", err.Message)
+	}
+}
+
+func TestFSDanglingSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	policy := FSPolicy{WriteRoots: []string{dir}}
+
+	// a link to a file that doesn't exist yet can't be used to create it outside of the root
+	assert.Nil(t, os.Symlink(filepath.Join(outside, "pwned.txt"), filepath.Join(dir, "evil")))
+	_, err := interpretWithPolicy(`writeFile(dir + "/evil", "escaped");`, policy, dir, outside)
+	if assert.NotNil(t, err) {
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+	}
+	_, statErr := os.Stat(filepath.Join(outside, "pwned.txt"))
+	assert.True(t, errors.Is(statErr, os.ErrNotExist))
+
+	// nor can a chain of links or a link to a missing directory
+	assert.Nil(t, os.Symlink(filepath.Join(dir, "evil"), filepath.Join(dir, "chain")))
+	assert.Nil(t, os.Symlink(filepath.Join(outside, "missing"), filepath.Join(dir, "missingDir")))
+	for _, source := range []string{
+		`appendFile(dir + "/chain", "escaped");`,
+		`writeFile(dir + "/missingDir/pwned.txt", "escaped");`,
+	} {
+		_, err := interpretWithPolicy(source, policy, dir, outside)
+		if assert.NotNil(t, err, source) {
+			assert.True(t, errors.Is(err, ErrPermissionDenied), source)
+		}
+	}
+
+	// a dangling link that stays inside the root still works
+	assert.Nil(t, os.Symlink("target.txt", filepath.Join(dir, "ok")))
+	i, err := interpretWithPolicy(`writeFile(dir + "/ok", "fine"); print readFile(dir + "/target.txt");`, policy, dir, outside)
+	assert.Nil(t, err)
+	assert.Equal(t, "fine\n", i.Output.String())
+}
+
+func TestFSRemoveSymlink(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	policy := FSPolicy{WriteRoots: []string{dir}}
+
+	target := filepath.Join(outside, "keep.txt")
+	assert.Nil(t, os.WriteFile(target, []byte("keep"), 0o644))
+	assert.Nil(t, os.Symlink(target, filepath.Join(dir, "link")))
+
+	// removing a link deletes the link and leaves its target alone
+	_, err := interpretWithPolicy(`remove(dir + "/link");`, policy, dir, outside)
+	assert.Nil(t, err)
+
+	_, err2 := os.Lstat(filepath.Join(dir, "link"))
+	assert.True(t, errors.Is(err2, os.ErrNotExist))
+	data, err2 := os.ReadFile(target)
+	assert.Nil(t, err2)
+	assert.Equal(t, "keep", string(data))
+
+	// the link's own location still has to be writable
+	assert.Nil(t, os.Symlink(dir, filepath.Join(outside, "back")))
+	_, err = interpretWithPolicy(`remove(outside + "/back");`, policy, dir, outside)
+	if assert.NotNil(t, err) {
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+	}
+}
+
+func TestFSRemoveRoot(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	policy := FSPolicy{WriteRoots: []string{dir}}
+
+	// a write root holds what the script may remove, it can't remove the root itself however it's spelled
+	for _, source := range []string{`remove(dir);`, `remove(dir + "/");`, `remove(dir + "/missing/..");`} {
+		_, err := interpretWithPolicy(source, policy, dir, outside)
+		if assert.NotNil(t, err, source) {
+			assert.True(t, errors.Is(err, ErrPermissionDenied), source)
+		}
+	}
+	_, statErr := os.Stat(dir)
+	assert.Nil(t, statErr)
+
+	// what's inside it is fine
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "empty"), 0o755))
+	i, err := interpretWithPolicy(`remove(dir + "/empty"); print exists(dir + "/empty");`, policy, dir, outside)
+	assert.Nil(t, err)
+	assert.Equal(t, "false\n", i.Output.String())
+}
+
+func TestFSOpenResolvedNoFollow(t *testing.T) {
+	if noFollow == 0 {
+		t.Skip("no O_NOFOLLOW on this platform")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	assert.Nil(t, os.WriteFile(target, []byte("target"), 0o644))
+
+	// a symlink where check resolved a file means it was swapped in after the check, so it isn't followed
+	link := filepath.Join(dir, "link")
+	assert.Nil(t, os.Symlink(target, link))
+	_, err := openResolved(link, os.O_RDONLY)
+	assert.NotNil(t, err)
+
+	f, err := openResolved(target, os.O_RDONLY)
+	if assert.Nil(t, err) {
+		f.Close()
+	}
+}
@@ -0,0 +1,8 @@
+//go:build unix
+
+package interpreter
+
+import "syscall"
+
+// noFollow makes opening a symlink fail instead of following it
+const noFollow = syscall.O_NOFOLLOW
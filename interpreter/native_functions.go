@@ -1,12 +1,117 @@
 package interpreter
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"time"
+)
 
 type Clock struct{}
 
 func (c *Clock) Arity() int { return 0 }
-func (c *Clock) Call(i Interpreter, arguments []any) any {
-	return time.Now().UnixMilli() / 1000
+func (c *Clock) Call(i *Interpreter, arguments []any) (any, error) {
+	now := time.Now()
+	// a virtual event loop keeps its own time, which clock() follows so timer tests can measure it
+	if i.Loop != nil {
+		now = i.Loop.Now()
+	}
+	return float64(now.UnixMilli()) / 1000, nil
 }
 
 func (c *Clock) toString() string { return "<native fn>" }
+
+// NativeFunction adapts a go function to the LoxCallable interface. an Arity of -1 accepts any
+// number of arguments, leaving validation to Fn.
+type NativeFunction struct {
+	Name     string
+	ArgCount int
+	Fn       func(interpreter *Interpreter, arguments []any) (any, error)
+}
+
+func (n *NativeFunction) Arity() int { return n.ArgCount }
+func (n *NativeFunction) Call(i *Interpreter, arguments []any) (any, error) {
+	return n.Fn(i, arguments)
+}
+
+func (n *NativeFunction) toString() string { return fmt.Sprintf("<native fn %s>", n.Name) }
+
+func defineNative(env *Environment, name string, arity int, fn func(*Interpreter, []any) (any, error)) {
+	env.Define(name, &NativeFunction{Name: name, ArgCount: arity, Fn: fn})
+}
+
+// typeName describes a runtime value for error messages
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *Generator:
+		return "generator"
+	case *Task:
+		return "task"
+	case *Channel:
+		return "channel"
+	case LoxCallable:
+		return "function"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// argument helpers below. errors returned from natives are reported at the call's closing paren by VisitCallExpr
+
+func stringArg(fn string, args []any, idx int) (string, error) {
+	if str, ok := args[idx].(string); ok {
+		return str, nil
+	}
+	return "", fmt.Errorf("%s: argument %d must be a string, got %s.", fn, idx+1, typeName(args[idx]))
+}
+
+func numberArg(fn string, args []any, idx int) (float64, error) {
+	if num, ok := args[idx].(float64); ok {
+		return num, nil
+	}
+	return 0, fmt.Errorf("%s: argument %d must be a number, got %s.", fn, idx+1, typeName(args[idx]))
+}
+
+func intArg(fn string, args []any, idx int) (int, error) {
+	num, err := numberArg(fn, args, idx)
+	if err != nil {
+		return 0, err
+	}
+	if num != math.Trunc(num) || math.IsInf(num, 0) {
+		return 0, fmt.Errorf("%s: argument %d must be an integer, got %v.", fn, idx+1, num)
+	}
+	// float64(math.MaxInt) rounds up to 2^63, which int can't hold
+	if num < math.MinInt || num >= math.MaxInt {
+		return 0, fmt.Errorf("%s: argument %d is out of range, got %v.", fn, idx+1, num)
+	}
+	return int(num), nil
+}
+
+func callableArg(fn string, args []any, idx int) (LoxCallable, error) {
+	if c, ok := args[idx].(LoxCallable); ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%s: argument %d must be a function, got %s.", fn, idx+1, typeName(args[idx]))
+}
+
+// NativeArities reports the arity of every native function defined in a fresh interpreter, so static
+// tools can check calls to them without running anything. variadic natives report -1
+func NativeArities() map[string]int {
+	arities := map[string]int{}
+	for name, value := range NewInterpreter().Environment.Values {
+		if native, ok := value.(LoxCallable); ok {
+			arities[name] = native.Arity()
+		}
+	}
+	return arities
+}
@@ -0,0 +1,228 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+func defineJSONNatives(env *Environment) {
+	defineNative(env, "jsonParse", 1, nativeJSONParse)
+	defineNative(env, "jsonStringify", -1, nativeJSONStringify)
+}
+
+// jsonParse(str) decodes a JSON document. objects become maps, arrays become lists and every number is a float.
+func nativeJSONParse(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("jsonParse", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str))
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, jsonSyntaxError(err, len(str))
+	}
+
+	// only whitespace may follow the top level value
+	offset := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("jsonParse: unexpected data after top-level value at offset %d.", offset)
+	}
+
+	return value, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			list := NewLoxList(nil)
+			for dec.More() {
+				item, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				list.Append(item)
+			}
+			// the closing ']'
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return list, nil
+		}
+
+		obj := NewLoxMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(key.(string), value)
+		}
+		// the closing '}'
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	default:
+		// float64, string, bool or nil
+		return t, nil
+	}
+}
+
+func jsonSyntaxError(err error, length int) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Errorf("jsonParse: %s at offset %d.", syntaxErr.Error(), syntaxErr.Offset)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("jsonParse: unexpected end of JSON input at offset %d.", length)
+	}
+	return fmt.Errorf("jsonParse: %s.", err.Error())
+}
+
+// maxJSONIndent is the longest indent jsonStringify uses, longer ones are clamped like JSON.stringify does
+const maxJSONIndent = 10
+
+// jsonStringify(value, indent?) encodes value as JSON. indent is either a number of spaces or the string
+// to indent with, when omitted the output is compact.
+func nativeJSONStringify(i *Interpreter, args []any) (any, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("jsonStringify: expected 1 or 2 arguments, got %d.", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case string:
+			indent = arg
+			if len(indent) > maxJSONIndent {
+				indent = indent[:maxJSONIndent]
+			}
+		case float64:
+			spaces, err := intArg("jsonStringify", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if spaces < 0 {
+				return nil, fmt.Errorf("jsonStringify: indent must not be negative, got %d.", spaces)
+			}
+			indent = strings.Repeat(" ", min(spaces, maxJSONIndent))
+		default:
+			return nil, fmt.Errorf("jsonStringify: argument 2 must be a number or a string, got %s.", typeName(arg))
+		}
+	}
+
+	enc := &jsonEncoder{indent: indent, visiting: map[any]bool{}}
+	if err := enc.encode(args[0], 0); err != nil {
+		return nil, fmt.Errorf("jsonStringify: %s", err.Error())
+	}
+	return enc.buf.String(), nil
+}
+
+type jsonEncoder struct {
+	buf      bytes.Buffer
+	indent   string
+	visiting map[any]bool // collections currently being encoded, used to detect cycles
+}
+
+func (e *jsonEncoder) encode(value any, depth int) error {
+	switch v := value.(type) {
+	case nil:
+		e.buf.WriteString("null")
+	case bool:
+		fmt.Fprint(&e.buf, v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("cannot encode %v.", v)
+		}
+		out, _ := json.Marshal(v)
+		e.buf.Write(out)
+	case string:
+		e.writeString(v)
+	case *LoxList:
+		if e.visiting[v] {
+			return fmt.Errorf("cannot encode cyclic structure.")
+		}
+		e.visiting[v] = true
+		defer delete(e.visiting, v)
+
+		items := v.Values()
+		e.buf.WriteByte('[')
+		for idx, item := range items {
+			if idx > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(item, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(items) > 0 {
+			e.newline(depth)
+		}
+		e.buf.WriteByte(']')
+	case *LoxMap:
+		if e.visiting[v] {
+			return fmt.Errorf("cannot encode cyclic structure.")
+		}
+		e.visiting[v] = true
+		defer delete(e.visiting, v)
+
+		keys := v.Keys()
+		e.buf.WriteByte('{')
+		for idx, key := range keys {
+			if idx > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			e.writeString(key)
+			e.buf.WriteByte(':')
+			if e.indent != "" {
+				e.buf.WriteByte(' ')
+			}
+			item, _ := v.Lookup(key)
+			if err := e.encode(item, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(keys) > 0 {
+			e.newline(depth)
+		}
+		e.buf.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot encode %s.", typeName(value))
+	}
+
+	return nil
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(strings.Repeat(e.indent, depth))
+}
+
+func (e *jsonEncoder) writeString(str string) {
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	// Encode terminates every value with a newline
+	e.buf.Truncate(e.buf.Len() - 1)
+}
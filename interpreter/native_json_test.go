@@ -0,0 +1,94 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+// lox strings can't contain double quotes, so documents are handed to the script in the `doc` global
+type JSONTestCase struct {
+	ID       int
+	Doc      string
+	Source   string
+	Expected string
+}
+
+var jsonNativeCases = []JSONTestCase{
+	{ID: 1, Doc: `1.5`, Source: `print jsonParse(doc) + 1;`, Expected: "2.5\n"},
+	{ID: 2, Doc: `"hi"`, Source: `print jsonParse(doc);`, Expected: "hi\n"},
+	{ID: 3, Doc: `true`, Source: `print jsonParse(doc);`, Expected: "true\n"},
+	{ID: 4, Doc: `null`, Source: `print jsonParse(doc) == nil;`, Expected: "true\n"},
+	{ID: 5, Doc: `[1, "two", null]`, Source: `var v = jsonParse(doc); print v; print v.length; print v.get(1);`, Expected: "[1, \"two\", nil]\n3\ntwo\n"},
+	{ID: 6, Doc: `{"b": 1, "a": {"c": [true]}}`, Source: `var v = jsonParse(doc); print v; print v.get("a").get("c").get(0);`, Expected: "{\"b\": 1, \"a\": {\"c\": [true]}}\ntrue\n"},
+	{ID: 7, Doc: ` {"b":1,"a":[1,2.5,"<x>"]} `, Source: `print jsonStringify(jsonParse(doc));`, Expected: "{\"b\":1,\"a\":[1,2.5,\"<x>\"]}\n"},
+	{ID: 8, Source: `print jsonStringify(nil);`, Expected: "null\n"},
+	{ID: 9, Source: `var m = dict(); m.set("name", "lox"); m.set("tags", list("a", "b")); m.set("empty", list()); print jsonStringify(m, 2);`, Expected: "{\n  \"name\": \"lox\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ],\n  \"empty\": []\n}\n"},
+	{ID: 10, Source: `print jsonStringify(list(1, list()), "	");`, Expected: "[\n\t1,\n\t[]\n]\n"},
+	{ID: 11, Doc: `quote " and ünïcode`, Source: `print jsonStringify(doc);`, Expected: "\"quote \\\" and ünïcode\"\n"},
+	{ID: 12, Doc: `{"a": 1, "a": 2}`, Source: `print len(jsonParse(doc)); print jsonParse(doc).get("a");`, Expected: "1\n2\n"},
+	{ID: 13, Doc: `["a", "b"]`, Source: `print join("-", jsonParse(doc));`, Expected: "a-b\n"},
+	{ID: 14, Source: `print jsonStringify(list(1), 1000000000000);`, Expected: "[\n          1\n]\n"},
+	{ID: 15, Source: `print jsonStringify(list(1), "abcdefghijklmnop");`, Expected: "[\nabcdefghij1\n]\n"},
+}
+
+func TestJSONNatives(t *testing.T) {
+	for _, testCase := range jsonNativeCases {
+		i, err := interpretSourceWith(testCase.Source, map[string]any{"doc": testCase.Doc})
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var jsonNativeErrorCases = []JSONTestCase{
+	{ID: 1, Doc: `[1, x]`, Source: `jsonParse(doc);`, Expected: "jsonParse: invalid character 'x' looking for beginning of value at offset 5."},
+	{ID: 2, Doc: `{"a": 1`, Source: `jsonParse(doc);`, Expected: "jsonParse: unexpected end of JSON input at offset 7."},
+	{ID: 3, Doc: ``, Source: `jsonParse(doc);`, Expected: "jsonParse: unexpected end of JSON input at offset 0."},
+	{ID: 4, Doc: `1 2`, Source: `jsonParse(doc);`, Expected: "jsonParse: unexpected data after top-level value at offset 1."},
+	{ID: 5, Doc: `[1 2]`, Source: `jsonParse(doc);`, Expected: "jsonParse: invalid character '2' after array element at offset 4."},
+	{ID: 6, Source: `jsonStringify(clock);`, Expected: "jsonStringify: cannot encode function."},
+	{ID: 7, Source: `var l = list(); l.push(l); jsonStringify(l);`, Expected: "jsonStringify: cannot encode cyclic structure."},
+	{ID: 8, Source: `jsonStringify(1, true);`, Expected: "jsonStringify: argument 2 must be a number or a string, got boolean."},
+	{ID: 9, Source: `jsonStringify(0 / 0);`, Expected: "jsonStringify: cannot encode NaN."},
+	{ID: 10, Source: `jsonParse(1);`, Expected: "jsonParse: argument 1 must be a string, got number."},
+}
+
+func TestJSONNativeErrors(t *testing.T) {
+	for _, testCase := range jsonNativeErrorCases {
+		_, err := interpretSourceWith(testCase.Source, map[string]any{"doc": testCase.Doc})
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, err.Message, "test case %d failed", testCase.ID)
+			assert.Equal(t, lexer.RIGHT_PAREN, err.Token.TokenType, "test case %d failed", testCase.ID)
+		}
+	}
+}
+
+func TestCollections(t *testing.T) {
+	i, err := interpretSource(`var l = list(1, 2); l.push(3); l.set(0, "x"); print l; print l.pop(); print l.length;
+var m = dict(); m.set("a", 1); m.set("b", 2); m.set("a", 3); print m.keys(); print m.has("b"); print m.remove("b"); print m.has("b"); print m.get("zzz");`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "[\"x\", 2, 3]\n3\n2\n[\"a\", \"b\"]\ntrue\ntrue\nfalse\n<nil>\n", i.Output.String())
+
+	_, err = interpretSource(`list(1).get(1);`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "get: index 1 out of bounds for list of length 1.", err.Message)
+	}
+
+	_, err = interpretSource(`list().pop();`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "pop: list is empty.", err.Message)
+	}
+}
+
+func TestCyclicCollectionsPrint(t *testing.T) {
+	i, err := interpretSource(`var l = list(1); l.push(l); print l;
+var m = dict(); m.set("self", m); m.set("items", list(m)); print m;
+var shared = list(); print list(shared, shared);`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "[1, [...]]\n{\"self\": {...}, \"items\": [{...}]}\n[[], []]\n", i.Output.String())
+}
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Exit is the error exit(code) stops a program with. it reaches whoever called Interpret or
+// EventLoop.Run wrapped in a RuntimeError, use ExitCode to recognise it
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Error() string { return fmt.Sprintf("exit(%d)", e.Code) }
+
+// ExitCode reports the code a program asked for when err is the one exit() stopped it with
+func ExitCode(err error) (int, bool) {
+	var exit *Exit
+	if errors.As(err, &exit) {
+		return exit.Code, true
+	}
+	return 0, false
+}
+
+func defineProcessNatives(env *Environment) {
+	env.Define("args", NewLoxList(nil))
+	defineNative(env, "getenv", 1, nativeGetenv)
+	defineNative(env, "setenv", 2, nativeSetenv)
+	defineNative(env, "exit", 1, nativeExit)
+}
+
+// SetArgs makes args the script's `args` list, the command line arguments that followed the script name
+func (s *Interpreter) SetArgs(args []string) {
+	items := make([]any, len(args))
+	for i, arg := range args {
+		items[i] = arg
+	}
+	s.Environment.Define("args", NewLoxList(items))
+}
+
+// getenv(name) returns the value of an environment variable, or nil when it isn't set
+func nativeGetenv(i *Interpreter, args []any) (any, error) {
+	name, err := stringArg("getenv", args, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	return nil, nil
+}
+
+// setenv(name, value) sets an environment variable for the rest of the process, including programs it starts
+func nativeSetenv(i *Interpreter, args []any) (any, error) {
+	name, err := stringArg("setenv", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stringArg("setenv", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Setenv(name, value); err != nil {
+		return nil, fmt.Errorf("setenv: can't set '%s': %s.", name, err.Error())
+	}
+	return nil, nil
+}
+
+// exit(code) stops the program, skipping any timers still pending. called from a spawned task it only
+// ends that task, and the program once the task is waited on
+func nativeExit(i *Interpreter, args []any) (any, error) {
+	code, err := intArg("exit", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if code < 0 || code > 255 {
+		return nil, fmt.Errorf("exit: code must be between 0 and 255, got %d.", code)
+	}
+	return nil, &Exit{Code: code}
+}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgs(t *testing.T) {
+	i, err := interpretSource(`print args;`)
+	assert.Nil(t, err)
+	assert.Equal(t, "[]\n", i.Output.String())
+
+	stmts := ast.NewParser(lexer.NewScanner(`print args.length; print args.get(1);`).ScanTokens()).Parse()
+	i = NewInterpreter()
+	i.SetArgs([]string{"a", "b c"})
+	assert.Nil(t, i.Interpret(stmts))
+	assert.Equal(t, "2\nb c\n", i.Output.String())
+}
+
+func TestEnvNatives(t *testing.T) {
+	t.Setenv("LOX_TEST_VAR", "from go")
+
+	i, err := interpretSource(`
+print getenv("LOX_TEST_VAR");
+setenv("LOX_TEST_VAR", "from lox");
+print getenv("LOX_TEST_VAR");
+print getenv("LOX_TEST_UNSET_VAR");`)
+	assert.Nil(t, err)
+	assert.Equal(t, "from go\nfrom lox\n<nil>\n", i.Output.String())
+
+	_, err = interpretSource(`setenv("", "x");`)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Message, "setenv: can't set ''")
+}
+
+func TestExit(t *testing.T) {
+	cases := []struct {
+		ID     int
+		Source string
+		Output string
+		Code   int
+	}{
+		{ID: 1, Source: `print 1; exit(3); print 2;`, Output: "1\n", Code: 3},
+		// exit unwinds through functions, loops and blocks
+		{ID: 2, Source: `fun f() { while (true) { exit(0); } } f(); print 2;`, Code: 0},
+		{ID: 3, Source: `fun f(x) { { exit(len(x)); } } f("abcdefg"); print 2;`, Code: 7},
+		{ID: 4, Source: `fun* g() { yield 1; exit(4); } var gen = g(); print gen.next(); gen.next(); print 2;`, Output: "1\n", Code: 4},
+		{ID: 5, Source: `fun f() { exit(5); } wait(spawn f()); print 2;`, Code: 5},
+	}
+
+	for _, testCase := range cases {
+		i, err := interpretSource(testCase.Source)
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			code, ok := ExitCode(err)
+			assert.True(t, ok, "test case %d failed", testCase.ID)
+			assert.Equal(t, testCase.Code, code, "test case %d failed", testCase.ID)
+		}
+		assert.Equal(t, testCase.Output, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+
+	for _, source := range []string{`exit(256);`, `exit(-1);`, `exit(1.5);`, `exit("1");`} {
+		_, err := interpretSource(source)
+		if assert.NotNil(t, err, source) {
+			_, ok := ExitCode(err)
+			assert.False(t, ok, source)
+		}
+	}
+}
+
+func TestExitFromTimer(t *testing.T) {
+	stmts := ast.NewParser(lexer.NewScanner(`
+fun f() { print "timer"; exit(2); }
+fun g() { print "never"; }
+setTimeout(f, 10);
+setTimeout(g, 20);`).ScanTokens()).Parse()
+
+	i := NewInterpreter()
+	i.Loop = NewVirtualEventLoop()
+	assert.Nil(t, i.Interpret(stmts))
+
+	err := i.Loop.Run(i)
+	if assert.NotNil(t, err) {
+		code, ok := ExitCode(err)
+		assert.True(t, ok)
+		assert.Equal(t, 2, code)
+	}
+	assert.Equal(t, "timer\n", i.Output.String())
+}
@@ -0,0 +1,232 @@
+package interpreter
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+func defineRegexNatives(env *Environment) {
+	defineNative(env, "regex", -1, nativeRegex)
+}
+
+// regex(pattern, flags?) compiles pattern using go's RE2 syntax. flags is any combination of
+// "i" (case insensitive), "m" (multi-line) and "s" (`.` matches newlines).
+func nativeRegex(i *Interpreter, args []any) (any, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("regex: expected 1 or 2 arguments, got %d.", len(args))
+	}
+	pattern, err := stringArg("regex", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	flags := ""
+	if len(args) == 2 {
+		if flags, err = stringArg("regex", args, 1); err != nil {
+			return nil, err
+		}
+		for _, flag := range flags {
+			if !strings.ContainsRune("ims", flag) {
+				return nil, fmt.Errorf("regex: unknown flag '%c'.", flag)
+			}
+		}
+	}
+
+	key := flags + "/" + pattern
+	re, ok := i.regexCache.get(key)
+	if !ok {
+		source := pattern
+		if flags != "" {
+			source = "(?" + flags + ")" + pattern
+		}
+		if re, err = regexp.Compile(source); err != nil {
+			return nil, fmt.Errorf("regex: invalid pattern: %s.", err.Error())
+		}
+		i.regexCache.add(key, re)
+	}
+
+	return &RegexObject{Pattern: pattern, Flags: flags, re: re}, nil
+}
+
+// maxCachedRegexes bounds how many compiled patterns an interpreter keeps, so a program building
+// patterns from its input can't grow the cache forever
+const maxCachedRegexes = 256
+
+// regexCache keeps the patterns used most recently, keyed by flags and source. the zero value is empty
+type regexCache struct {
+	entries map[string]*list.Element
+	// the most recently used pattern first
+	order list.List
+}
+
+type cachedRegex struct {
+	key string
+	re  *regexp.Regexp
+}
+
+func (c *regexCache) get(key string) (*regexp.Regexp, bool) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(entry)
+	return entry.Value.(*cachedRegex).re, true
+}
+
+// add caches re, dropping the pattern used least recently once the cache is full
+func (c *regexCache) add(key string, re *regexp.Regexp) {
+	if c.entries == nil {
+		c.entries = map[string]*list.Element{}
+	}
+	if c.order.Len() >= maxCachedRegexes {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedRegex).key)
+	}
+	c.entries[key] = c.order.PushFront(&cachedRegex{key: key, re: re})
+}
+
+func (c *regexCache) len() int {
+	return c.order.Len()
+}
+
+// RegexObject is the compiled pattern returned by `regex`
+type RegexObject struct {
+	Pattern string
+	Flags   string
+	re      *regexp.Regexp
+}
+
+func (r *RegexObject) String() string { return fmt.Sprintf("<regex /%s/%s>", r.Pattern, r.Flags) }
+
+func (r *RegexObject) Get(name lexer.Token) (any, error) {
+	switch name.Lexeme {
+	case "pattern":
+		return r.Pattern, nil
+	case "flags":
+		return r.Flags, nil
+	case "match":
+		// match(str) reports whether str contains a match
+		return r.method("match", 1, func(i *Interpreter, args []any) (any, error) {
+			str, err := stringArg("match", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return r.re.MatchString(str), nil
+		}), nil
+	case "find":
+		// find(str) returns the leftmost match, or nil
+		return r.method("find", 1, func(i *Interpreter, args []any) (any, error) {
+			str, err := stringArg("find", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			loc := r.re.FindStringSubmatchIndex(str)
+			if loc == nil {
+				return nil, nil
+			}
+			return newMatchObject(r.re, str, loc), nil
+		}), nil
+	case "findAll":
+		// findAll(str) returns a list of every successive non-overlapping match
+		return r.method("findAll", 1, func(i *Interpreter, args []any) (any, error) {
+			str, err := stringArg("findAll", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			matches := []any{}
+			for _, loc := range r.re.FindAllStringSubmatchIndex(str, -1) {
+				matches = append(matches, newMatchObject(r.re, str, loc))
+			}
+			return NewLoxList(matches), nil
+		}), nil
+	case "replace":
+		// replace(str, replacement) replaces every match. $1 or ${name} in replacement expand to capture groups
+		return r.method("replace", 2, func(i *Interpreter, args []any) (any, error) {
+			str, err := stringArg("replace", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			replacement, err := stringArg("replace", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return r.re.ReplaceAllString(str, replacement), nil
+		}), nil
+	}
+
+	return nil, undefinedProperty(name)
+}
+
+func (r *RegexObject) method(name string, arity int, fn func(*Interpreter, []any) (any, error)) *NativeFunction {
+	return &NativeFunction{Name: name, ArgCount: arity, Fn: fn}
+}
+
+// MatchObject describes a single match. start and end are character offsets into the searched string.
+type MatchObject struct {
+	Text   string
+	Start  int
+	End    int
+	groups []any // nil for groups that did not participate in the match
+	names  []string
+}
+
+func newMatchObject(re *regexp.Regexp, str string, loc []int) *MatchObject {
+	groups := make([]any, len(loc)/2)
+	for idx := range groups {
+		if loc[2*idx] >= 0 {
+			groups[idx] = str[loc[2*idx]:loc[2*idx+1]]
+		}
+	}
+
+	return &MatchObject{
+		Text:   str[loc[0]:loc[1]],
+		Start:  utf8.RuneCountInString(str[:loc[0]]),
+		End:    utf8.RuneCountInString(str[:loc[1]]),
+		groups: groups,
+		names:  re.SubexpNames(),
+	}
+}
+
+func (m *MatchObject) String() string { return fmt.Sprintf("<match %q>", m.Text) }
+
+func (m *MatchObject) Get(name lexer.Token) (any, error) {
+	switch name.Lexeme {
+	case "text":
+		return m.Text, nil
+	case "start":
+		return float64(m.Start), nil
+	case "end":
+		return float64(m.End), nil
+	case "count":
+		// number of capture groups, not counting the whole match
+		return float64(len(m.groups) - 1), nil
+	case "group":
+		// group(n) returns capture group n, or the group named n when given a string. group 0 is the whole match
+		return &NativeFunction{Name: "group", ArgCount: 1, Fn: func(i *Interpreter, args []any) (any, error) {
+			if groupName, ok := args[0].(string); ok {
+				for idx, n := range m.names {
+					if n != "" && n == groupName {
+						return m.groups[idx], nil
+					}
+				}
+				return nil, fmt.Errorf("group: no capture group named '%s'.", groupName)
+			}
+
+			idx, err := intArg("group", args, 0)
+			if err != nil {
+				return nil, err
+			}
+			if idx < 0 || idx >= len(m.groups) {
+				return nil, fmt.Errorf("group: index %d out of bounds for %d groups.", idx, len(m.groups)-1)
+			}
+			return m.groups[idx], nil
+		}}, nil
+	}
+
+	return nil, undefinedProperty(name)
+}
@@ -0,0 +1,88 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+var regexNativeCases = []NativeTestCase{
+	{ID: 1, Source: `print regex("a+b").match("caaab");`, Expected: "true\n"},
+	{ID: 2, Source: `print regex("^a+b$").match("caaab");`, Expected: "false\n"},
+	{ID: 3, Source: `print regex("HELLO", "i").match("well hello");`, Expected: "true\n"},
+	{ID: 4, Source: `var m = regex("(\w+)@(\w+)").find("mail bob@example now"); print m.text; print m.group(1); print m.group(2); print m.count;`, Expected: "bob@example\nbob\nexample\n2\n"},
+	{ID: 5, Source: `var m = regex("ü+").find("grüüße"); print m.start; print m.end;`, Expected: "2\n4\n"},
+	{ID: 6, Source: `print regex("x").find("abc");`, Expected: "<nil>\n"},
+	{ID: 7, Source: `var m = regex("(?P<level>[A-Z]+):").find("ts=1 WARN: disk"); print m.group("level");`, Expected: "WARN\n"},
+	{ID: 8, Source: `print regex("a(x)?b").find("ab").group(1);`, Expected: "<nil>\n"},
+	{ID: 9, Source: `var all = regex("[0-9]+").findAll("a1 b22 c333"); for (var i = 0; i < all.length; i++) print all.get(i).text; print regex("x").findAll("abc").length;`, Expected: "1\n22\n333\n0\n"},
+	{ID: 10, Source: `print regex("(\w+)=(\w+)").replace("a=1, b=2", "$2=$1");`, Expected: "1=a, 2=b\n"},
+	{ID: 11, Source: `print regex("a.b", "s").pattern;`, Expected: "a.b\n"},
+	{ID: 12, Source: `print regex("a.b", "s");`, Expected: "<regex /a.b/s>\n"},
+}
+
+func TestRegexNatives(t *testing.T) {
+	for _, testCase := range regexNativeCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var regexNativeErrorCases = []NativeTestCase{
+	{ID: 1, Source: `regex("(");`, Expected: "regex: invalid pattern: error parsing regexp: missing closing ): `(`."},
+	{ID: 2, Source: `regex("a", "g");`, Expected: "regex: unknown flag 'g'."},
+	{ID: 3, Source: `regex(1);`, Expected: "regex: argument 1 must be a string, got number."},
+	{ID: 4, Source: `regex("a").match(1);`, Expected: "match: argument 1 must be a string, got number."},
+	{ID: 5, Source: `regex("a").find("a").group(3);`, Expected: "group: index 3 out of bounds for 0 groups."},
+}
+
+func TestRegexNativeErrors(t *testing.T) {
+	for _, testCase := range regexNativeErrorCases {
+		_, err := interpretSource(testCase.Source)
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, err.Message, "test case %d failed", testCase.ID)
+			assert.Equal(t, lexer.RIGHT_PAREN, err.Token.TokenType, "test case %d failed", testCase.ID)
+		}
+	}
+}
+
+func TestPropertyErrors(t *testing.T) {
+	_, err := interpretSource(`regex("a").nope;`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "undefined property 'nope'.", err.Message)
+		assert.Equal(t, "nope", err.Token.Lexeme)
+	}
+
+	_, err = interpretSource(`"str".length;`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "only objects have properties, got string.", err.Message)
+	}
+}
+
+func TestRegexCache(t *testing.T) {
+	i, err := interpretSource(`var a = regex("[a-z]+"); var b = regex("[a-z]+"); var c = regex("[a-z]+", "i");`)
+	assert.Nil(t, err)
+
+	a, _ := i.Environment.Get(lexer.Token{Lexeme: "a"})
+	b, _ := i.Environment.Get(lexer.Token{Lexeme: "b"})
+
+	// the same pattern compiles once, flags are part of the cache key
+	assert.Equal(t, 2, i.regexCache.len())
+	assert.Same(t, a.(*RegexObject).re, b.(*RegexObject).re)
+
+	// once it is full the pattern used least recently makes room, and "a" is used after every new one
+	i, err = interpretSource(`var a = regex("a"); var first = regex("b"); var p = "b"; for (var n = 0; n < 300; n++) { p = p + "b"; regex(p); regex("a"); } var c = regex("a"); var last = regex("b");`)
+	assert.Nil(t, err)
+	assert.Equal(t, maxCachedRegexes, i.regexCache.len())
+
+	a, _ = i.Environment.Get(lexer.Token{Lexeme: "a"})
+	c, _ := i.Environment.Get(lexer.Token{Lexeme: "c"})
+	first, _ := i.Environment.Get(lexer.Token{Lexeme: "first"})
+	last, _ := i.Environment.Get(lexer.Token{Lexeme: "last"})
+	assert.Same(t, a.(*RegexObject).re, c.(*RegexObject).re)
+	assert.NotSame(t, first.(*RegexObject).re, last.(*RegexObject).re)
+}
@@ -0,0 +1,257 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// string natives operate on runes, so indexes and lengths count characters rather than bytes
+func defineStringNatives(env *Environment) {
+	defineNative(env, "len", 1, nativeLen)
+	defineNative(env, "substr", 3, nativeSubstr)
+	defineNative(env, "indexOf", 2, nativeIndexOf)
+	defineNative(env, "split", 2, nativeSplit)
+	defineNative(env, "join", -1, nativeJoin)
+	defineNative(env, "upper", 1, nativeUpper)
+	defineNative(env, "lower", 1, nativeLower)
+	defineNative(env, "trim", 1, nativeTrim)
+	defineNative(env, "replace", 3, nativeReplace)
+	defineNative(env, "startsWith", 2, nativeStartsWith)
+	defineNative(env, "endsWith", 2, nativeEndsWith)
+	defineNative(env, "repeat", 2, nativeRepeat)
+	defineNative(env, "charAt", 2, nativeCharAt)
+	defineNative(env, "ord", 1, nativeOrd)
+	defineNative(env, "chr", 1, nativeChr)
+}
+
+// len(value) returns the number of characters in a string, or the number of entries in a list or map
+func nativeLen(i *Interpreter, args []any) (any, error) {
+	switch value := args[0].(type) {
+	case string:
+		return float64(utf8.RuneCountInString(value)), nil
+	case *LoxList:
+		return float64(value.Len()), nil
+	case *LoxMap:
+		return float64(value.Len()), nil
+	}
+	return nil, fmt.Errorf("len: argument 1 must be a string, list or map, got %s.", typeName(args[0]))
+}
+
+// substr(str, start, end) returns the characters in the half open range [start, end)
+func nativeSubstr(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("substr", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := intArg("substr", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	end, err := intArg("substr", args, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(str)
+	if start < 0 || end > len(runes) || start > end {
+		return nil, fmt.Errorf("substr: range [%d, %d) out of bounds for string of length %d.", start, end, len(runes))
+	}
+	return string(runes[start:end]), nil
+}
+
+// indexOf(str, sub) returns the character index of the first occurrence of sub, or -1
+func nativeIndexOf(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("indexOf", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := stringArg("indexOf", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := strings.Index(str, sub)
+	if idx < 0 {
+		return float64(-1), nil
+	}
+	return float64(utf8.RuneCountInString(str[:idx])), nil
+}
+
+// split(str, sep) returns a list of the pieces of str separated by sep. an empty separator splits str
+// into characters.
+func nativeSplit(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("split", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringArg("split", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := strings.Split(str, sep)
+	items := make([]any, len(pieces))
+	for idx, piece := range pieces {
+		items[idx] = piece
+	}
+	return NewLoxList(items), nil
+}
+
+// join(sep, parts...) concatenates every part with sep in between. parts may also be given as a single list.
+func nativeJoin(i *Interpreter, args []any) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("join: expected a separator.")
+	}
+	sep, err := stringArg("join", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 2 {
+		if list, ok := args[1].(*LoxList); ok {
+			args = append([]any{sep}, list.Values()...)
+		}
+	}
+
+	parts := make([]string, 0, len(args)-1)
+	for idx := 1; idx < len(args); idx++ {
+		part, err := stringArg("join", args, idx)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func nativeUpper(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("upper", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(str), nil
+}
+
+func nativeLower(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("lower", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(str), nil
+}
+
+// trim(str) strips leading and trailing unicode whitespace
+func nativeTrim(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("trim", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(str), nil
+}
+
+// replace(str, old, new) replaces every occurrence of old
+func nativeReplace(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("replace", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	old, err := stringArg("replace", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringArg("replace", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(str, old, replacement), nil
+}
+
+func nativeStartsWith(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("startsWith", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := stringArg("startsWith", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.HasPrefix(str, prefix), nil
+}
+
+func nativeEndsWith(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("endsWith", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := stringArg("endsWith", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return strings.HasSuffix(str, suffix), nil
+}
+
+// maxRepeatLength caps the strings repeat builds, so a typo in the count fails instead of exhausting memory
+const maxRepeatLength = 1 << 28
+
+// repeat(str, count) returns str repeated count times
+func nativeRepeat(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("repeat", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	count, err := intArg("repeat", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, fmt.Errorf("repeat: count must not be negative, got %d.", count)
+	}
+	// dividing instead of multiplying keeps huge counts from overflowing the check itself
+	if count > 0 && len(str) > maxRepeatLength/count {
+		return nil, fmt.Errorf("repeat: result would be longer than %d bytes.", maxRepeatLength)
+	}
+	return strings.Repeat(str, count), nil
+}
+
+// charAt(str, idx) returns the character at idx as a one character string
+func nativeCharAt(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("charAt", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := intArg("charAt", args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(str)
+	if idx < 0 || idx >= len(runes) {
+		return nil, fmt.Errorf("charAt: index %d out of bounds for string of length %d.", idx, len(runes))
+	}
+	return string(runes[idx]), nil
+}
+
+// ord(char) returns the unicode code point of a one character string
+func nativeOrd(i *Interpreter, args []any) (any, error) {
+	str, err := stringArg("ord", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(str) != 1 {
+		return nil, fmt.Errorf("ord: expected a single character, got %q.", str)
+	}
+	r, _ := utf8.DecodeRuneInString(str)
+	return float64(r), nil
+}
+
+// chr(code) returns the one character string for a unicode code point
+func nativeChr(i *Interpreter, args []any) (any, error) {
+	code, err := intArg("chr", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+		return nil, fmt.Errorf("chr: %d is not a valid code point.", code)
+	}
+	return string(rune(code)), nil
+}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func interpretSource(source string) (*Interpreter, *RuntimeError) {
+	return interpretSourceWith(source, nil)
+}
+
+// interpretSourceWith defines globals before running source, handy for strings lox can't spell without escapes
+func interpretSourceWith(source string, globals map[string]any) (*Interpreter, *RuntimeError) {
+	tokens := lexer.NewScanner(source).ScanTokens()
+	stmts := ast.NewParser(tokens).Parse()
+
+	i := NewInterpreter()
+	for name, value := range globals {
+		i.Environment.Define(name, value)
+	}
+	err := i.Interpret(stmts)
+
+	return i, err
+}
+
+type NativeTestCase struct {
+	ID       int
+	Source   string
+	Expected string
+}
+
+var stringNativeCases = []NativeTestCase{
+	{ID: 1, Source: `print len("héllo");`, Expected: "5\n"},
+	{ID: 2, Source: `print len("");`, Expected: "0\n"},
+	{ID: 3, Source: `print substr("héllo wörld", 6, 11);`, Expected: "wörld\n"},
+	{ID: 4, Source: `print indexOf("héllo", "l");`, Expected: "2\n"},
+	{ID: 5, Source: `print indexOf("héllo", "z");`, Expected: "-1\n"},
+	{ID: 6, Source: `print join(", ", "a", "b", "c");`, Expected: "a, b, c\n"},
+	{ID: 7, Source: `print join("-");`, Expected: "\n"},
+	{ID: 8, Source: `print upper("ñandú");`, Expected: "ÑANDÚ\n"},
+	{ID: 9, Source: `print lower("ÀB");`, Expected: "àb\n"},
+	{ID: 10, Source: `print trim("  padded	");`, Expected: "padded\n"},
+	{ID: 11, Source: `print replace("a-b-c", "-", "+");`, Expected: "a+b+c\n"},
+	{ID: 12, Source: `print startsWith("lox", "lo");`, Expected: "true\n"},
+	{ID: 13, Source: `print endsWith("lox", "lo");`, Expected: "false\n"},
+	{ID: 14, Source: `print repeat("ab", 3);`, Expected: "ababab\n"},
+	{ID: 15, Source: `print charAt("日本語", 1);`, Expected: "本\n"},
+	{ID: 16, Source: `print ord("é");`, Expected: "233\n"},
+	{ID: 17, Source: `print chr(26085);`, Expected: "日\n"},
+	{ID: 18, Source: `print split("a,b,c", ",");`, Expected: "[\"a\", \"b\", \"c\"]\n"},
+	{ID: 19, Source: `var chars = split("añb", ""); print chars.length; print chars.get(1);`, Expected: "3\nñ\n"},
+}
+
+func TestStringNatives(t *testing.T) {
+	for _, testCase := range stringNativeCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var stringNativeErrorCases = []NativeTestCase{
+	{ID: 1, Source: `len(1);`, Expected: "len: argument 1 must be a string, list or map, got number."},
+	{ID: 2, Source: `substr("abc", 1.5, 2);`, Expected: "substr: argument 2 must be an integer, got 1.5."},
+	{ID: 3, Source: `substr("abc", 2, 4);`, Expected: "substr: range [2, 4) out of bounds for string of length 3."},
+	{ID: 4, Source: `charAt("abc", -1);`, Expected: "charAt: index -1 out of bounds for string of length 3."},
+	{ID: 5, Source: `ord("ab");`, Expected: `ord: expected a single character, got "ab".`},
+	{ID: 6, Source: `chr(-1);`, Expected: "chr: -1 is not a valid code point."},
+	{ID: 7, Source: `repeat("a", -1);`, Expected: "repeat: count must not be negative, got -1."},
+	{ID: 8, Source: `split("a", 1);`, Expected: "split: argument 2 must be a string, got number."},
+	{ID: 9, Source: `join(", ", "a", nil);`, Expected: "join: argument 3 must be a string, got nil."},
+	{ID: 10, Source: `var x = upper(true);`, Expected: "upper: argument 1 must be a string, got boolean."},
+	{ID: 11, Source: `repeat("ab", 1000000000000000000);`, Expected: "repeat: result would be longer than 268435456 bytes."},
+	{ID: 12, Source: `chr(1000000000000000000000);`, Expected: "chr: argument 1 is out of range, got 1e+21."},
+}
+
+func TestStringNativeErrors(t *testing.T) {
+	for _, testCase := range stringNativeErrorCases {
+		_, err := interpretSource(testCase.Source)
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, err.Message, "test case %d failed", testCase.ID)
+			// errors point at the closing paren of the call
+			assert.Equal(t, lexer.RIGHT_PAREN, err.Token.TokenType, "test case %d failed", testCase.ID)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var operatorCases = []NativeTestCase{
+	// remainder takes the sign of the dividend
+	{ID: 1, Source: `print 7 % 3; print -7 % 3; print 7 % -3; print 5.5 % 2;`, Expected: "1\n-1\n1\n1.5\n"},
+	// integer division truncates toward zero
+	{ID: 2, Source: `print 7 ~/ 2; print -7 ~/ 2; print 7.9 ~/ 1;`, Expected: "3\n-3\n7\n"},
+	{ID: 3, Source: `var a = -7; var b = 3; print (a ~/ b) * b + a % b == a;`, Expected: "true\n"},
+	{ID: 4, Source: `print 2 ** 10; print 2 ** 3 ** 2; print -2 ** 2; print (-2) ** 2; print 4 ** -1;`, Expected: "1024\n512\n-4\n4\n0.25\n"},
+	{ID: 5, Source: `print 12 & 10; print 12 | 10; print 12 ^ 10; print ~5; print ~-1;`, Expected: "8\n14\n6\n-6\n0\n"},
+	{ID: 6, Source: `print 1 << 10; print 1024 >> 3; print -16 >> 2;`, Expected: "1024\n128\n-4\n"},
+	{ID: 7, Source: `print 6 & 3 == 2; print 1 << 1 + 1;`, Expected: "true\n4\n"},
+}
+
+func TestArithmeticOperators(t *testing.T) {
+	for _, testCase := range operatorCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var operatorErrorCases = []NativeTestCase{
+	{ID: 1, Source: `1.5 & 1;`, Expected: "operands must be integers."},
+	{ID: 2, Source: `1 | "a";`, Expected: "operands must be integers."},
+	{ID: 3, Source: `~0.5;`, Expected: "operand must be an integer."},
+	{ID: 4, Source: `1 << 64;`, Expected: "shift count must be between 0 and 63."},
+	{ID: 5, Source: `1 >> -1;`, Expected: "shift count must be between 0 and 63."},
+	{ID: 6, Source: `1 % 0;`, Expected: "division by zero."},
+	{ID: 7, Source: `1 ~/ 0;`, Expected: "division by zero."},
+	{ID: 8, Source: `"a" % 2;`, Expected: "operands must be numbers."},
+	{ID: 9, Source: `2 ** nil;`, Expected: "operands must be numbers."},
+}
+
+func TestArithmeticOperatorErrors(t *testing.T) {
+	for _, testCase := range operatorErrorCases {
+		_, err := interpretSource(testCase.Source)
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, err.Message, "test case %d failed", testCase.ID)
+		}
+	}
+}
+
+var updateCases = []NativeTestCase{
+	{ID: 1, Source: `var a = 1; a += 2; print a; a -= 5; print a; a *= -3; print a; a /= 4; print a;`, Expected: "3\n-2\n6\n1.5\n"},
+	{ID: 2, Source: `var s = "lo"; s += "x"; print s;`, Expected: "lox\n"},
+	{ID: 3, Source: `var a = 1; print a += 1; print a;`, Expected: "2\n2\n"},
+	{ID: 4, Source: `var a = 1; print a++; print a; print ++a; print a;`, Expected: "1\n2\n3\n3\n"},
+	{ID: 5, Source: `var a = 1; print a--; print --a;`, Expected: "1\n-1\n"},
+	{ID: 6, Source: `for (var i = 0; i < 3; i++) print i;`, Expected: "0\n1\n2\n"},
+	{ID: 7, Source: `var a = 1; var b = 2; a += b += 3; print a; print b;`, Expected: "6\n5\n"},
+	// the right hand side is evaluated once, after the variable has been read
+	{ID: 8, Source: `var calls = 0; var total = 10; fun bump() { calls++; total = 100; return 1; } total += bump(); print total; print calls;`, Expected: "11\n1\n"},
+	{ID: 9, Source: `var a = 1; { var b = a++; print b; } print a;`, Expected: "1\n2\n"},
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	for _, testCase := range updateCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var updateErrorCases = []NativeTestCase{
+	{ID: 1, Source: `var s = "a"; s -= 1;`, Expected: "operands must be numbers."},
+	{ID: 2, Source: `var s = "a"; s += 1;`, Expected: "operands must be two numbers or two strings."},
+	{ID: 3, Source: `var s = "a"; s++;`, Expected: "operand must be a number."},
+	{ID: 4, Source: `missing += 1;`, Expected: "undefined variable 'missing'."},
+	{ID: 5, Source: `--missing;`, Expected: "undefined variable 'missing'."},
+}
+
+func TestCompoundAssignmentErrors(t *testing.T) {
+	for _, testCase := range updateErrorCases {
+		_, err := interpretSource(testCase.Source)
+
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, err.Message, "test case %d failed", testCase.ID)
+		}
+	}
+
+	// errors point at the compound operator
+	_, err := interpretSource(`var s = "a"; s *= 2;`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "*=", err.Token.Lexeme)
+	}
+}
+
+var conditionalCases = []NativeTestCase{
+	{ID: 1, Source: `print true ? "yes" : "no"; print nil ? "yes" : "no";`, Expected: "yes\nno\n"},
+	{ID: 2, Source: `var n = 15; print n < 10 ? "small" : n < 20 ? "medium" : "large";`, Expected: "medium\n"},
+	// only the chosen branch is evaluated
+	{ID: 3, Source: `var a = 0; var b = 0; var r = true ? a++ : b++; print a; print b; print r;`, Expected: "1\n0\n0\n"},
+	{ID: 4, Source: `var x = false ? undefinedVariable : 2; print x;`, Expected: "2\n"},
+	// the comma operator evaluates left to right and yields the last operand
+	{ID: 5, Source: `var a = 1; var b = (a++, a++, a); print b; print a;`, Expected: "3\n3\n"},
+	{ID: 6, Source: `print join("-", "a", ("x", "b"));`, Expected: "a-b\n"},
+	{ID: 7, Source: `var j = 0; for (var i = 0; i < 3; i++, j += 2) print j;`, Expected: "0\n2\n4\n"},
+}
+
+func TestConditionalAndComma(t *testing.T) {
+	for _, testCase := range conditionalCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+}
+
+var nilSafetyCases = []NativeTestCase{
+	{ID: 1, Source: `print nil ?? "default"; print false ?? "default"; print 0 ?? 1;`, Expected: "default\nfalse\n0\n"},
+	{ID: 2, Source: `var a; var b; print a ?? b ?? "last";`, Expected: "last\n"},
+	// the right operand is only evaluated when needed
+	{ID: 3, Source: `var n = 0; var x = 1 ?? n++; print n;`, Expected: "0\n"},
+	{ID: 4, Source: `var m = nil; print m?.get("a");`, Expected: "<nil>\n"},
+	// a nil receiver skips the rest of the chain, including argument evaluation
+	{ID: 5, Source: `var m = nil; var n = 0; print m?.get(n++).missing.length; print n;`, Expected: "<nil>\n0\n"},
+	{ID: 6, Source: `var m = dict(); m.set("a", "xyz"); print m?.get("a"); print len(m?.keys());`, Expected: "xyz\n1\n"},
+	{ID: 7, Source: `var f = nil; print f?.(1, 2); print len?.("abc");`, Expected: "<nil>\n3\n"},
+	{ID: 8, Source: `var m = dict(); print m.get("config")?.get("port") ?? 8080;`, Expected: "8080\n"},
+}
+
+func TestNilCoalescingAndOptionalChaining(t *testing.T) {
+	for _, testCase := range nilSafetyCases {
+		i, err := interpretSource(testCase.Source)
+
+		assert.Nil(t, err, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, i.Output.String(), "test case %d failed", testCase.ID)
+	}
+
+	// only the links marked with `?.` tolerate nil
+	_, err := interpretSource(`var m = dict(); m.get("a")?.b.c;`)
+	assert.Nil(t, err)
+
+	_, err = interpretSource(`var m = dict(); m.get("a").b;`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "only objects have properties, got nil.", err.Message)
+	}
+
+	// grouping ends the chain
+	_, err = interpretSource(`var m = nil; (m?.a).b;`)
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "only objects have properties, got nil.", err.Message)
+	}
+}
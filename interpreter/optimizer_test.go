@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/optimizer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func interpretOptimized(source string, globals map[string]any) (*Interpreter, *RuntimeError) {
+	tokens := lexer.NewScanner(source).ScanTokens()
+	stmts := ast.NewParser(tokens).Parse()
+
+	i := NewInterpreter()
+	for name, value := range globals {
+		i.Environment.Define(name, value)
+	}
+	stmts = optimizer.NewOptimizer(i.Evaluate).Optimize(stmts)
+	err := i.Interpret(stmts)
+
+	return i, err
+}
+
+// every test program must print the same output and fail with the same error, at the same line,
+// whether or not it was optimized first
+func TestOptimizerEquivalence(t *testing.T) {
+	programs := []JSONTestCase{}
+	for _, cases := range [][]NativeTestCase{
+		stringNativeCases, stringNativeErrorCases, regexNativeCases, regexNativeErrorCases,
+		operatorCases, operatorErrorCases, updateCases, updateErrorCases, conditionalCases, nilSafetyCases,
+		matchCases, returnCases, optimizerCases,
+	} {
+		for _, testCase := range cases {
+			programs = append(programs, JSONTestCase{ID: testCase.ID, Source: testCase.Source})
+		}
+	}
+	programs = append(programs, jsonNativeCases...)
+	programs = append(programs, jsonNativeErrorCases...)
+
+	for _, testCase := range programs {
+		globals := map[string]any{"doc": testCase.Doc}
+		plain, plainErr := interpretSourceWith(testCase.Source, globals)
+		optimized, optimizedErr := interpretOptimized(testCase.Source, globals)
+
+		assert.Equal(t, plain.Output.String(), optimized.Output.String(), "program %q", testCase.Source)
+		if plainErr == nil || optimizedErr == nil {
+			assert.Equal(t, plainErr == nil, optimizedErr == nil, "program %q", testCase.Source)
+			continue
+		}
+		assert.Equal(t, plainErr.Message, optimizedErr.Message, "program %q", testCase.Source)
+		assert.Equal(t, plainErr.Token.Line, optimizedErr.Token.Line, "program %q", testCase.Source)
+	}
+}
+
+var optimizerCases = []NativeTestCase{
+	{ID: 1, Source: "print 60 * 60 * 24; print 2 ** 10 - 1; print 7 ~/ 2;"},
+	{ID: 2, Source: "if (1 > 2) print \"no\"; else if (nil ?? true) print \"yes\";\nwhile (false) print \"never\";"},
+	{ID: 3, Source: "fun f(a) {\n  return a * 1 - 0;\n  print \"dead\";\n}\nprint f(3);\nprint f(\"s\");"},
+	{ID: 4, Source: "var x = 1;\nprint false and x;\nprint true or x;\nprint x or \"unused\";"},
+	{ID: 5, Source: "print 1;\nprint (1 + 2) + \"a\";"},
+	{ID: 6, Source: "print -(-(-0));\nprint 0 - 0;\nprint -0 + 0;"},
+	{ID: 7, Source: "var i = 0; for (;1 < 0;) print i; for (var j = 0; j < 3; j++) i += j; print i;"},
+}
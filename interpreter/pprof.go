@@ -0,0 +1,182 @@
+package interpreter
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// WritePprof writes the profile as a gzipped profile.proto, the format `go tool pprof` reads. samples
+// carry two values, calls and wall nanoseconds, each Lox function is a pprof function in p.File and each
+// line of one a location
+func (p *Profiler) WritePprof(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	indices := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		idx, ok := indices[s]
+		if !ok {
+			idx = int64(len(table))
+			indices[s] = idx
+			table = append(table, s)
+		}
+		return idx
+	}
+
+	var profile protoBuffer
+	valueType := func(field int, kind, unit string) {
+		profile.message(field, func(b *protoBuffer) {
+			b.int64(1, str(kind))
+			b.int64(2, str(unit))
+		})
+	}
+	valueType(1, "calls", "count")
+	valueType(1, "wall", "nanoseconds")
+
+	// ids are handed out in the order locations and functions are first seen, starting at 1 as pprof requires
+	type function struct {
+		name  string
+		start int
+	}
+	functionIDs := map[function]uint64{}
+	functionOrder := []function{}
+	locationIDs := map[profileLocation]uint64{}
+	locationOrder := []profileLocation{}
+
+	// each node is a location below its parent's stack, so a sample's stack is its node's location
+	// followed by those of its ancestors
+	nodeLocations := make([]uint64, len(p.nodes))
+	for node := 1; node < len(p.nodes); node++ {
+		location := p.nodes[node].location
+		id, ok := locationIDs[location]
+		if !ok {
+			id = uint64(len(locationOrder) + 1)
+			locationIDs[location] = id
+			locationOrder = append(locationOrder, location)
+		}
+		nodeLocations[node] = id
+
+		fn := function{location.Function, location.Start}
+		if _, ok := functionIDs[fn]; !ok {
+			functionIDs[fn] = uint64(len(functionOrder) + 1)
+			functionOrder = append(functionOrder, fn)
+		}
+	}
+
+	ids := []uint64{}
+	for sample := 1; sample < len(p.nodes); sample++ {
+		ids = ids[:0]
+		for node := sample; node != 0; node = p.nodes[node].parent {
+			ids = append(ids, nodeLocations[node])
+		}
+
+		profile.message(2, func(b *protoBuffer) {
+			b.packedUint64(1, ids)
+			b.packedInt64(2, []int64{p.nodes[sample].calls, p.nodes[sample].wall.Nanoseconds()})
+		})
+	}
+
+	for idx, location := range locationOrder {
+		profile.message(4, func(b *protoBuffer) {
+			b.uint64(1, uint64(idx+1))
+			b.message(4, func(line *protoBuffer) {
+				line.uint64(1, functionIDs[function{location.Function, location.Start}])
+				line.int64(2, int64(location.Line))
+			})
+		})
+	}
+
+	for idx, fn := range functionOrder {
+		profile.message(5, func(b *protoBuffer) {
+			b.uint64(1, uint64(idx+1))
+			b.int64(2, str(fn.name))
+			b.int64(3, str(fn.name))
+			b.int64(4, str(p.File))
+			b.int64(5, int64(fn.start))
+		})
+	}
+
+	// every string has been interned by now, the table goes after everything that refers to it
+	timeNanos := p.started.UnixNano()
+	durationNanos := p.now().Sub(p.started).Nanoseconds()
+	defaultType := str("wall")
+	for _, s := range table {
+		profile.bytes(6, []byte(s))
+	}
+	profile.int64(9, timeNanos)
+	profile.int64(10, durationNanos)
+	valueType(11, "wall", "nanoseconds")
+	profile.int64(12, 1)
+	profile.int64(14, defaultType)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// protoBuffer encodes the handful of protobuf wire types profile.proto needs
+type protoBuffer struct {
+	data []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.data = append(b.data, byte(v)|0x80)
+		v >>= 7
+	}
+	b.data = append(b.data, byte(v))
+}
+
+func (b *protoBuffer) key(field, wire int) {
+	b.varint(uint64(field<<3 | wire))
+}
+
+// uint64 and int64 leave out zero values, which are the default every reader fills in
+func (b *protoBuffer) uint64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int64(field int, v int64) {
+	b.uint64(field, uint64(v))
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protoBuffer) packedUint64(field int, vs []uint64) {
+	var packed protoBuffer
+	for _, v := range vs {
+		packed.varint(v)
+	}
+	b.bytes(field, packed.data)
+}
+
+func (b *protoBuffer) packedInt64(field int, vs []int64) {
+	var packed protoBuffer
+	for _, v := range vs {
+		packed.varint(uint64(v))
+	}
+	b.bytes(field, packed.data)
+}
+
+// message writes a nested message, which encode fills in
+func (b *protoBuffer) message(field int, encode func(*protoBuffer)) {
+	var nested protoBuffer
+	encode(&nested)
+	b.bytes(field, nested.data)
+}
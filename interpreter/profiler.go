@@ -0,0 +1,365 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	ast "github.com/brandonshearin/go-lox/parser"
+)
+
+// scriptFunction names the top-level code of a script in profiles. no Lox function can be called that,
+// and pprof would take anything in angle brackets for C++ template arguments and hide it
+const scriptFunction = "top-level"
+
+// Profiler attributes the wall time a program spends, and the calls it makes, to the Lox functions and
+// source lines responsible. set it as an Interpreter's Profiler before running, every statement and call
+// is timed until it is removed. spawned tasks and generators are profiled into the same Profiler.
+// without one the interpreter pays a nil check per statement and call
+type Profiler struct {
+	// File names the script in reports
+	File string
+
+	mu      sync.Mutex
+	now     func() time.Time
+	started time.Time
+	// every call stack seen so far as a tree, nodes[0] stands for the empty stack. nodes are added in the
+	// order they're first seen, so reports are stable
+	nodes    []profileNode
+	children map[profileEdge]int
+}
+
+// NewProfiler creates a profiler for the script in file, starting its clock
+func NewProfiler(file string) *Profiler {
+	p := &Profiler{
+		File:     file,
+		now:      time.Now,
+		nodes:    []profileNode{{}},
+		children: map[profileEdge]int{},
+	}
+	p.started = p.now()
+	return p
+}
+
+// profileLocation is a line in a function, the unit time and calls are attributed to
+type profileLocation struct {
+	Function string
+	Start    int // the line the function is declared on, 0 for the script
+	Line     int
+}
+
+// profileNode totals what was spent at one call stack: its location, below the stack of its parent
+type profileNode struct {
+	parent   int
+	location profileLocation
+	calls    int64
+	wall     time.Duration
+}
+
+type profileEdge struct {
+	parent   int
+	location profileLocation
+}
+
+// intern returns the node for location running below the stack parent, adding it the first time
+func (p *Profiler) intern(parent int, location profileLocation) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	edge := profileEdge{parent, location}
+	node, ok := p.children[edge]
+	if !ok {
+		node = len(p.nodes)
+		p.nodes = append(p.nodes, profileNode{parent: parent, location: location})
+		p.children[edge] = node
+	}
+	return node
+}
+
+// record adds to the totals of node
+func (p *Profiler) record(node int, calls int64, wall time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nodes[node].calls += calls
+	p.nodes[node].wall += wall
+}
+
+// profileStack is the part of a profile that belongs to one thread of execution: the frames of the Lox
+// functions it is in and the statements it has started but not finished
+type profileStack struct {
+	frames []profileFrame
+	spans  []profileSpan
+}
+
+type profileFrame struct {
+	function string
+	start    int
+	parent   int // the node of the call site, 0 for the outermost frame
+	node     int // of the statement running in the frame
+}
+
+type profileSpan struct {
+	started time.Time
+	// time inside the statement that is attributed elsewhere, to the statements nested in it, to a
+	// generator it was waiting on or to the profiler itself
+	nested time.Duration
+}
+
+// exclude takes d out of the innermost running statement's own time
+func (s *profileStack) exclude(d time.Duration) {
+	if s != nil && len(s.spans) > 0 {
+		s.spans[len(s.spans)-1].nested += d
+	}
+}
+
+// caller is the node of the innermost running statement, where a call made now is made from
+func (s *profileStack) caller() int {
+	if len(s.frames) == 0 {
+		return 0
+	}
+	return s.frames[len(s.frames)-1].node
+}
+
+func (s *Interpreter) profileStack() *profileStack {
+	if s.profile == nil {
+		s.profile = &profileStack{}
+	}
+	return s.profile
+}
+
+// executeProfiled runs a statement, charging the time it took, less that of the statements and calls
+// nested in it, to the line it is on. the time the profiler spends keeping track is charged to nothing
+func (s *Interpreter) executeProfiled(stmt ast.Stmt) error {
+	entered := s.Profiler.now()
+	stack := s.profileStack()
+
+	// top-level code runs in the script's frame, which only exists while it does. callbacks the event
+	// loop runs later start from an empty stack, without it
+	script := len(stack.frames) == 0
+	if script {
+		stack.frames = append(stack.frames, profileFrame{
+			function: scriptFunction,
+			node:     s.Profiler.intern(0, profileLocation{Function: scriptFunction}),
+		})
+	}
+	frame := len(stack.frames) - 1
+	enclosing := stack.frames[frame].node
+	// statements the optimizer or parser made up have no line, they count towards the one they're in
+	if line := ast.StmtLine(stmt); line > 0 {
+		f := stack.frames[frame]
+		stack.frames[frame].node = s.Profiler.intern(f.parent, profileLocation{Function: f.function, Start: f.start, Line: line})
+	}
+	stack.spans = append(stack.spans, profileSpan{started: s.Profiler.now()})
+
+	err := stmt.Accept(s)
+
+	finished := s.Profiler.now()
+	span := stack.spans[len(stack.spans)-1]
+	stack.spans = stack.spans[:len(stack.spans)-1]
+	s.Profiler.record(stack.frames[frame].node, 0, finished.Sub(span.started)-span.nested)
+
+	stack.frames[frame].node = enclosing
+	if script {
+		stack.frames = stack.frames[:frame]
+	}
+	stack.exclude(s.Profiler.now().Sub(entered))
+	return err
+}
+
+// enterProfiled counts a call to function and makes it the running frame, until exitProfiled
+func (s *Interpreter) enterProfiled(function *LoxFunction) {
+	entered := s.Profiler.now()
+	stack := s.profileStack()
+	name := function.Declaration.Name
+
+	caller := stack.caller()
+	node := s.Profiler.intern(caller, profileLocation{Function: name.Lexeme, Start: name.Line, Line: name.Line})
+	s.Profiler.record(node, 1, 0)
+	stack.frames = append(stack.frames, profileFrame{function: name.Lexeme, start: name.Line, parent: caller, node: node})
+
+	stack.exclude(s.Profiler.now().Sub(entered))
+}
+
+func (s *Interpreter) exitProfiled() {
+	stack := s.profileStack()
+	stack.frames = stack.frames[:len(stack.frames)-1]
+}
+
+// resumeProfiled is called as a consumer resumes a generator, and returns the function to call once
+// the generator pauses. the body keeps its own stack on its own interpreter, the time it ran only has
+// to be taken out of the consumer's statement
+func (s *Interpreter) resumeProfiled() (pause func()) {
+	resumed := s.Profiler.now()
+	return func() {
+		s.profile.exclude(s.Profiler.now().Sub(resumed))
+	}
+}
+
+// pauseProfiled is called as a generator's body yields, and returns the function to call once it is
+// resumed or unwound. the time it spent paused doesn't count towards its statements
+func (s *Interpreter) pauseProfiled() (resume func()) {
+	paused := s.Profiler.now()
+	return func() {
+		s.profile.exclude(s.Profiler.now().Sub(paused))
+	}
+}
+
+// FunctionProfile is what a profile attributes to one function
+type FunctionProfile struct {
+	Name  string
+	Line  int // the line the function is declared on, 0 for the script
+	Calls int64
+	// Self is the time spent running the function's own statements, Total adds the functions it called
+	Self, Total time.Duration
+}
+
+// LineProfile is what a profile attributes to one line of a function
+type LineProfile struct {
+	Function    string
+	Line        int
+	Self, Total time.Duration
+}
+
+// profileTotal is what a profile attributes to a group of locations, like the lines of a function
+type profileTotal struct {
+	calls       int64
+	self, total time.Duration
+}
+
+// totalProfile folds the call tree into a total for each key. self is what was spent at the key's
+// locations themselves, total adds everything called from them, counted once however many times the key
+// is on a stack. keys come in the order they are first seen, leaving out those that only ever cost
+// nothing when idle is false. the caller holds p.mu
+func totalProfile[K comparable](p *Profiler, key func(profileLocation) K, idle bool) (map[K]*profileTotal, []K) {
+	// nodes are added after their parents, so going backwards reaches every child before its parent
+	subtree := make([]time.Duration, len(p.nodes))
+	children := make([][]int, len(p.nodes))
+	for id := len(p.nodes) - 1; id > 0; id-- {
+		node := p.nodes[id]
+		subtree[id] += node.wall
+		subtree[node.parent] += subtree[id]
+	}
+
+	totals := map[K]*profileTotal{}
+	order := []K{}
+	for id := 1; id < len(p.nodes); id++ {
+		node := p.nodes[id]
+		children[node.parent] = append(children[node.parent], id)
+		if k := key(node.location); totals[k] == nil && (idle || subtree[id] != 0) {
+			totals[k] = &profileTotal{}
+			order = append(order, k)
+		}
+	}
+
+	// walk the tree keeping count of the keys on the current stack. a negative id leaves -id
+	onStack := map[K]int{}
+	pending := append([]int{}, children[0]...)
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if id < 0 {
+			onStack[key(p.nodes[-id].location)]--
+			continue
+		}
+
+		node := p.nodes[id]
+		k := key(node.location)
+		if total := totals[k]; total != nil {
+			total.calls += node.calls
+			total.self += node.wall
+			// a recursive function is on the stack more than once, but its time only counts once
+			if onStack[k] == 0 {
+				total.total += subtree[id]
+			}
+		}
+		onStack[k]++
+		pending = append(append(pending, -id), children[id]...)
+	}
+	return totals, order
+}
+
+// Functions totals the profile by function, most expensive first
+func (p *Profiler) Functions() []FunctionProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	type key struct {
+		name  string
+		start int
+	}
+	totals, order := totalProfile(p, func(location profileLocation) key {
+		return key{location.Function, location.Start}
+	}, true)
+
+	functions := make([]FunctionProfile, len(order))
+	for i, k := range order {
+		total := totals[k]
+		functions[i] = FunctionProfile{Name: k.name, Line: k.start, Calls: total.calls, Self: total.self, Total: total.total}
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		if functions[i].Self != functions[j].Self {
+			return functions[i].Self > functions[j].Self
+		}
+		return functions[i].Total > functions[j].Total
+	})
+	return functions
+}
+
+// Lines totals the profile by source line, most expensive first. the line a function is declared on
+// only has calls attributed to it, so it is left out unless it also runs statements
+func (p *Profiler) Lines() []LineProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	totals, order := totalProfile(p, func(location profileLocation) profileLocation { return location }, false)
+
+	lines := make([]LineProfile, len(order))
+	for i, location := range order {
+		total := totals[location]
+		lines[i] = LineProfile{Function: location.Function, Line: location.Line, Self: total.self, Total: total.total}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].Self != lines[j].Self {
+			return lines[i].Self > lines[j].Self
+		}
+		return lines[i].Total > lines[j].Total
+	})
+	return lines
+}
+
+func profileMilliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+}
+
+// WriteSummary writes the n most expensive functions and lines as plain text
+func (p *Profiler) WriteSummary(w io.Writer, n int) error {
+	functions, lines := p.Functions(), p.Lines()
+
+	var wall time.Duration
+	var calls int64
+	for _, function := range functions {
+		wall += function.Self
+		calls += function.Calls
+	}
+	fmt.Fprintf(w, "profile of %s: %s in lox code, %d calls\n\n", p.File, profileMilliseconds(wall), calls)
+
+	fmt.Fprintf(w, "%10s %10s %8s  %s\n", "self", "total", "calls", "function")
+	for _, function := range functions[:min(n, len(functions))] {
+		name := function.Name
+		if function.Line > 0 {
+			name = fmt.Sprintf("%s (line %d)", name, function.Line)
+		}
+		fmt.Fprintf(w, "%10s %10s %8d  %s\n", profileMilliseconds(function.Self), profileMilliseconds(function.Total), function.Calls, name)
+	}
+
+	fmt.Fprintf(w, "\n%10s %10s  %s\n", "self", "total", "line")
+	for _, line := range lines[:min(n, len(lines))] {
+		if _, err := fmt.Fprintf(w, "%10s %10s  %s:%d in %s\n", profileMilliseconds(line.Self), profileMilliseconds(line.Total), p.File, line.Line, line.Function); err != nil {
+			return err
+		}
+	}
+	return nil
+}
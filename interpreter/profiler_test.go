@@ -0,0 +1,134 @@
+package interpreter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	ast "github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// profile runs source with a profiler whose clock moves forward a millisecond every time it is read
+func profile(t *testing.T, source string) *Profiler {
+	p := NewProfiler("script.lox")
+	clock := time.Unix(0, 0)
+	p.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	i := NewInterpreter()
+	i.Stdout = io.Discard
+	i.Profiler = p
+	stmts := ast.NewParser(lexer.NewScanner(source).ScanTokens()).Parse()
+	assert.Nil(t, i.Interpret(stmts))
+	return p
+}
+
+func TestProfiler(t *testing.T) {
+	p := profile(t, "fun f() {\n  print 1;\n}\nf();\nf();")
+
+	// the time the profiler spends between its own clock reads isn't charged to anything. the step
+	// between a statement's reads and those of the call and statement nested in it is, so `print 1;`
+	// takes 1ms and `f();` 3ms
+	assert.Equal(t, []FunctionProfile{
+		{Name: "top-level", Calls: 0, Self: 7 * time.Millisecond, Total: 9 * time.Millisecond},
+		{Name: "f", Line: 1, Calls: 2, Self: 2 * time.Millisecond, Total: 2 * time.Millisecond},
+	}, p.Functions())
+
+	assert.Equal(t, []LineProfile{
+		{Function: "top-level", Line: 4, Self: 3 * time.Millisecond, Total: 4 * time.Millisecond},
+		{Function: "top-level", Line: 5, Self: 3 * time.Millisecond, Total: 4 * time.Millisecond},
+		{Function: "f", Line: 2, Self: 2 * time.Millisecond, Total: 2 * time.Millisecond},
+		{Function: "top-level", Line: 1, Self: 1 * time.Millisecond, Total: 1 * time.Millisecond},
+	}, p.Lines())
+
+	var summary bytes.Buffer
+	assert.Nil(t, p.WriteSummary(&summary, 1))
+	assert.Equal(t, `profile of script.lox: 9.00ms in lox code, 2 calls
+
+      self      total    calls  function
+    7.00ms     9.00ms        0  top-level
+
+      self      total  line
+    3.00ms     4.00ms  script.lox:4 in top-level
+`, summary.String())
+}
+
+func TestProfilerCallsAndGenerators(t *testing.T) {
+	p := profile(t, `fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); }
+fun* count(n) { for (var i = 0; i < n; i = i + 1) yield i; }
+fun last(n) { return fib(n); }
+var g = count(3);
+while (!g.done()) g.next();
+last(5);`)
+
+	calls := map[string]int64{}
+	for _, function := range p.Functions() {
+		calls[function.Name] = function.Calls
+		assert.LessOrEqual(t, function.Self, function.Total, function.Name)
+	}
+	// a tail call is still a call, and a generator is called once however many values it yields
+	assert.Equal(t, map[string]int64{"top-level": 0, "fib": 15, "count": 1, "last": 1}, calls)
+
+	// the generator's lines are attributed to it rather than to the loop consuming it
+	lines := map[string][]int{}
+	for _, line := range p.Lines() {
+		lines[line.Function] = append(lines[line.Function], line.Line)
+	}
+	assert.Equal(t, []int{2}, lines["count"])
+	assert.Contains(t, lines["top-level"], 5)
+}
+
+func TestWritePprof(t *testing.T) {
+	p := profile(t, "fun f() {\n  print 1;\n}\nf();")
+
+	var out bytes.Buffer
+	assert.Nil(t, p.WritePprof(&out))
+
+	reader, err := gzip.NewReader(&out)
+	if !assert.Nil(t, err) {
+		return
+	}
+	data, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	for _, s := range []string{"calls", "count", "wall", "nanoseconds", "top-level", "f", "script.lox"} {
+		assert.Contains(t, string(data), s)
+	}
+}
+
+func TestProtoBuffer(t *testing.T) {
+	var b protoBuffer
+	b.uint64(1, 150)
+	b.uint64(2, 0)
+	b.message(3, func(nested *protoBuffer) { nested.packedInt64(1, []int64{1, 300}) })
+	b.bytes(4, []byte("hi"))
+
+	// the examples of the protobuf encoding guide, zero values are left out
+	assert.Equal(t, []byte{0x08, 0x96, 0x01, 0x1a, 0x05, 0x0a, 0x03, 0x01, 0xac, 0x02, 0x22, 0x02, 'h', 'i'}, b.data)
+}
+
+func TestProfilerDeepRecursion(t *testing.T) {
+	p := profile(t, "fun deep(n) {\n  if (n > 0) deep(n - 1);\n}\ndeep(200);")
+
+	functions := p.Functions()
+	assert.Equal(t, "deep", functions[0].Name)
+	assert.Equal(t, int64(201), functions[0].Calls)
+
+	// every level is its own stack, reaching from the innermost call back to the script
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	deepest := 0
+	for id := range p.nodes {
+		depth := 0
+		for node := id; node != 0; node = p.nodes[node].parent {
+			depth++
+		}
+		deepest = max(deepest, depth)
+	}
+	assert.Equal(t, 202, deepest)
+}
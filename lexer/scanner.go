@@ -3,6 +3,7 @@ package lexer
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -12,6 +13,8 @@ type Scanner struct {
 	tokens []Token
 
 	Errors []string
+	// comments never become tokens, they are kept to the side for tools like the linter
+	Comments []Comment
 
 	start   int
 	current int
@@ -23,21 +26,25 @@ type Scanner struct {
 func NewScanner(source string) *Scanner {
 	reservedWords := map[string]TokenType{
 		"and":    AND,
+		"case":   CASE,
 		"class":  CLASS,
 		"else":   ELSE,
 		"false":  FALSE,
 		"for":    FOR,
 		"fun":    FUN,
 		"if":     IF,
+		"match":  MATCH,
 		"nil":    NIL,
 		"or":     OR,
 		"print":  PRINT,
 		"return": RETURN,
+		"spawn":  SPAWN,
 		"super":  SUPER,
 		"this":   THIS,
 		"true":   TRUE,
 		"var":    VAR,
 		"while":  WHILE,
+		"yield":  YIELD,
 	}
 
 	return &Scanner{
@@ -53,6 +60,13 @@ func NewScanner(source string) *Scanner {
 }
 
 func (s *Scanner) ScanTokens() []Token {
+	// a `#!/usr/bin/env lox` first line lets scripts be executed directly, lox itself ignores it
+	if strings.HasPrefix(s.source, "#!") {
+		for s.peek() != "\n" && !s.isAtEnd() {
+			s.advance()
+		}
+	}
+
 	for !s.isAtEnd() {
 		// we are at the beginning of next lexeme
 		s.start = s.current
@@ -86,13 +100,58 @@ func (s *Scanner) scanToken() {
 	case ".":
 		s.addToken(DOT)
 	case "-":
-		s.addToken(MINUS)
+		if s.match("-") {
+			s.addToken(MINUS_MINUS)
+		} else if s.match("=") {
+			s.addToken(MINUS_EQUAL)
+		} else {
+			s.addToken(MINUS)
+		}
 	case "+":
-		s.addToken(PLUS)
+		if s.match("+") {
+			s.addToken(PLUS_PLUS)
+		} else if s.match("=") {
+			s.addToken(PLUS_EQUAL)
+		} else {
+			s.addToken(PLUS)
+		}
 	case ";":
 		s.addToken(SEMICOLON)
 	case "*":
-		s.addToken(STAR)
+		if s.match("*") {
+			s.addToken(STAR_STAR)
+		} else if s.match("=") {
+			s.addToken(STAR_EQUAL)
+		} else {
+			s.addToken(STAR)
+		}
+	case "%":
+		s.addToken(PERCENT)
+	case "&":
+		s.addToken(AMPERSAND)
+	case "|":
+		s.addToken(PIPE)
+	case "^":
+		s.addToken(CARET)
+	case "?":
+		if s.match("?") {
+			s.addToken(QUESTION_QUESTION)
+		} else if s.peek() == "." && !isDigit(s.peekNext()) {
+			// like javascript, `x?.5:1` is a conditional, so `?.` is never followed by a digit
+			s.advance()
+			s.addToken(QUESTION_DOT)
+		} else {
+			s.addToken(QUESTION)
+		}
+	case ":":
+		s.addToken(COLON)
+	case "~":
+		// `//` already starts a comment, so integer division is spelled `~/`
+		if matches := s.match("/"); matches {
+			s.addToken(TILDE_SLASH)
+		} else {
+			s.addToken(TILDE)
+		}
 	case "!":
 		matches := s.match("=")
 		if matches {
@@ -101,23 +160,26 @@ func (s *Scanner) scanToken() {
 			s.addToken(BANG)
 		}
 	case "=":
-		matches := s.match("=")
-		if matches {
+		if s.match("=") {
 			s.addToken(EQUAL_EQUAL)
+		} else if s.match(">") {
+			s.addToken(EQUAL_GREATER)
 		} else {
 			s.addToken(EQUAL)
 		}
 	case "<":
-		matches := s.match("=")
-		if matches {
+		if s.match("=") {
 			s.addToken(LESS_EQUAL)
+		} else if s.match("<") {
+			s.addToken(LESS_LESS)
 		} else {
 			s.addToken(LESS)
 		}
 	case ">":
-		matches := s.match("=")
-		if matches {
+		if s.match("=") {
 			s.addToken(GREATER_EQUAL)
+		} else if s.match(">") {
+			s.addToken(GREATER_GREATER)
 		} else {
 			s.addToken(GREATER)
 		}
@@ -126,6 +188,12 @@ func (s *Scanner) scanToken() {
 			for s.peek() != "\n" && !s.isAtEnd() {
 				s.advance()
 			}
+			s.Comments = append(s.Comments, Comment{
+				Text: s.source[s.start+2 : s.current],
+				Line: s.line,
+			})
+		} else if s.match("=") {
+			s.addToken(SLASH_EQUAL)
 		} else {
 			s.addToken(SLASH)
 		}
@@ -230,7 +298,7 @@ func isAlpha(c string) bool {
 	}
 
 	runeValue := []rune(c)[0]
-	return unicode.IsLetter(runeValue)
+	return unicode.IsLetter(runeValue) || runeValue == '_'
 }
 
 func isAlphaNumeric(c string) bool {
@@ -252,7 +320,7 @@ func (s *Scanner) number() {
 		}
 	}
 
-	if float, err := strconv.ParseFloat(s.source[s.start:s.current], 32); err != nil {
+	if float, err := strconv.ParseFloat(s.source[s.start:s.current], 64); err != nil {
 		s.handleError(s.line, "there was an error parsing the number")
 	} else {
 		s.addTokenWithLiteral(NUMBER, float)
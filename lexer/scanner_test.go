@@ -62,8 +62,8 @@ var testCases = []ScannerTestCase{
 		ID:         3,
 		Source:     `!*+-/=<> <= == // some operators`,
 		Lines:      1,
-		NumTokens:  11,
-		TokenTypes: []TokenType{BANG, STAR, PLUS, MINUS, SLASH, EQUAL, LESS, GREATER, LESS_EQUAL, EQUAL_EQUAL, EOF},
+		NumTokens:  10,
+		TokenTypes: []TokenType{BANG, STAR, PLUS, MINUS, SLASH_EQUAL, LESS, GREATER, LESS_EQUAL, EQUAL_EQUAL, EOF},
 	},
 	{
 		ID:         4,
@@ -86,6 +86,48 @@ var testCases = []ScannerTestCase{
 		NumTokens:  3,
 		TokenTypes: []TokenType{FOR, WHILE, EOF},
 	},
+	{
+		ID:         11,
+		Source:     "% ** * ~/ ~ & | ^ << >> <<= // integer division is ~/",
+		Lines:      1,
+		NumTokens:  13,
+		TokenTypes: []TokenType{PERCENT, STAR_STAR, STAR, TILDE_SLASH, TILDE, AMPERSAND, PIPE, CARET, LESS_LESS, GREATER_GREATER, LESS_LESS, EQUAL, EOF},
+	},
+	{
+		ID:         12,
+		Source:     "+= ++ + -= -- - *= ** /= // compound assignment",
+		Lines:      1,
+		NumTokens:  10,
+		TokenTypes: []TokenType{PLUS_EQUAL, PLUS_PLUS, PLUS, MINUS_EQUAL, MINUS_MINUS, MINUS, STAR_EQUAL, STAR_STAR, SLASH_EQUAL, EOF},
+	},
+	{
+		ID:         13,
+		Source:     "a ? b : c, d",
+		Lines:      1,
+		NumTokens:  8,
+		TokenTypes: []TokenType{IDENTIFIER, QUESTION, IDENTIFIER, COLON, IDENTIFIER, COMMA, IDENTIFIER, EOF},
+	},
+	{
+		ID:         14,
+		Source:     "a ?? b?.c ?.( ?",
+		Lines:      1,
+		NumTokens:  9,
+		TokenTypes: []TokenType{IDENTIFIER, QUESTION_QUESTION, IDENTIFIER, QUESTION_DOT, IDENTIFIER, QUESTION_DOT, LEFT_PAREN, QUESTION, EOF},
+	},
+	{
+		ID:         15,
+		Source:     "match case _ snake_case =>",
+		Lines:      1,
+		NumTokens:  6,
+		TokenTypes: []TokenType{MATCH, CASE, IDENTIFIER, IDENTIFIER, EQUAL_GREATER, EOF},
+	},
+	{
+		ID:         16,
+		Source:     "x?.5:1",
+		Lines:      1,
+		NumTokens:  7,
+		TokenTypes: []TokenType{IDENTIFIER, QUESTION, DOT, NUMBER, COLON, NUMBER, EOF},
+	},
 	// negative cases
 	{
 		ID:             7,
@@ -185,3 +227,38 @@ func TestStringHandling(t *testing.T) {
 	}
 
 }
+
+func TestComments(t *testing.T) {
+	s := NewScanner("// first\nvar a = 1; // lox:ignore unused-variable\n\n//")
+	tokens := s.ScanTokens()
+
+	// comments never reach the parser
+	assert.Len(t, tokens, 6)
+	assert.Equal(t, []Comment{
+		{Text: " first", Line: 1},
+		{Text: " lox:ignore unused-variable", Line: 2},
+		{Text: "", Line: 4},
+	}, s.Comments)
+}
+
+func TestShebang(t *testing.T) {
+	s := NewScanner("#!/usr/bin/env lox\nprint 1;")
+	tokens := s.ScanTokens()
+
+	assert.Empty(t, s.Errors)
+	assert.Equal(t, PRINT, tokens[0].TokenType)
+	assert.Equal(t, 2, tokens[0].Line)
+
+	// only the very first line can be a shebang
+	s = NewScanner("print 1;\n#!/usr/bin/env lox")
+	s.ScanTokens()
+	assert.NotEmpty(t, s.Errors)
+}
+
+func TestNumberPrecision(t *testing.T) {
+	// numbers are float64 all the way through, parsing them at 32 bits would turn 0.1 into 0.10000000149011612
+	tokens := NewScanner("0.1 16777217").ScanTokens()
+
+	assert.Equal(t, 0.1, tokens[0].Literal)
+	assert.Equal(t, float64(16777217), tokens[1].Literal)
+}
@@ -16,3 +16,9 @@ func NewToken(t TokenType, lexeme string, literal any, line int) *Token {
 func (t *Token) String() string {
 	return fmt.Sprintf("token type: %s, lexeme: %s", t.TokenType.String(), t.Lexeme)
 }
+
+// Comment is the text after a `//`, up to the end of its line
+type Comment struct {
+	Text string
+	Line int
+}
@@ -15,6 +15,12 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
+	AMPERSAND
+	PIPE
+	CARET
+	QUESTION
+	COLON
 
 	// One or two character tokens.
 	BANG
@@ -22,12 +28,30 @@ const (
 
 	EQUAL
 	EQUAL_EQUAL
+	EQUAL_GREATER
 
 	GREATER
 	GREATER_EQUAL
+	GREATER_GREATER
 
 	LESS
 	LESS_EQUAL
+	LESS_LESS
+
+	STAR_STAR
+
+	TILDE
+	TILDE_SLASH
+
+	QUESTION_QUESTION
+	QUESTION_DOT
+
+	PLUS_EQUAL
+	PLUS_PLUS
+	MINUS_EQUAL
+	MINUS_MINUS
+	STAR_EQUAL
+	SLASH_EQUAL
 
 	// Literals.
 	IDENTIFIER
@@ -36,22 +60,26 @@ const (
 
 	// Keywords.
 	AND
+	CASE
 	CLASS
 	ELSE
 	FALSE
 	FUN
 	FOR
 	IF
+	MATCH
 	NIL
 	OR
 
 	PRINT
 	RETURN
+	SPAWN
 	SUPER
 	THIS
 	TRUE
 	VAR
 	WHILE
+	YIELD
 
 	EOF
 )
@@ -69,6 +97,12 @@ func (tt TokenType) String() string {
 		"SEMICOLON",
 		"SLASH",
 		"STAR",
+		"PERCENT",
+		"AMPERSAND",
+		"PIPE",
+		"CARET",
+		"QUESTION",
+		"COLON",
 
 		// One or two character tokens.
 		"BANG",
@@ -76,12 +110,30 @@ func (tt TokenType) String() string {
 
 		"EQUAL",
 		"EQUAL_EQUAL",
+		"EQUAL_GREATER",
 
 		"GREATER",
 		"GREATER_EQUAL",
+		"GREATER_GREATER",
 
 		"LESS",
 		"LESS_EQUAL",
+		"LESS_LESS",
+
+		"STAR_STAR",
+
+		"TILDE",
+		"TILDE_SLASH",
+
+		"QUESTION_QUESTION",
+		"QUESTION_DOT",
+
+		"PLUS_EQUAL",
+		"PLUS_PLUS",
+		"MINUS_EQUAL",
+		"MINUS_MINUS",
+		"STAR_EQUAL",
+		"SLASH_EQUAL",
 
 		// Literals.
 		"IDENTIFIER",
@@ -90,24 +142,43 @@ func (tt TokenType) String() string {
 
 		// Keywords.
 		"AND",
+		"CASE",
 		"CLASS",
 		"ELSE",
 		"FALSE",
 		"FUN",
 		"FOR",
 		"IF",
+		"MATCH",
 		"NIL",
 		"OR",
 
 		"PRINT",
 		"RETURN",
+		"SPAWN",
 		"SUPER",
 		"THIS",
 		"TRUE",
 		"VAR",
 		"WHILE",
+		"YIELD",
 
 		"EOF",
 	}
 	return tokenTypes[tt]
 }
+
+// IsKeyword reports whether tt is a reserved word
+func (tt TokenType) IsKeyword() bool {
+	return tt >= AND && tt <= YIELD
+}
+
+// ParseTokenType is the inverse of String, reporting false for names that aren't a token type
+func ParseTokenType(name string) (TokenType, bool) {
+	for tt := LEFT_PAREN; tt <= EOF; tt++ {
+		if tt.String() == name {
+			return tt, true
+		}
+	}
+	return 0, false
+}
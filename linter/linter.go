@@ -0,0 +1,386 @@
+package linter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+// unknownArity marks names that aren't, or are no longer, a function the linter can see the declaration of
+const unknownArity = -2
+
+type variable struct {
+	Name      lexer.Token
+	Parameter bool
+	Used      bool
+	Arity     int // -1 for variadic natives
+}
+
+type scope struct {
+	variables map[string]*variable
+	order     []*variable // unused variables are reported in declaration order
+}
+
+// Linter reports suspicious but legal code. implements parser.ExprVisitor and parser.StmtVisitor
+type Linter struct {
+	disabled    map[string]bool
+	diagnostics []Diagnostic
+	scopes      []*scope
+}
+
+// NewLinter creates a linter with every rule enabled that knows the arity of the given native functions
+func NewLinter(natives map[string]int) *Linter {
+	globals := &scope{variables: map[string]*variable{}}
+	for name, arity := range natives {
+		globals.variables[name] = &variable{Name: lexer.Token{Lexeme: name}, Used: true, Arity: arity}
+	}
+
+	return &Linter{
+		disabled: map[string]bool{},
+		scopes:   []*scope{globals},
+	}
+}
+
+// Disable turns off rules by ID, failing on IDs that don't name a rule
+func (l *Linter) Disable(ids ...string) error {
+	return l.setDisabled(ids, true)
+}
+
+// Enable turns rules back on by ID
+func (l *Linter) Enable(ids ...string) error {
+	return l.setDisabled(ids, false)
+}
+
+func (l *Linter) setDisabled(ids []string, disabled bool) error {
+	for _, id := range ids {
+		if !knownRule(id) {
+			return fmt.Errorf("unknown lint rule '%s'", id)
+		}
+		l.disabled[id] = disabled
+	}
+	return nil
+}
+
+// Lint reports the findings of every enabled rule in line order, minus those suppressed by a
+// `// lox:ignore` comment
+func (l *Linter) Lint(stmts []parser.Stmt, comments []lexer.Comment) []Diagnostic {
+	// top level declarations can be used by anything that runs later, so the global scope is never reported
+	l.beginScope()
+	l.lintBlock(stmts)
+	l.scopes = l.scopes[:len(l.scopes)-1]
+
+	ignored := parseSuppressions(comments)
+	diagnostics := []Diagnostic{}
+	for _, d := range l.diagnostics {
+		if !l.disabled[d.Rule] && !ignored.ignores(d) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].Line < diagnostics[j].Line })
+	return diagnostics
+}
+
+func (l *Linter) report(line int, rule string, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{Line: line, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *Linter) beginScope() {
+	l.scopes = append(l.scopes, &scope{variables: map[string]*variable{}})
+}
+
+// endScope reports the locals of the innermost scope that were never read
+func (l *Linter) endScope() {
+	current := l.scopes[len(l.scopes)-1]
+	l.scopes = l.scopes[:len(l.scopes)-1]
+
+	// the global scope and the top level of the program are exempt
+	if len(l.scopes) < 2 {
+		return
+	}
+
+	for _, v := range current.order {
+		if v.Used || strings.HasPrefix(v.Name.Lexeme, "_") {
+			continue
+		}
+		if v.Parameter {
+			l.report(v.Name.Line, UnusedParameter, "parameter '%s' is never used.", v.Name.Lexeme)
+		} else {
+			l.report(v.Name.Line, UnusedVariable, "'%s' is declared but never used.", v.Name.Lexeme)
+		}
+	}
+}
+
+func (l *Linter) declare(name lexer.Token, parameter bool, arity int) {
+	// shadowing only matters for locals, and natives don't count as declarations
+	if len(l.scopes) > 2 {
+		for i := len(l.scopes) - 2; i >= 1; i-- {
+			if outer, ok := l.scopes[i].variables[name.Lexeme]; ok {
+				l.report(name.Line, Shadowing, "'%s' shadows the declaration on line %d.", name.Lexeme, outer.Name.Line)
+				break
+			}
+		}
+	}
+
+	current := l.scopes[len(l.scopes)-1]
+	v := &variable{Name: name, Parameter: parameter, Arity: arity}
+	current.variables[name.Lexeme] = v
+	current.order = append(current.order, v)
+}
+
+func (l *Linter) lookup(name string) *variable {
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if v, ok := l.scopes[i].variables[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// markUsed records a read of name
+func (l *Linter) markUsed(name string) {
+	if v := l.lookup(name); v != nil {
+		v.Used = true
+	}
+}
+
+func (l *Linter) lint(stmt parser.Stmt) {
+	// a bad statement has no partial one when nothing of it parsed
+	if stmt != nil {
+		stmt.Accept(l)
+	}
+}
+
+func (l *Linter) lintExpr(expr parser.Expr) {
+	if expr != nil {
+		expr.Accept(l)
+	}
+}
+
+// lintBlock lints a list of statements that share a scope, flagging whatever follows a return
+func (l *Linter) lintBlock(stmts []parser.Stmt) {
+	for i, stmt := range stmts {
+		l.lint(stmt)
+
+		if parser.AlwaysReturns(stmt) && i+1 < len(stmts) {
+			l.report(parser.StmtLine(stmts[i+1]), UnreachableCode, "unreachable code after return.")
+			for _, rest := range stmts[i+1:] {
+				l.lint(rest)
+			}
+			return
+		}
+	}
+}
+
+// checkCondition flags an if or while condition that is an assignment. like C compilers, it takes one
+// wrapped in parentheses of its own, `if ((x = next()))`, to be meant
+func (l *Linter) checkCondition(condition parser.Expr) {
+	if assign, ok := condition.(*parser.AssignExpr); ok {
+		l.report(assign.Name.Line, AssignInCondition, "assignment to '%s' used as a condition, did you mean '=='?", assign.Name.Lexeme)
+	}
+}
+
+// StmtVisitor implementation below ----------------------------------------------------------------
+func (l *Linter) VisitPrintStmt(stmt *parser.PrintStmt) error {
+	l.lintExpr(stmt.Expr)
+	return nil
+}
+
+func (l *Linter) VisitExpressionStmt(stmt *parser.ExpressionStmt) error {
+	l.lintExpr(stmt.Expr)
+	return nil
+}
+
+func (l *Linter) VisitVariableDeclStmt(stmt *parser.VariableDeclarationStmt) error {
+	// the initializer can't see the variable it initializes
+	l.lintExpr(stmt.Initializer)
+	l.declare(stmt.Name, false, unknownArity)
+	return nil
+}
+
+func (l *Linter) VisitBlockStmt(stmt *parser.BlockStmt) error {
+	// blocks made up by the parser while desugaring `for` have no brace
+	if len(stmt.Stmts) == 0 && stmt.Brace.Line != 0 {
+		l.report(stmt.Brace.Line, EmptyBlock, "empty block.")
+	}
+
+	l.beginScope()
+	l.lintBlock(stmt.Stmts)
+	l.endScope()
+	return nil
+}
+
+func (l *Linter) VisitIfStmt(stmt *parser.IfStmt) error {
+	l.checkCondition(stmt.Condition)
+	l.lintExpr(stmt.Condition)
+	l.lint(stmt.ThenBranch)
+	l.lint(stmt.ElseBranch)
+	return nil
+}
+
+func (l *Linter) VisitWhileStmt(stmt *parser.WhileStmt) error {
+	l.checkCondition(stmt.Condition)
+
+	// `for (;;)` and `for (; true;)` are the idiomatic infinite loops
+	if literal, ok := stmt.Condition.(*parser.LiteralExpr); stmt.Keyword.TokenType == lexer.FOR && parser.IsConstant(stmt.Condition) && !(ok && literal.Value == true) {
+		l.report(stmt.Keyword.Line, ConstantCondition, "for loop condition is a constant.")
+	}
+
+	l.lintExpr(stmt.Condition)
+	l.lint(stmt.Body)
+	return nil
+}
+
+func (l *Linter) VisitFunctionStmt(stmt *parser.FunctionStmt) error {
+	// declared before the body so recursive calls are checked too
+	l.declare(stmt.Name, false, len(stmt.Params))
+
+	l.beginScope()
+	for _, param := range stmt.Params {
+		l.declare(param, true, unknownArity)
+	}
+	l.lintBlock(stmt.Body)
+	l.endScope()
+	return nil
+}
+
+func (l *Linter) VisitReturnStmt(stmt *parser.ReturnStmt) error {
+	l.lintExpr(stmt.Value)
+	return nil
+}
+
+func (l *Linter) VisitYieldStmt(stmt *parser.YieldStmt) error {
+	l.lintExpr(stmt.Value)
+	return nil
+}
+
+func (l *Linter) VisitTestStmt(stmt *parser.TestStmt) error {
+	l.beginScope()
+	l.lintBlock(stmt.Body)
+	l.endScope()
+	return nil
+}
+
+// whatever part of a bad statement parsed is still linted, so the names it declares are known
+func (l *Linter) VisitBadStmt(stmt *parser.BadStmt) error {
+	l.lint(stmt.Partial)
+	return nil
+}
+
+func (l *Linter) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	l.lintExpr(stmt.Subject)
+
+	for _, matchCase := range stmt.Cases {
+		l.beginScope()
+		for _, pattern := range matchCase.Patterns {
+			if pattern.Kind == parser.BindingPattern {
+				l.declare(pattern.Token, false, unknownArity)
+			}
+		}
+		l.lintExpr(matchCase.Guard)
+		l.lint(matchCase.Body)
+		l.endScope()
+	}
+	return nil
+}
+
+// ExprVisitor implementation below ----------------------------------------------------------------
+func (l *Linter) VisitLiteralExpr(expr *parser.LiteralExpr) (any, error) {
+	return nil, nil
+}
+
+func (l *Linter) VisitGroupingExpr(expr *parser.GroupingExpr) (any, error) {
+	l.lintExpr(expr.Expr)
+	return nil, nil
+}
+
+func (l *Linter) VisitVariableExpr(expr *parser.VariableExpr) (any, error) {
+	l.markUsed(expr.Name.Lexeme)
+	return nil, nil
+}
+
+func (l *Linter) VisitUnaryExpr(expr *parser.UnaryExpr) (any, error) {
+	l.lintExpr(expr.Expr)
+	return nil, nil
+}
+
+func (l *Linter) VisitBinaryExpr(expr *parser.BinaryExpr) (any, error) {
+	l.lintExpr(expr.LeftExpr)
+	l.lintExpr(expr.RightExpr)
+	return nil, nil
+}
+
+func (l *Linter) VisitAssignExpr(expr *parser.AssignExpr) (any, error) {
+	if value, ok := expr.Value.(*parser.VariableExpr); ok && value.Name.Lexeme == expr.Name.Lexeme {
+		l.report(expr.Name.Line, SelfAssignment, "'%s' is assigned to itself.", expr.Name.Lexeme)
+	}
+
+	l.lintExpr(expr.Value)
+
+	// a write isn't a use, but whatever the name held, it is no longer the function declared with it
+	if v := l.lookup(expr.Name.Lexeme); v != nil {
+		v.Arity = unknownArity
+	}
+	return nil, nil
+}
+
+func (l *Linter) VisitCompoundAssignExpr(expr *parser.CompoundAssignExpr) (any, error) {
+	l.markUsed(expr.Name.Lexeme)
+	l.lintExpr(expr.Value)
+	return nil, nil
+}
+
+func (l *Linter) VisitIncrementExpr(expr *parser.IncrementExpr) (any, error) {
+	l.markUsed(expr.Name.Lexeme)
+	return nil, nil
+}
+
+func (l *Linter) VisitLogicalExpr(expr *parser.LogicalExpr) (any, error) {
+	l.lintExpr(expr.Left)
+	l.lintExpr(expr.Right)
+	return nil, nil
+}
+
+func (l *Linter) VisitConditionalExpr(expr *parser.ConditionalExpr) (any, error) {
+	l.lintExpr(expr.Condition)
+	l.lintExpr(expr.ThenBranch)
+	l.lintExpr(expr.ElseBranch)
+	return nil, nil
+}
+
+func (l *Linter) VisitCallExpr(expr *parser.CallExpr) (any, error) {
+	l.lintExpr(expr.Callee)
+	for _, arg := range expr.Arguments {
+		l.lintExpr(arg)
+	}
+
+	if callee, ok := expr.Callee.(*parser.VariableExpr); ok {
+		v := l.lookup(callee.Name.Lexeme)
+		if v != nil && v.Arity >= 0 && v.Arity != len(expr.Arguments) {
+			l.report(expr.Paren.Line, WrongArgCount, "'%s' expects %d arguments but got %d.", callee.Name.Lexeme, v.Arity, len(expr.Arguments))
+		}
+	}
+	return nil, nil
+}
+
+func (l *Linter) VisitGetExpr(expr *parser.GetExpr) (any, error) {
+	l.lintExpr(expr.Object)
+	return nil, nil
+}
+
+func (l *Linter) VisitOptionalChainExpr(expr *parser.OptionalChainExpr) (any, error) {
+	l.lintExpr(expr.Expr)
+	return nil, nil
+}
+
+func (l *Linter) VisitSpawnExpr(expr *parser.SpawnExpr) (any, error) {
+	l.lintExpr(expr.Call)
+	return nil, nil
+}
+
+func (l *Linter) VisitBadExpr(expr *parser.BadExpr) (any, error) {
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package linter
+
+import (
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+type LintTestCase struct {
+	ID       int
+	Source   string
+	Disabled []string
+	Expected []string
+}
+
+func lint(source string, disabled ...string) []string {
+	s := lexer.NewScanner(source)
+	stmts := parser.NewParser(s.ScanTokens()).Parse()
+
+	l := NewLinter(map[string]int{"len": 1, "join": -1})
+	if err := l.Disable(disabled...); err != nil {
+		panic(err)
+	}
+
+	messages := []string{}
+	for _, diagnostic := range l.Lint(stmts, s.Comments) {
+		messages = append(messages, diagnostic.String())
+	}
+	return messages
+}
+
+var lintCases = []LintTestCase{
+	// globals and used locals are fine
+	{ID: 1, Source: "var a = 1; fun f(x) { var y = x; return y; } print f(a);", Expected: []string{}},
+	{ID: 2, Source: "fun f(x, _y) {\n  var z = 1;\n  var _w = 2;\n}", Expected: []string{
+		"[line 1] Warning (unused-parameter): parameter 'x' is never used.",
+		"[line 2] Warning (unused-variable): 'z' is declared but never used.",
+	}},
+	// a write isn't a read, but read-modify-write is
+	{ID: 3, Source: "{ var a = 1; a = 2; var b = 1; b += 1; var c = 0; c++; }", Expected: []string{
+		"[line 1] Warning (unused-variable): 'a' is declared but never used.",
+	}},
+	// closures read their enclosing locals
+	{ID: 4, Source: "fun outer() { var n = 0; fun inner() { print n; } inner(); }", Expected: []string{}},
+	{ID: 5, Source: "var x = 1;\nfun f(x) { print x; }\n{ var y = 1; { var y = 2; print y; } print y; }", Expected: []string{
+		"[line 2] Warning (shadowing): 'x' shadows the declaration on line 1.",
+		"[line 3] Warning (shadowing): 'y' shadows the declaration on line 3.",
+	}},
+	{ID: 6, Source: "fun f() {\n  return 1;\n  print 2;\n  print 3;\n}\nfun g(a) { if (a) { return 1; } else return 2; print 3; }", Expected: []string{
+		"[line 3] Warning (unreachable-code): unreachable code after return.",
+		"[line 6] Warning (unreachable-code): unreachable code after return.",
+	}},
+	{ID: 7, Source: "var a; if (a = 1) print a; while (a = nil) print a; if (a == 1) print a;", Expected: []string{
+		"[line 1] Warning (assign-in-condition): assignment to 'a' used as a condition, did you mean '=='?",
+		"[line 1] Warning (assign-in-condition): assignment to 'a' used as a condition, did you mean '=='?",
+	}},
+	{ID: 8, Source: "var a = 1; a = a; a = a + 1;", Expected: []string{
+		"[line 1] Warning (self-assignment): 'a' is assigned to itself.",
+	}},
+	{ID: 9, Source: "if (true) {} else { print 1; }\nfun noop() {}\nfor (var i = 0; i < 2; i++) print i;", Expected: []string{
+		"[line 1] Warning (empty-block): empty block.",
+	}},
+	{ID: 10, Source: "for (var i = 0; 1 < 2; i++) print i;\nfor (;false;) print 1;\nfor (;;) print 1;\nwhile (1 < 2) print 1;", Expected: []string{
+		"[line 1] Warning (constant-condition): for loop condition is a constant.",
+		"[line 2] Warning (constant-condition): for loop condition is a constant.",
+	}},
+	{ID: 11, Source: "fun f(a, b) { return a + b; }\nf(1);\nlen();\njoin(1, 2, 3);\nf = len;\nf(1);", Expected: []string{
+		"[line 2] Warning (wrong-arg-count): 'f' expects 2 arguments but got 1.",
+		"[line 3] Warning (wrong-arg-count): 'len' expects 1 arguments but got 0.",
+	}},
+	// suppression comments cover their own line and the next one
+	{ID: 12, Source: "len(); // lox:ignore wrong-arg-count\n// lox:ignore self-assignment, wrong-arg-count\nlen();\nlen();\n// lox:ignore\nlen();", Expected: []string{
+		"[line 4] Warning (wrong-arg-count): 'len' expects 1 arguments but got 0.",
+	}},
+	{ID: 13, Source: "len(); // lox:ignore unused-variable", Expected: []string{
+		"[line 1] Warning (wrong-arg-count): 'len' expects 1 arguments but got 0.",
+	}},
+	{ID: 14, Source: "{ var a; }\nlen();", Disabled: []string{UnusedVariable, WrongArgCount}, Expected: []string{}},
+	// an extra pair of parentheses says the assignment is meant
+	{ID: 15, Source: "var a; if ((a = 1)) print a; while ((a = nil)) print a; if (((a = 1))) print a;", Expected: []string{}},
+}
+
+func TestLinter(t *testing.T) {
+	for _, testCase := range lintCases {
+		assert.Equal(t, testCase.Expected, lint(testCase.Source, testCase.Disabled...), "test case %d failed", testCase.ID)
+	}
+}
+
+func TestUnknownRule(t *testing.T) {
+	l := NewLinter(nil)
+
+	assert.EqualError(t, l.Disable(UnusedVariable, "no-such-rule"), "unknown lint rule 'no-such-rule'")
+	assert.NoError(t, l.Enable(UnusedVariable))
+}
@@ -0,0 +1,95 @@
+package linter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+const (
+	UnusedVariable    = "unused-variable"
+	UnusedParameter   = "unused-parameter"
+	Shadowing         = "shadowing"
+	UnreachableCode   = "unreachable-code"
+	AssignInCondition = "assign-in-condition"
+	SelfAssignment    = "self-assignment"
+	EmptyBlock        = "empty-block"
+	ConstantCondition = "constant-condition"
+	WrongArgCount     = "wrong-arg-count"
+)
+
+type Rule struct {
+	ID          string
+	Description string
+}
+
+// Rules lists every rule the linter knows, all of them are enabled by default
+var Rules = []Rule{
+	{UnusedVariable, "a local variable is declared but never read"},
+	{UnusedParameter, "a function parameter is never read"},
+	{Shadowing, "a local declaration hides a declaration from an enclosing scope"},
+	{UnreachableCode, "a statement comes after a return and can never run"},
+	{AssignInCondition, "an if or while condition is an assignment, usually a typo for ==. parenthesize it to say it's meant"},
+	{SelfAssignment, "a variable is assigned to itself"},
+	{EmptyBlock, "a block has no statements"},
+	{ConstantCondition, "a for loop's condition is a constant, so it runs never or forever"},
+	{WrongArgCount, "a known function is called with the wrong number of arguments"},
+}
+
+func knownRule(id string) bool {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostic is a single finding of a rule
+type Diagnostic struct {
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[line %d] Warning (%s): %s", d.Line, d.Rule, d.Message)
+}
+
+const ignoreDirective = "lox:ignore"
+
+// suppressions maps a line to the rules ignored on it. `// lox:ignore rule-a, rule-b` covers its own line
+// and the line after it, so it works both trailing a statement and on the line above one. with no rules
+// listed it ignores everything
+type suppressions map[int][]string
+
+func parseSuppressions(comments []lexer.Comment) suppressions {
+	s := suppressions{}
+	for _, comment := range comments {
+		text := strings.TrimSpace(comment.Text)
+		if !strings.HasPrefix(text, ignoreDirective) {
+			continue
+		}
+
+		rules := strings.FieldsFunc(text[len(ignoreDirective):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(rules) == 0 {
+			rules = []string{"all"}
+		}
+
+		s[comment.Line] = append(s[comment.Line], rules...)
+		s[comment.Line+1] = append(s[comment.Line+1], rules...)
+	}
+	return s
+}
+
+func (s suppressions) ignores(d Diagnostic) bool {
+	for _, rule := range s[d.Line] {
+		if rule == d.Rule || rule == "all" {
+			return true
+		}
+	}
+	return false
+}
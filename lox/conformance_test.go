@@ -0,0 +1,134 @@
+package lox
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/stretchr/testify/assert"
+)
+
+// annotations in the testdata scripts, in the style of the Crafting Interpreters test suite:
+//
+//	print 1 + 2; // expect: 3
+//	print -"a";  // expect runtime error: operand must be a number.
+//	print ;      // [line 3] Error at ;: Expect expression.
+//
+// expected output is matched line by line in order. a runtime error is expected on the line of its
+// annotation, and `[line N]` annotations must match the scanner and parser diagnostics exactly
+var (
+	expectOutput       = regexp.MustCompile(`// expect: ?(.*)`)
+	expectRuntimeError = regexp.MustCompile(`// expect runtime error: (.+)`)
+	expectDiagnostic   = regexp.MustCompile(`// (\[line \d+\] .+)`)
+)
+
+// conformanceTimeout fails a script instead of hanging the whole run when something loops forever
+const conformanceTimeout = 10 * time.Second
+
+type expectations struct {
+	output       []string
+	runtimeError string
+	diagnostics  []string
+}
+
+func parseExpectations(source string) expectations {
+	expected := expectations{output: []string{}, diagnostics: []string{}}
+	for n, line := range strings.Split(source, "\n") {
+		if match := expectOutput.FindStringSubmatch(line); match != nil {
+			expected.output = append(expected.output, match[1])
+		} else if match := expectRuntimeError.FindStringSubmatch(line); match != nil {
+			expected.runtimeError = fmt.Sprintf("%s\n[line %d]", match[1], n+1)
+		} else if match := expectDiagnostic.FindStringSubmatch(line); match != nil {
+			expected.diagnostics = append(expected.diagnostics, match[1])
+		}
+	}
+	return expected
+}
+
+// lines splits output into lines, without the empty one after the final newline
+func lines(output string) []string {
+	if output == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+}
+
+func runConformanceScript(t *testing.T, path string) {
+	source, err := os.ReadFile(path)
+	if !assert.Nil(t, err) {
+		return
+	}
+	expected := parseExpectations(string(source))
+
+	var stdout, stderr bytes.Buffer
+	l := NewLox()
+	l.Stdout, l.Stderr = &stdout, &stderr
+	l.Interpreter.Loop = interpreter.NewVirtualEventLoop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.RunSource(string(source))
+	}()
+	select {
+	case <-done:
+	case <-time.After(conformanceTimeout):
+		t.Fatalf("still running after %s", conformanceTimeout)
+	}
+
+	assert.Equal(t, expected.output, lines(stdout.String()), "output")
+
+	switch {
+	case expected.runtimeError != "":
+		assert.True(t, l.HadRuntimeError(), "expected a runtime error")
+		assert.Equal(t, expected.runtimeError, strings.TrimSuffix(stderr.String(), "\n"), "runtime error")
+	case len(expected.diagnostics) > 0:
+		// the scanner's errors are reported before the parser's, whatever their lines
+		actual := lines(stderr.String())
+		sort.Strings(actual)
+		sort.Strings(expected.diagnostics)
+		assert.Equal(t, expected.diagnostics, actual, "diagnostics")
+	default:
+		assert.Empty(t, stderr.String(), "unexpected errors")
+	}
+}
+
+// TestConformance runs every script under testdata and checks what it printed and reported against
+// its annotations, covering the scanner, parser, optimizer and interpreter together
+func TestConformance(t *testing.T) {
+	scripts := []string{}
+	err := filepath.WalkDir("testdata", func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && strings.HasSuffix(path, ".lox") {
+			scripts = append(scripts, path)
+		}
+		return err
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, scripts)
+
+	for _, path := range scripts {
+		name := filepath.ToSlash(strings.TrimPrefix(path, "testdata"+string(filepath.Separator)))
+		t.Run(name, func(t *testing.T) {
+			runConformanceScript(t, path)
+		})
+	}
+}
+
+func TestParseExpectations(t *testing.T) {
+	expected := parseExpectations(`print 1; // expect: 1
+print ""; // expect:
+// [line 3] Error at ;: Expect expression.
+print -nil; // expect runtime error: operand must be a number.`)
+
+	assert.Equal(t, []string{"1", ""}, expected.output)
+	assert.Equal(t, []string{"[line 3] Error at ;: Expect expression."}, expected.diagnostics)
+	assert.Equal(t, "operand must be a number.\n[line 4]", expected.runtimeError)
+}
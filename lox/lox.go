@@ -3,30 +3,57 @@ package lox
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
+	"github.com/brandonshearin/go-lox/checker"
+	"github.com/brandonshearin/go-lox/formatter"
 	"github.com/brandonshearin/go-lox/interpreter"
 	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/linter"
+	"github.com/brandonshearin/go-lox/optimizer"
 	"github.com/brandonshearin/go-lox/parser"
 )
 
 func NewLox() *Lox {
-	return &Lox{
+	l := &Lox{
 		hadError:        false,
 		hadRuntimeError: false,
 		Interpreter:     *interpreter.NewInterpreter(),
+		Optimize:        true,
+		Stdout:          os.Stdout,
+		Stderr:          os.Stderr,
 	}
+	l.Interpreter.Loop = interpreter.NewEventLoop()
+	return l
 }
 
 type Lox struct {
 	hadError        bool
 	hadRuntimeError bool
+	// set once the program calls exit()
+	exited   bool
+	exitCode int
 	// Lexer           lexer.Scanner
 	// Parser          parser.Parser/
 	Interpreter interpreter.Interpreter
+	// Optimize runs the AST optimizer between parsing and interpreting
+	Optimize bool
+	// File is the path the source being run was read from, which coverage is recorded under
+	File string
+
+	// Stdout receives what programs print and Stderr the errors that stop them
+	Stdout io.Writer
+	Stderr io.Writer
 }
 
+// HadError reports whether the last source run had syntax errors
+func (l *Lox) HadError() bool { return l.hadError }
+
+// HadRuntimeError reports whether the last source run stopped on a runtime error
+func (l *Lox) HadRuntimeError() bool { return l.hadRuntimeError }
+
 func (l *Lox) RunFile(filename string) error {
 	// ReadFile reads the file named by filename and returns the contents.
 	// A successful call returns err == nil, not err == EOF.
@@ -35,59 +62,194 @@ func (l *Lox) RunFile(filename string) error {
 		// Return an empty slice and the error
 		return err
 	}
+	l.File = filename
 
-	sourceCode := string(data)
+	l.RunSource(string(data))
+	return nil
+}
 
-	l.run(sourceCode)
+// ExitCode reports the code the last source run passed to exit(), if it called it
+func (l *Lox) ExitCode() (int, bool) { return l.exitCode, l.exited }
 
-	return nil
+// RunSource runs a whole program and then its timers, until none are left, and finally shuts down any
+// tasks and generators still running
+func (l *Lox) RunSource(source string) {
+	l.run(source)
+	l.runLoop()
+	l.Interpreter.Shutdown()
+}
+
+// runLoop runs the timers the source just run left behind, unless it failed or exited
+func (l *Lox) runLoop() {
+	if !l.hadError && !l.hadRuntimeError && !l.exited && l.Interpreter.Loop != nil {
+		if err := l.Interpreter.Loop.Run(&l.Interpreter); err != nil {
+			l.HandleRuntimeError(*err)
+		}
+	}
 }
 
-func (l *Lox) RunPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
+// RunPrompt runs each line read from in as its own program until in runs out or a line calls exit().
+// errors are reported and the session carries on, keeping the variables defined so far. each line's
+// timers run before the next prompt, tasks and generators carry on between lines until the session ends
+func (l *Lox) RunPrompt(in io.Reader) {
+	defer l.Interpreter.Shutdown()
+	scanner := bufio.NewScanner(in)
 
-	// loops until user `control C's``
 	for {
-		fmt.Print("> ")
+		fmt.Fprint(l.Stdout, "> ")
 
 		// Wait for the user to input something and press Enter
-		scanner.Scan()
+		if !scanner.Scan() {
+			fmt.Fprintln(l.Stdout)
+			return
+		}
 		input := scanner.Text()
 
 		// Trim the input to remove any leading or trailing whitespace
 		trimmedInput := strings.TrimSpace(input)
 
 		l.run(trimmedInput)
+		l.runLoop()
+		if l.exited {
+			return
+		}
 	}
 }
 
 func (l *Lox) run(source string) {
+	l.hadError, l.hadRuntimeError = false, false
+	l.exited, l.exitCode = false, 0
 
 	s := lexer.NewScanner(source)
 
 	tokens := s.ScanTokens()
 
-	if l.hadError {
-		fmt.Println(">>> lexical error occurred")
-		fmt.Println(s.Errors)
-		return
-	}
-
 	p := parser.NewParser(tokens)
 	ast := p.Parse()
 
+	for _, warning := range p.Warnings {
+		fmt.Fprintln(l.Stderr, warning)
+	}
+
+	// the parser reports where the scanner's bad characters left it stuck too, so both are shown
+	for _, problem := range append(append([]string{}, s.Errors...), p.Errors...) {
+		l.hadError = true
+		fmt.Fprintln(l.Stderr, problem)
+	}
 	if l.hadError {
-		fmt.Println(">>> syntax error occurred")
-		fmt.Println(s.Errors)
 		return
 	}
 
-	// interpreter := parser.NewInterpreter()
+	// the optimizer folds away branches and drops code that can't run, coverage is of the program as written
+	if l.Interpreter.Coverage != nil {
+		l.Interpreter.Coverage.Register(l.File, source, ast)
+	} else if l.Optimize {
+		ast = optimizer.NewOptimizer(l.Interpreter.Evaluate).Optimize(ast)
+	}
+
+	l.Interpreter.Stdout = l.Stdout
 	if err := l.Interpreter.Interpret(ast); err != nil {
 		l.HandleRuntimeError(*err)
 	}
 }
 
+// Tokens scans source, returning its tokens along with any lexical errors
+func (l *Lox) Tokens(source string) ([]lexer.Token, []string) {
+	s := lexer.NewScanner(source)
+	tokens := s.ScanTokens()
+	return tokens, s.Errors
+}
+
+// Tree prints the parse tree of source with parser.ASTPrinter, failing with a *CompileError when
+// source doesn't parse
+func (l *Lox) Tree(source string) (string, error) {
+	stmts, _, err := parse(source)
+	if err != nil {
+		return "", err
+	}
+	return (&parser.ASTPrinter{}).PrintProgram(stmts), nil
+}
+
+// Format lays source out the way formatter.Formatter does, failing with a *CompileError when source
+// doesn't parse
+func (l *Lox) Format(source string) (string, error) {
+	stmts, comments, err := parse(source)
+	if err != nil {
+		return "", err
+	}
+	return formatter.NewFormatter(source, comments).Format(stmts), nil
+}
+
+// parse scans and parses source, collecting every syntax error into a *CompileError
+func parse(source string) ([]parser.Stmt, []lexer.Comment, error) {
+	s := lexer.NewScanner(source)
+	tokens := s.ScanTokens()
+
+	p := parser.NewParser(tokens)
+	stmts := p.Parse()
+
+	if problems := append(append([]string{}, s.Errors...), p.Errors...); len(problems) > 0 {
+		return nil, nil, &CompileError{Problems: problems}
+	}
+	return stmts, s.Comments, nil
+}
+
+// Check reports the syntax errors in source without running it, plus type errors from the gradual
+// checker when types is set. an empty result means the program is clean
+func (l *Lox) Check(source string, types bool) []string {
+	s := lexer.NewScanner(source)
+	tokens := s.ScanTokens()
+
+	p := parser.NewParser(tokens)
+	stmts := p.Parse()
+
+	problems := append(append([]string{}, s.Errors...), p.Errors...)
+	// type checking a program that didn't parse would only repeat the syntax errors
+	if !types || len(problems) > 0 {
+		return problems
+	}
+
+	for _, diagnostic := range checker.NewChecker(interpreter.NativeArities()).Check(stmts) {
+		problems = append(problems, diagnostic.String())
+	}
+	return problems
+}
+
+// Lint reports syntax errors and lint warnings for source without running it. disabled rule IDs are
+// skipped, naming a rule that doesn't exist is an error
+func (l *Lox) Lint(source string, disabled []string) ([]string, error) {
+	lint := linter.NewLinter(interpreter.NativeArities())
+	if err := lint.Disable(disabled...); err != nil {
+		return nil, err
+	}
+
+	s := lexer.NewScanner(source)
+	tokens := s.ScanTokens()
+
+	p := parser.NewParser(tokens)
+	stmts := p.Parse()
+
+	problems := append(append([]string{}, s.Errors...), p.Errors...)
+	if len(problems) > 0 {
+		return problems, nil
+	}
+
+	for _, diagnostic := range lint.Lint(stmts, s.Comments) {
+		problems = append(problems, diagnostic.String())
+	}
+	return problems, nil
+}
+
+// AST serializes the parse tree of source with parser.EncodeJSON, failing with a *CompileError when
+// source doesn't parse. the tree is exactly as parsed, the optimizer doesn't run
+func (l *Lox) AST(source string) ([]byte, error) {
+	stmts, _, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+	return parser.EncodeJSON(stmts)
+}
+
 // TODO: maybe collect error messages in a slice on the Lox struct for test assertions
 func (l *Lox) HandleError(line int, message string) {
 	l.Report(line, "", message)
@@ -95,10 +257,23 @@ func (l *Lox) HandleError(line int, message string) {
 
 func (l *Lox) Report(line int, where string, message string) {
 	l.hadError = true
-	fmt.Println("[line ", line, "] Error ", where, ": ", message)
+	fmt.Fprintf(l.Stderr, "[line %d] Error%s: %s\n", line, where, message)
 }
 
+// HandleRuntimeError reports the error that stopped a program, unless it stopped because it called exit()
 func (l *Lox) HandleRuntimeError(e interpreter.RuntimeError) {
-	fmt.Println(e.Message, "\n[line ], ", e.Token.Line, "]")
+	if code, ok := interpreter.ExitCode(&e); ok {
+		l.exited, l.exitCode = true, code
+		return
+	}
+
+	interpreter.QuoteAssertion(&e, formatter.Expr)
+
+	// an event loop cancelled between callbacks fails outside any line
+	if e.Token.Line == 0 {
+		fmt.Fprintf(l.Stderr, "%s\n", e.Message)
+	} else {
+		fmt.Fprintf(l.Stderr, "%s\n[line %d]\n", e.Message, e.Token.Line)
+	}
 	l.hadRuntimeError = true
 }
@@ -0,0 +1,100 @@
+package lox
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/brandonshearin/go-lox/formatter"
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/brandonshearin/go-lox/optimizer"
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+// Program is a script that has been scanned, parsed and optimized once. nothing modifies it after
+// Compile, so a single Program can be run from any number of goroutines at the same time
+type Program struct {
+	stmts []parser.Stmt
+}
+
+// CompileError lists the syntax errors that kept a source from compiling
+type CompileError struct {
+	Problems []string
+}
+
+func (e *CompileError) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+// Compile turns source into a Program, failing with a *CompileError when it has syntax errors
+func Compile(source string) (*Program, error) {
+	stmts, _, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	// the optimizer only evaluates constants, which read nothing a run could change
+	stmts = optimizer.NewOptimizer(interpreter.NewInterpreter().Evaluate).Optimize(stmts)
+	return &Program{stmts: stmts}, nil
+}
+
+// RunOptions configures a single run of a Program
+type RunOptions struct {
+	// Stdout receives the program's output as it prints, nothing is written anywhere when nil
+	Stdout io.Writer
+	// Globals are defined before the program starts. values are shared, not copied, so lists and maps
+	// given to runs on different goroutines must not be mutated by them
+	Globals map[string]any
+	// Args become the program's `args` list
+	Args []string
+
+	FS               interpreter.FSPolicy
+	DisableTailCalls bool
+	// MaxCallDepth fails the run with "Stack overflow." once this many calls are nested, see
+	// interpreter.Interpreter.MaxCallDepth. a runaway recursion fails its own run, never the process
+	MaxCallDepth int
+	// VirtualClock runs timers without waiting for them, see interpreter.NewVirtualEventLoop
+	VirtualClock bool
+	// Profiler, when set, times the run. runs on different goroutines may share one
+	Profiler *interpreter.Profiler
+}
+
+// Run executes the program and then its timers on a fresh interpreter, returning everything it printed.
+// tasks the program spawned and didn't wait for are cancelled once it finishes, and generators it left
+// paused are unwound.
+// cancelling ctx stops the run at its next loop iteration or function call. a program that calls exit()
+// stops with an error interpreter.ExitCode recognises
+func (p *Program) Run(ctx context.Context, opts RunOptions) (string, error) {
+	i := interpreter.NewInterpreter()
+	i.Context = ctx
+	i.Stdout = opts.Stdout
+	if i.Stdout == nil {
+		i.Stdout = io.Discard
+	}
+	i.FS = opts.FS
+	i.DisableTailCalls = opts.DisableTailCalls
+	i.MaxCallDepth = opts.MaxCallDepth
+	i.Profiler = opts.Profiler
+
+	i.Loop = interpreter.NewEventLoop()
+	if opts.VirtualClock {
+		i.Loop = interpreter.NewVirtualEventLoop()
+	}
+
+	i.SetArgs(opts.Args)
+	for name, value := range opts.Globals {
+		i.Environment.Define(name, value)
+	}
+
+	err := i.Interpret(p.stmts)
+	if err == nil {
+		err = i.Loop.Run(i)
+	}
+	i.Shutdown()
+
+	if err != nil {
+		interpreter.QuoteAssertion(err, formatter.Expr)
+		return i.Output.String(), err
+	}
+	return i.Output.String(), nil
+}
@@ -0,0 +1,260 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/stretchr/testify/assert"
+)
+
+const fibSource = `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+var memo = 0;
+for (var i = 0; i < 3; i++) memo += fib(n);
+print memo;
+`
+
+func TestCompileErrors(t *testing.T) {
+	_, err := Compile("var = 1;\nprint ;")
+
+	var compileErr *CompileError
+	if assert.True(t, errors.As(err, &compileErr)) {
+		assert.Len(t, compileErr.Problems, 2)
+	}
+}
+
+func TestProgramRun(t *testing.T) {
+	program, err := Compile(`var greeting = "hello " + name; print greeting;`)
+	assert.Nil(t, err)
+
+	var stdout bytes.Buffer
+	output, err := program.Run(context.Background(), RunOptions{Stdout: &stdout, Globals: map[string]any{"name": "lox"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "hello lox\n", output)
+	assert.Equal(t, "hello lox\n", stdout.String())
+
+	// runs don't see each other's globals
+	output, err = program.Run(context.Background(), RunOptions{Globals: map[string]any{"name": "again"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "hello again\n", output)
+
+	_, err = program.Run(context.Background(), RunOptions{})
+	var runtimeErr *interpreter.RuntimeError
+	if assert.True(t, errors.As(err, &runtimeErr)) {
+		assert.Equal(t, "undefined variable 'name'.", runtimeErr.Message)
+	}
+}
+
+func TestProgramRunConcurrently(t *testing.T) {
+	program, err := Compile(fibSource)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	outputs := make([]string, 50)
+	errs := make([]error, 50)
+	for n := range outputs {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			outputs[n], errs[n] = program.Run(context.Background(), RunOptions{Globals: map[string]any{"n": float64(n % 10)}})
+		}(n)
+	}
+	wg.Wait()
+
+	fib := []int{0, 1, 1, 2, 3, 5, 8, 13, 21, 34}
+	for n := range outputs {
+		assert.Nil(t, errs[n], "run %d failed", n)
+		assert.Equal(t, fmt.Sprintf("%d\n", 3*fib[n%10]), outputs[n], "run %d failed", n)
+	}
+}
+
+func TestProgramRunStackOverflow(t *testing.T) {
+	runaway, err := Compile("fun f(n) { return f(n + 1) + 1; }\nf(0);")
+	assert.Nil(t, err)
+	program, err := Compile(fibSource)
+	assert.Nil(t, err)
+
+	// a recursion that never ends fails its own run, the runs beside it finish
+	var wg sync.WaitGroup
+	outputs := make([]string, 20)
+	errs := make([]error, 20)
+	for n := range outputs {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			if n%2 == 0 {
+				outputs[n], errs[n] = runaway.Run(context.Background(), RunOptions{})
+			} else {
+				outputs[n], errs[n] = program.Run(context.Background(), RunOptions{Globals: map[string]any{"n": 10.0}})
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	for n := range outputs {
+		if n%2 == 1 {
+			assert.Nil(t, errs[n], "run %d failed", n)
+			assert.Equal(t, "165\n", outputs[n], "run %d failed", n)
+			continue
+		}
+		var runtimeErr *interpreter.RuntimeError
+		if assert.True(t, errors.As(errs[n], &runtimeErr), "run %d failed", n) {
+			assert.Equal(t, "Stack overflow.", runtimeErr.Message)
+			assert.Equal(t, 1, runtimeErr.Token.Line)
+		}
+	}
+
+	// the limit is per run
+	recursive, err := Compile("fun f(n) { if (n > 0) f(n - 1); }\nf(depth); print depth;")
+	assert.Nil(t, err)
+	output, err := recursive.Run(context.Background(), RunOptions{MaxCallDepth: 100, Globals: map[string]any{"depth": 99.0}})
+	assert.Nil(t, err)
+	assert.Equal(t, "99\n", output)
+	_, err = recursive.Run(context.Background(), RunOptions{MaxCallDepth: 100, Globals: map[string]any{"depth": 100.0}})
+	assert.NotNil(t, err)
+}
+
+func TestProgramRunTimers(t *testing.T) {
+	program, err := Compile(`fun later() { print "later"; } setTimeout(later, 60000); print "now";`)
+	assert.Nil(t, err)
+
+	output, err := program.Run(context.Background(), RunOptions{VirtualClock: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "now\nlater\n", output)
+}
+
+func TestProgramRunExit(t *testing.T) {
+	program, err := Compile(`print args; exit(args.length);`)
+	assert.Nil(t, err)
+
+	output, err := program.Run(context.Background(), RunOptions{Args: []string{"a", "b"}})
+	assert.Equal(t, "[\"a\", \"b\"]\n", output)
+	code, ok := interpreter.ExitCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, code)
+}
+
+// assertGoroutines waits for goroutines that are on their way out to exit, then checks no more than want
+// are left. assert.Eventually can't be used, it runs the check on a goroutine of its own
+func assertGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > want && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), want)
+}
+
+// run with -race: a task left running would still be printing while the output is read
+func TestProgramRunUnjoinedTasks(t *testing.T) {
+	program, err := Compile(`
+fun chatty() { for (var i = 0; i < 2000; i++) print i; }
+fun stuck() { chan().recv(); }
+spawn chatty();
+spawn stuck();`)
+	assert.Nil(t, err)
+
+	before := runtime.NumGoroutine()
+	var stdout bytes.Buffer
+	output, err := program.Run(context.Background(), RunOptions{Stdout: &stdout})
+	assert.Nil(t, err)
+
+	// every task has returned by the time Run does, so nothing prints afterwards
+	assert.Equal(t, output, stdout.String())
+	assertGoroutines(t, before)
+}
+
+func TestProgramRunAbandonedGenerators(t *testing.T) {
+	program, err := Compile(`
+fun* naturals() { var n = 0; while (true) { yield n; n++; } }
+var g = naturals();
+g.next();
+fun take() { var inner = naturals(); inner.next(); return inner.next(); }
+print wait(spawn take());`)
+	assert.Nil(t, err)
+
+	// each run leaves two generators paused, shutting it down unwinds both
+	before := runtime.NumGoroutine()
+	for n := 0; n < 1000; n++ {
+		output, err := program.Run(context.Background(), RunOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, "1\n", output)
+	}
+	assertGoroutines(t, before)
+
+	// a profiled body unwinds through its own profile
+	profiler := interpreter.NewProfiler("generators.lox")
+	_, err = program.Run(context.Background(), RunOptions{Profiler: profiler})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, profiler.Functions())
+	assertGoroutines(t, before)
+}
+
+func TestProgramRunCancelled(t *testing.T) {
+	program, err := Compile("var n = 0;\nwhile (true) n++;")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = program.Run(ctx, RunOptions{})
+
+	var runtimeErr *interpreter.RuntimeError
+	if assert.True(t, errors.As(err, &runtimeErr)) {
+		assert.Equal(t, "execution cancelled: context deadline exceeded.", runtimeErr.Message)
+		assert.Equal(t, 2, runtimeErr.Token.Line)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	// a cancelled run doesn't wait out its timers either
+	program, err = Compile(`fun never() { print "never"; } setTimeout(never, 60000);`)
+	assert.Nil(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	output, err := program.Run(ctx, RunOptions{})
+	assert.NotNil(t, err)
+	assert.Empty(t, output)
+}
+
+func BenchmarkProgramRun(b *testing.B) {
+	program, err := Compile(fibSource)
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts := RunOptions{Globals: map[string]any{"n": 10.0}}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := program.Run(context.Background(), opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// the cost Compile saves, for comparison with BenchmarkProgramRun
+func BenchmarkCompileAndRun(b *testing.B) {
+	opts := RunOptions{Globals: map[string]any{"n": 10.0}}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			program, err := Compile(fibSource)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := program.Run(context.Background(), opts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
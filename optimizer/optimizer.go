@@ -0,0 +1,409 @@
+package optimizer
+
+import (
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+// Evaluator computes the value of an expression. the optimizer only ever hands it constant
+// expressions, and folds with it so folded values can't drift from what the interpreter would compute
+type Evaluator func(expr parser.Expr) (any, error)
+
+// Optimizer rewrites a program into a cheaper one that behaves the same, runtime errors included:
+// anything that would fail is left for the interpreter to fail on, at the same line.
+// implements parser.ExprVisitor and parser.StmtVisitor
+type Optimizer struct {
+	evaluate Evaluator
+
+	// what the statement just visited was rewritten into, empty when it was removed
+	out []parser.Stmt
+}
+
+func NewOptimizer(evaluate Evaluator) *Optimizer {
+	return &Optimizer{evaluate: evaluate}
+}
+
+// Optimize rewrites stmts in place and returns the optimized program
+func (o *Optimizer) Optimize(stmts []parser.Stmt) []parser.Stmt {
+	return o.block(stmts)
+}
+
+// block optimizes a list of statements, dropping everything after one that always returns
+func (o *Optimizer) block(stmts []parser.Stmt) []parser.Stmt {
+	optimized := []parser.Stmt{}
+	for _, stmt := range stmts {
+		optimized = append(optimized, o.stmt(stmt)...)
+		if parser.AlwaysReturns(stmt) {
+			break
+		}
+	}
+	return optimized
+}
+
+func (o *Optimizer) stmt(stmt parser.Stmt) []parser.Stmt {
+	// a bad statement has no partial one when nothing of it parsed
+	if stmt == nil {
+		return nil
+	}
+
+	o.out = nil
+	stmt.Accept(o)
+	return o.out
+}
+
+// single optimizes a statement that has to stay a single statement, like the body of a loop
+func (o *Optimizer) single(stmt parser.Stmt) parser.Stmt {
+	stmts := o.stmt(stmt)
+	if len(stmts) == 1 {
+		return stmts[0]
+	}
+	return &parser.BlockStmt{Stmts: stmts}
+}
+
+func (o *Optimizer) expr(expr parser.Expr) parser.Expr {
+	if expr == nil {
+		return nil
+	}
+
+	optimized, _ := expr.Accept(o)
+	return optimized.(parser.Expr)
+}
+
+// fold replaces a constant expression with its value, unless evaluating it fails. operands are folded
+// before the expressions using them, so an expression is constant exactly when its operands are literals
+func (o *Optimizer) fold(expr parser.Expr) parser.Expr {
+	if !foldable(expr) {
+		return expr
+	}
+
+	value, err := o.evaluate(expr)
+	if err != nil {
+		return expr
+	}
+	return literal(value, parser.ExprLine(expr))
+}
+
+// foldable reports whether every operand of expr has been folded to a literal
+func foldable(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.UnaryExpr:
+		_, ok := e.Expr.(*parser.LiteralExpr)
+		return ok
+	case *parser.BinaryExpr:
+		_, left := e.LeftExpr.(*parser.LiteralExpr)
+		_, right := e.RightExpr.(*parser.LiteralExpr)
+		return left && right
+	}
+	return false
+}
+
+func literal(value any, line int) *parser.LiteralExpr {
+	expr := &parser.LiteralExpr{Value: value, Token: lexer.Token{Line: line}}
+	switch value := value.(type) {
+	case nil:
+		expr.IsNil = true
+		expr.Token.TokenType = lexer.NIL
+	case bool:
+		expr.IsBoolean = true
+		expr.Token.TokenType = lexer.FALSE
+		if value {
+			expr.Token.TokenType = lexer.TRUE
+		}
+	case string:
+		expr.Token.TokenType = lexer.STRING
+	default:
+		expr.Token.TokenType = lexer.NUMBER
+	}
+	return expr
+}
+
+// constantValue reports the value of expr when it has already been folded to a literal
+func constantValue(expr parser.Expr) (any, bool) {
+	if literal, ok := expr.(*parser.LiteralExpr); ok {
+		return literal.Value, true
+	}
+	return nil, false
+}
+
+func isTruthy(value any) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return value != nil
+}
+
+// isNumeric reports whether expr can only evaluate to a number, or fail. simplifications that drop an
+// operator are only safe on these, since dropping the operator from `"a" * 1` would hide its error
+func isNumeric(expr parser.Expr) bool {
+	switch e := expr.(type) {
+	case *parser.LiteralExpr:
+		_, ok := e.Value.(float64)
+		return ok
+	case *parser.UnaryExpr:
+		return e.Operator.TokenType != lexer.BANG
+	case *parser.BinaryExpr:
+		switch e.Operator.TokenType {
+		case lexer.MINUS, lexer.STAR, lexer.SLASH, lexer.PERCENT, lexer.STAR_STAR, lexer.TILDE_SLASH,
+			lexer.AMPERSAND, lexer.PIPE, lexer.CARET, lexer.LESS_LESS, lexer.GREATER_GREATER:
+			return true
+		}
+	case *parser.IncrementExpr:
+		return true
+	}
+	return false
+}
+
+func isNumber(expr parser.Expr, n float64) bool {
+	value, ok := constantValue(expr)
+	return ok && value == n
+}
+
+// simplify applies identities that hold exactly for every number, including -0, NaN and infinities
+func simplify(expr *parser.BinaryExpr) parser.Expr {
+	left, right := expr.LeftExpr, expr.RightExpr
+
+	switch expr.Operator.TokenType {
+	case lexer.MINUS:
+		// x - 0 is x, but x + 0 isn't when x is -0
+		if isNumber(right, 0) && isNumeric(left) {
+			return left
+		}
+	case lexer.STAR:
+		if isNumber(right, 1) && isNumeric(left) {
+			return left
+		}
+		if isNumber(left, 1) && isNumeric(right) {
+			return right
+		}
+	case lexer.SLASH, lexer.STAR_STAR:
+		if isNumber(right, 1) && isNumeric(left) {
+			return left
+		}
+	}
+	return expr
+}
+
+// ExprVisitor implementation below ----------------------------------------------------------------
+func (o *Optimizer) VisitLiteralExpr(expr *parser.LiteralExpr) (any, error) {
+	return expr, nil
+}
+
+// the tree already encodes precedence, so parentheses have nothing left to do
+func (o *Optimizer) VisitGroupingExpr(expr *parser.GroupingExpr) (any, error) {
+	return o.expr(expr.Expr), nil
+}
+
+func (o *Optimizer) VisitVariableExpr(expr *parser.VariableExpr) (any, error) {
+	return expr, nil
+}
+
+func (o *Optimizer) VisitUnaryExpr(expr *parser.UnaryExpr) (any, error) {
+	expr.Expr = o.expr(expr.Expr)
+
+	// -(-x) is x for any number x
+	if inner, ok := expr.Expr.(*parser.UnaryExpr); ok && expr.Operator.TokenType == lexer.MINUS &&
+		inner.Operator.TokenType == lexer.MINUS && isNumeric(inner.Expr) {
+		return inner.Expr, nil
+	}
+	return o.fold(expr), nil
+}
+
+func (o *Optimizer) VisitBinaryExpr(expr *parser.BinaryExpr) (any, error) {
+	expr.LeftExpr = o.expr(expr.LeftExpr)
+	expr.RightExpr = o.expr(expr.RightExpr)
+
+	if folded := o.fold(expr); folded != parser.Expr(expr) {
+		return folded, nil
+	}
+	return simplify(expr), nil
+}
+
+func (o *Optimizer) VisitLogicalExpr(expr *parser.LogicalExpr) (any, error) {
+	expr.Left = o.expr(expr.Left)
+	expr.Right = o.expr(expr.Right)
+
+	left, ok := constantValue(expr.Left)
+	if !ok {
+		return expr, nil
+	}
+
+	// a constant left side decides whether the right side runs at all
+	var useLeft bool
+	switch expr.Operator.TokenType {
+	case lexer.OR:
+		useLeft = isTruthy(left)
+	case lexer.AND:
+		useLeft = !isTruthy(left)
+	case lexer.QUESTION_QUESTION:
+		useLeft = left != nil
+	default:
+		return expr, nil
+	}
+
+	if useLeft {
+		return expr.Left, nil
+	}
+	return expr.Right, nil
+}
+
+func (o *Optimizer) VisitConditionalExpr(expr *parser.ConditionalExpr) (any, error) {
+	expr.Condition = o.expr(expr.Condition)
+	expr.ThenBranch = o.expr(expr.ThenBranch)
+	expr.ElseBranch = o.expr(expr.ElseBranch)
+
+	if condition, ok := constantValue(expr.Condition); ok {
+		if isTruthy(condition) {
+			return expr.ThenBranch, nil
+		}
+		return expr.ElseBranch, nil
+	}
+	return expr, nil
+}
+
+func (o *Optimizer) VisitAssignExpr(expr *parser.AssignExpr) (any, error) {
+	expr.Value = o.expr(expr.Value)
+	return expr, nil
+}
+
+func (o *Optimizer) VisitCompoundAssignExpr(expr *parser.CompoundAssignExpr) (any, error) {
+	expr.Value = o.expr(expr.Value)
+	return expr, nil
+}
+
+func (o *Optimizer) VisitIncrementExpr(expr *parser.IncrementExpr) (any, error) {
+	return expr, nil
+}
+
+func (o *Optimizer) VisitCallExpr(expr *parser.CallExpr) (any, error) {
+	expr.Callee = o.expr(expr.Callee)
+	for i, arg := range expr.Arguments {
+		expr.Arguments[i] = o.expr(arg)
+	}
+	return expr, nil
+}
+
+func (o *Optimizer) VisitGetExpr(expr *parser.GetExpr) (any, error) {
+	expr.Object = o.expr(expr.Object)
+	return expr, nil
+}
+
+func (o *Optimizer) VisitOptionalChainExpr(expr *parser.OptionalChainExpr) (any, error) {
+	expr.Expr = o.expr(expr.Expr)
+	return expr, nil
+}
+
+func (o *Optimizer) VisitSpawnExpr(expr *parser.SpawnExpr) (any, error) {
+	// a call is never folded, so the spawned expression stays a call
+	o.VisitCallExpr(expr.Call)
+	return expr, nil
+}
+
+func (o *Optimizer) VisitBadExpr(expr *parser.BadExpr) (any, error) {
+	return expr, nil
+}
+
+// StmtVisitor implementation below ----------------------------------------------------------------
+func (o *Optimizer) VisitPrintStmt(stmt *parser.PrintStmt) error {
+	stmt.Expr = o.expr(stmt.Expr)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitExpressionStmt(stmt *parser.ExpressionStmt) error {
+	stmt.Expr = o.expr(stmt.Expr)
+
+	// a bare constant does nothing
+	if _, ok := constantValue(stmt.Expr); ok {
+		o.out = nil
+		return nil
+	}
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitVariableDeclStmt(stmt *parser.VariableDeclarationStmt) error {
+	stmt.Initializer = o.expr(stmt.Initializer)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitBlockStmt(stmt *parser.BlockStmt) error {
+	stmt.Stmts = o.block(stmt.Stmts)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitIfStmt(stmt *parser.IfStmt) error {
+	stmt.Condition = o.expr(stmt.Condition)
+
+	// only the branch that would run is kept. branches are statements, never declarations, so
+	// lifting one out of the if can't change what's in scope
+	if condition, ok := constantValue(stmt.Condition); ok {
+		if isTruthy(condition) {
+			o.out = o.stmt(stmt.ThenBranch)
+		} else {
+			o.out = o.stmt(stmt.ElseBranch)
+		}
+		return nil
+	}
+
+	stmt.ThenBranch = o.single(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		stmt.ElseBranch = o.single(stmt.ElseBranch)
+	}
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitWhileStmt(stmt *parser.WhileStmt) error {
+	stmt.Condition = o.expr(stmt.Condition)
+
+	if condition, ok := constantValue(stmt.Condition); ok && !isTruthy(condition) {
+		o.out = nil
+		return nil
+	}
+
+	stmt.Body = o.single(stmt.Body)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitFunctionStmt(stmt *parser.FunctionStmt) error {
+	stmt.Body = o.block(stmt.Body)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitReturnStmt(stmt *parser.ReturnStmt) error {
+	stmt.Value = o.expr(stmt.Value)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitYieldStmt(stmt *parser.YieldStmt) error {
+	stmt.Value = o.expr(stmt.Value)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitTestStmt(stmt *parser.TestStmt) error {
+	stmt.Body = o.block(stmt.Body)
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+// a bad statement is left as it is, it can never run
+func (o *Optimizer) VisitBadStmt(stmt *parser.BadStmt) error {
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
+
+func (o *Optimizer) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	stmt.Subject = o.expr(stmt.Subject)
+	for i := range stmt.Cases {
+		stmt.Cases[i].Guard = o.expr(stmt.Cases[i].Guard)
+		stmt.Cases[i].Body = o.single(stmt.Cases[i].Body)
+	}
+	o.out = []parser.Stmt{stmt}
+	return nil
+}
@@ -0,0 +1,89 @@
+package optimizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func optimize(source string) []parser.Stmt {
+	stmts := parser.NewParser(lexer.NewScanner(source).ScanTokens()).Parse()
+	return NewOptimizer(interpreter.NewInterpreter().Evaluate).Optimize(stmts)
+}
+
+type FoldTestCase struct {
+	ID       int
+	Source   string
+	Expected string
+}
+
+// each source is a single print statement, Expected is its optimized expression
+var foldCases = []FoldTestCase{
+	{ID: 1, Source: "print 60 * 60 * 24;", Expected: "86400.00"},
+	{ID: 2, Source: `print ("a" + "b") + x;`, Expected: "(+ ab x)"},
+	{ID: 3, Source: "print -(2 - 3) > 0 ? x : y;", Expected: "x"},
+	{ID: 4, Source: "print !nil;", Expected: "true"},
+	// errors are left for the interpreter to report
+	{ID: 5, Source: `print (1 + 2) + "a";`, Expected: "(+ 3.00 a)"},
+	{ID: 6, Source: "print 1 % 0;", Expected: "(% 1.00 0.00)"},
+	// constant left sides short-circuit
+	{ID: 7, Source: "print false and f();", Expected: "false"},
+	{ID: 8, Source: "print true and f();", Expected: "(call f)"},
+	{ID: 9, Source: "print nil ?? 1 + 1;", Expected: "2.00"},
+	{ID: 10, Source: "print x or true;", Expected: "(or x true)"},
+	// identities only apply when the other side is known to be a number
+	{ID: 11, Source: "print (a * b) * 1 - 0;", Expected: "(* a b)"},
+	{ID: 12, Source: "print x * 1;", Expected: "(* x 1.00)"},
+	{ID: 13, Source: "print x + 0;", Expected: "(+ x 0.00)"},
+	{ID: 14, Source: "print -(-(a - b));", Expected: "(- a b)"},
+	// a chain folds one operator at a time, so nothing is evaluated twice
+	{ID: 15, Source: "print " + strings.Repeat("1 + ", 500) + "1;", Expected: "501.00"},
+	{ID: 16, Source: "print 2 * 3 - (4 + x) * (1 + 1);", Expected: "(- 6.00 (* (+ 4.00 x) 2.00))"},
+}
+
+func TestFolding(t *testing.T) {
+	printer := parser.ASTPrinter{}
+
+	for _, testCase := range foldCases {
+		stmts := optimize(testCase.Source)
+
+		if assert.Len(t, stmts, 1, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Expected, printer.Print(stmts[0].(*parser.PrintStmt).Expr), "test case %d failed", testCase.ID)
+		}
+	}
+}
+
+func TestFoldingKeepsLines(t *testing.T) {
+	stmts := optimize("print\n1 +\n2;")
+
+	assert.Equal(t, 2, parser.ExprLine(stmts[0].(*parser.PrintStmt).Expr))
+}
+
+func TestDeadCode(t *testing.T) {
+	// constant if conditions keep only the branch that runs
+	stmts := optimize(`if (1 < 2) print "yes"; else print "no";`)
+	if assert.Len(t, stmts, 1) {
+		assert.Equal(t, "yes", stmts[0].(*parser.PrintStmt).Expr.(*parser.LiteralExpr).Value)
+	}
+
+	assert.Empty(t, optimize(`if (nil) print "no";`))
+	assert.Empty(t, optimize(`while (1 > 2) print "no";`))
+	assert.Empty(t, optimize(`1 + 2;`))
+
+	// infinite loops stay
+	stmts = optimize(`while (true) print "yes";`)
+	assert.IsType(t, &parser.WhileStmt{}, stmts[0])
+
+	// so does everything that isn't constant
+	stmts = optimize(`if (x) print "a"; while (x) print "b";`)
+	assert.Len(t, stmts, 2)
+
+	// nothing runs after a return
+	stmts = optimize(`fun f(x) { if (x) return 1; else return 2; print "dead"; } fun g() { { return; } print "dead"; }`)
+	assert.Len(t, stmts[0].(*parser.FunctionStmt).Body, 1)
+	assert.Len(t, stmts[1].(*parser.FunctionStmt).Body, 1)
+}
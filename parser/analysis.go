@@ -0,0 +1,127 @@
+package parser
+
+// AlwaysReturns reports whether running stmt always ends in a return, making anything after it unreachable
+func AlwaysReturns(stmt Stmt) bool {
+	switch s := stmt.(type) {
+	case *ReturnStmt:
+		return true
+	case *BlockStmt:
+		for _, inner := range s.Stmts {
+			if AlwaysReturns(inner) {
+				return true
+			}
+		}
+	case *IfStmt:
+		return s.ElseBranch != nil && AlwaysReturns(s.ThenBranch) && AlwaysReturns(s.ElseBranch)
+	}
+	return false
+}
+
+// IsConstant reports whether expr always evaluates to the same value
+func IsConstant(expr Expr) bool {
+	switch e := expr.(type) {
+	case *LiteralExpr:
+		return true
+	case *GroupingExpr:
+		return IsConstant(e.Expr)
+	case *UnaryExpr:
+		return IsConstant(e.Expr)
+	case *BinaryExpr:
+		return IsConstant(e.LeftExpr) && IsConstant(e.RightExpr)
+	case *LogicalExpr:
+		return IsConstant(e.Left) && IsConstant(e.Right)
+	default:
+		return false
+	}
+}
+
+// Inspect calls visit with every statement and expression in stmts, each before the ones inside it
+// and in the order they appear in the source. nil nodes are skipped
+func Inspect(stmts []Stmt, visit func(node any)) {
+	for _, stmt := range stmts {
+		inspectStmt(stmt, visit)
+	}
+}
+
+func inspectStmt(stmt Stmt, visit func(node any)) {
+	if stmt == nil {
+		return
+	}
+	visit(stmt)
+
+	switch s := stmt.(type) {
+	case *PrintStmt:
+		inspectExprs(visit, s.Expr)
+	case *ExpressionStmt:
+		inspectExprs(visit, s.Expr)
+	case *VariableDeclarationStmt:
+		inspectExprs(visit, s.Initializer)
+	case *BlockStmt:
+		Inspect(s.Stmts, visit)
+	case *IfStmt:
+		inspectExprs(visit, s.Condition)
+		inspectStmt(s.ThenBranch, visit)
+		inspectStmt(s.ElseBranch, visit)
+	case *WhileStmt:
+		inspectExprs(visit, s.Condition)
+		inspectStmt(s.Body, visit)
+	case *FunctionStmt:
+		Inspect(s.Body, visit)
+	case *ReturnStmt:
+		inspectExprs(visit, s.Value)
+	case *YieldStmt:
+		inspectExprs(visit, s.Value)
+	case *TestStmt:
+		Inspect(s.Body, visit)
+	case *BadStmt:
+		inspectStmt(s.Partial, visit)
+	case *MatchStmt:
+		inspectExprs(visit, s.Subject)
+		for _, matchCase := range s.Cases {
+			for _, pattern := range matchCase.Patterns {
+				if pattern.Literal != nil {
+					inspectExprs(visit, pattern.Literal)
+				}
+			}
+			inspectExprs(visit, matchCase.Guard)
+			inspectStmt(matchCase.Body, visit)
+		}
+	}
+}
+
+func inspectExprs(visit func(node any), exprs ...Expr) {
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		visit(expr)
+
+		switch e := expr.(type) {
+		case *UnaryExpr:
+			inspectExprs(visit, e.Expr)
+		case *BinaryExpr:
+			inspectExprs(visit, e.LeftExpr, e.RightExpr)
+		case *GroupingExpr:
+			inspectExprs(visit, e.Expr)
+		case *AssignExpr:
+			inspectExprs(visit, e.Value)
+		case *CompoundAssignExpr:
+			inspectExprs(visit, e.Value)
+		case *ConditionalExpr:
+			inspectExprs(visit, e.Condition, e.ThenBranch, e.ElseBranch)
+		case *LogicalExpr:
+			inspectExprs(visit, e.Left, e.Right)
+		case *CallExpr:
+			inspectExprs(visit, e.Callee)
+			inspectExprs(visit, e.Arguments...)
+		case *GetExpr:
+			inspectExprs(visit, e.Object)
+		case *OptionalChainExpr:
+			inspectExprs(visit, e.Expr)
+		case *SpawnExpr:
+			if e.Call != nil {
+				inspectExprs(visit, e.Call)
+			}
+		}
+	}
+}
@@ -11,6 +11,7 @@ type LiteralExpr struct {
 	Value     any
 	IsBoolean bool
 	IsNil     bool
+	Token     lexer.Token // zero for literals the parser makes up, like the `true` of an empty for condition
 }
 
 func (l *LiteralExpr) Expression() {}
@@ -75,6 +76,42 @@ func (a *AssignExpr) Accept(visitor ExprVisitor) (any, error) {
 	return visitor.VisitAssignExpr(a)
 }
 
+// compound assignment, ie `a += 1`. the operator is one of `+=`, `-=`, `*=` or `/=`
+type CompoundAssignExpr struct {
+	Name     lexer.Token
+	Operator Operator
+	Value    Expr
+}
+
+func (c *CompoundAssignExpr) Expression() {}
+func (c *CompoundAssignExpr) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitCompoundAssignExpr(c)
+}
+
+// `++a`, `--a`, `a++` and `a--`. prefix forms evaluate to the updated value, postfix forms to the original one
+type IncrementExpr struct {
+	Name     lexer.Token
+	Operator Operator
+	Prefix   bool
+}
+
+func (i *IncrementExpr) Expression() {}
+func (i *IncrementExpr) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitIncrementExpr(i)
+}
+
+// `condition ? thenBranch : elseBranch`, only the chosen branch is evaluated
+type ConditionalExpr struct {
+	Condition  Expr
+	ThenBranch Expr
+	ElseBranch Expr
+}
+
+func (c *ConditionalExpr) Expression() {}
+func (c *ConditionalExpr) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitConditionalExpr(c)
+}
+
 // logical operators 'and' and 'or'
 type LogicalExpr struct {
 	Operator Operator
@@ -91,7 +128,48 @@ type CallExpr struct {
 	Callee    Expr
 	Paren     lexer.Token
 	Arguments []Expr
+	Optional  bool // `callee?.(arguments)`
 }
 
 func (c *CallExpr) Expression()                             {}
 func (c *CallExpr) Accept(visitor ExprVisitor) (any, error) { return visitor.VisitCallExpr(c) }
+
+// property access, ie `object.name`
+type GetExpr struct {
+	Object   Expr
+	Name     lexer.Token
+	Optional bool // `object?.name`
+}
+
+func (g *GetExpr) Expression()                             {}
+func (g *GetExpr) Accept(visitor ExprVisitor) (any, error) { return visitor.VisitGetExpr(g) }
+
+// wraps a call/property chain containing `?.`, ie `a?.b.c()`. when an optional link finds a nil receiver
+// the rest of the chain is skipped and the whole chain evaluates to nil
+type OptionalChainExpr struct {
+	Expr Expr
+}
+
+func (o *OptionalChainExpr) Expression() {}
+func (o *OptionalChainExpr) Accept(visitor ExprVisitor) (any, error) {
+	return visitor.VisitOptionalChainExpr(o)
+}
+
+// spawn f(args) runs the call on a new goroutine and evaluates to a handle for `wait`
+type SpawnExpr struct {
+	Keyword lexer.Token
+	Call    *CallExpr
+}
+
+func (s *SpawnExpr) Expression()                             {}
+func (s *SpawnExpr) Accept(visitor ExprVisitor) (any, error) { return visitor.VisitSpawnExpr(s) }
+
+// BadExpr stands in for an expression that didn't parse, so trees with syntax errors have no nil
+// expressions. From and To are the first and last tokens of the span it covers
+type BadExpr struct {
+	From lexer.Token
+	To   lexer.Token
+}
+
+func (b *BadExpr) Expression()                             {}
+func (b *BadExpr) Accept(visitor ExprVisitor) (any, error) { return visitor.VisitBadExpr(b) }
@@ -8,7 +8,8 @@ type Stmt interface {
 }
 
 type PrintStmt struct {
-	Expr Expr
+	Keyword lexer.Token
+	Expr    Expr
 }
 
 func (p *PrintStmt) Statement() {}
@@ -27,6 +28,7 @@ func (p *ExpressionStmt) Accept(visitor StmtVisitor) error {
 
 type VariableDeclarationStmt struct {
 	Name        lexer.Token
+	Type        *TypeAnnotation // nil when the declaration is unannotated
 	Initializer Expr
 }
 
@@ -36,6 +38,7 @@ func (v *VariableDeclarationStmt) Accept(visitor StmtVisitor) error {
 }
 
 type BlockStmt struct {
+	Brace lexer.Token // the opening `{`, zero for blocks the parser makes up when desugaring `for`
 	Stmts []Stmt
 }
 
@@ -45,6 +48,7 @@ func (v *BlockStmt) Accept(visitor StmtVisitor) error {
 }
 
 type IfStmt struct {
+	Keyword    lexer.Token
 	Condition  Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
@@ -56,6 +60,7 @@ func (i *IfStmt) Accept(visitor StmtVisitor) error {
 }
 
 type WhileStmt struct {
+	Keyword   lexer.Token // `while`, or `for` when desugared from a for loop
 	Condition Expr
 	Body      Stmt
 }
@@ -66,10 +71,114 @@ func (w *WhileStmt) Accept(visitor StmtVisitor) error {
 }
 
 type FunctionStmt struct {
-	Name   lexer.Token
-	Params []lexer.Token
-	Body   []Stmt
+	Name       lexer.Token
+	Generator  bool // declared with `fun*` or containing a `yield`, calling it returns a generator
+	Params     []lexer.Token
+	ParamTypes []*TypeAnnotation // parallel to Params, nil entries are unannotated
+	ReturnType *TypeAnnotation
+	Body       []Stmt
 }
 
 func (f *FunctionStmt) Statement()                       {}
 func (f *FunctionStmt) Accept(visitor StmtVisitor) error { return visitor.VisitFunctionStmt(f) }
+
+type ReturnStmt struct {
+	Keyword lexer.Token
+	Value   Expr // nil for a bare `return;`
+}
+
+func (r *ReturnStmt) Statement()                       {}
+func (r *ReturnStmt) Accept(visitor StmtVisitor) error { return visitor.VisitReturnStmt(r) }
+
+// yield hands a value to whoever is consuming the enclosing generator and pauses it until the next one is asked for
+type YieldStmt struct {
+	Keyword lexer.Token
+	Value   Expr // nil for a bare `yield;`, which yields nil
+}
+
+func (y *YieldStmt) Statement()                       {}
+func (y *YieldStmt) Accept(visitor StmtVisitor) error { return visitor.VisitYieldStmt(y) }
+
+// test "name" { body }. only `lox test` runs these, running the file normally skips them
+type TestStmt struct {
+	Keyword lexer.Token
+	Name    string
+	Body    []Stmt
+}
+
+func (t *TestStmt) Statement()                       {}
+func (t *TestStmt) Accept(visitor StmtVisitor) error { return visitor.VisitTestStmt(t) }
+
+// BadStmt stands in for a statement with a syntax error. From and To are the first and last tokens of
+// the span it covers, including whatever the parser skipped to recover. Partial is as much of the
+// statement as was parsed, with BadExpr for the missing pieces, and nil when nothing was
+type BadStmt struct {
+	From    lexer.Token
+	To      lexer.Token
+	Partial Stmt
+}
+
+func (b *BadStmt) Statement()                       {}
+func (b *BadStmt) Accept(visitor StmtVisitor) error { return visitor.VisitBadStmt(b) }
+
+// an optional type written after a `:`, like `number` or `string?`. the interpreter ignores these,
+// they only exist for the type checker
+type TypeAnnotation struct {
+	Name     lexer.Token
+	Nullable bool
+}
+
+func (t *TypeAnnotation) String() string {
+	if t.Nullable {
+		return t.Name.Lexeme + "?"
+	}
+	return t.Name.Lexeme
+}
+
+// match (subject) { case pattern, pattern if guard => body ... }
+type MatchStmt struct {
+	Keyword lexer.Token
+	Subject Expr
+	Cases   []MatchCase
+}
+
+func (m *MatchStmt) Statement()                       {}
+func (m *MatchStmt) Accept(visitor StmtVisitor) error { return visitor.VisitMatchStmt(m) }
+
+// a case matches when any of its patterns match and its guard, if present, is truthy
+type MatchCase struct {
+	Keyword  lexer.Token
+	Patterns []MatchPattern
+	Guard    Expr
+	Body     Stmt
+}
+
+type PatternKind int
+
+const (
+	// LiteralPattern matches values equal to Literal
+	LiteralPattern PatternKind = iota
+	// BindingPattern matches anything and binds it to Token's name
+	BindingPattern
+	// WildcardPattern `_` matches anything
+	WildcardPattern
+)
+
+type MatchPattern struct {
+	Kind    PatternKind
+	Token   lexer.Token
+	Literal *LiteralExpr
+}
+
+// Irrefutable reports whether the case matches every value, making any case after it unreachable
+func (c *MatchCase) Irrefutable() bool {
+	if c.Guard != nil {
+		return false
+	}
+	for _, pattern := range c.Patterns {
+		if pattern.Kind != LiteralPattern {
+			return true
+		}
+	}
+	return false
+}
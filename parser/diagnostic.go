@@ -0,0 +1,20 @@
+package parser
+
+import "fmt"
+
+// Diagnostic is an error or warning the parser reported, with the token it was reported at
+// described by Where, like `at end` or `at ;`
+type Diagnostic struct {
+	Line    int
+	Where   string
+	Message string
+	Warning bool
+}
+
+// String formats the diagnostic the way the parser reports it in Errors and Warnings
+func (d Diagnostic) String() string {
+	if d.Warning {
+		return fmt.Sprintf("[line %d] Warning %s: %s", d.Line, d.Where, d.Message)
+	}
+	return formatErrorMessage(d.Line, d.Where, d.Message)
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+// tooDeep nests far past maxNesting, deep enough to overflow the stack if the parser let it through
+const tooDeep = 200 * maxNesting
+
+var fuzzSeeds = []string{
+	"",
+	"print 1 + 2 * 3;",
+	"var a: number? = nil; a ??= 1;",
+	"fun *count(n) { for (var i = 0; i < n; i++) yield i; }",
+	"match (x) { case 1, -2 => print x; case _ if x > 1 => {} }",
+	`test "adds" { assertEqual(1 + 1, 2); }`,
+	"spawn f(a?.b, c ? d : e);",
+	"fun (){ var = ; } print",
+	"{ { { ",
+	") } ] ;; =>",
+	"#!/usr/bin/env lox\nprint \"unterminated",
+	"1 = 2; ++3; a.b += ; return; yield;",
+	// nesting far past maxNesting, which would overflow the stack if it were parsed
+	"print " + strings.Repeat("(", tooDeep) + "1" + strings.Repeat(")", tooDeep) + ";",
+	"print " + strings.Repeat("- ", tooDeep) + "1; a = " + strings.Repeat("a = ", tooDeep) + "1;",
+	strings.Repeat("{ if (a) ", tooDeep) + strings.Repeat("}", tooDeep),
+	// chains of left associative operators nest just as deeply
+	"print a" + strings.Repeat(" + a", 15*tooDeep) + "; a" + strings.Repeat("?.b", tooDeep) + strings.Repeat(" ?? a", tooDeep) + ";",
+}
+
+// FuzzParse feeds arbitrary bytes through the scanner and parser, which must never panic and must
+// hand back a tree every visitor can walk
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, source string) {
+		stmts, diagnostics := Parse(lexer.NewScanner(source).ScanTokens())
+
+		for _, stmt := range stmts {
+			if stmt == nil {
+				t.Fatalf("nil statement parsing %q", source)
+			}
+		}
+
+		errors := 0
+		for _, diagnostic := range diagnostics {
+			if !diagnostic.Warning {
+				errors++
+			}
+		}
+		if errors == 0 && hasBadStmt(stmts) {
+			t.Fatalf("bad statement without an error parsing %q", source)
+		}
+
+		(&ASTPrinter{}).PrintProgram(stmts)
+		data, err := EncodeJSON(stmts)
+		if err != nil {
+			t.Fatalf("encoding %q: %s", source, err)
+		}
+		if _, err := DecodeJSON(data); err != nil {
+			t.Fatalf("decoding %q: %s", source, err)
+		}
+	})
+}
+
+func hasBadStmt(stmts []Stmt) bool {
+	for _, stmt := range stmts {
+		if _, ok := stmt.(*BadStmt); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,617 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/brandonshearin/go-lox/lexer"
+)
+
+// ASTVersion is the version of the JSON schema written by EncodeJSON. it changes whenever a node kind
+// or field is renamed or removed, adding one doesn't change it
+const ASTVersion = 1
+
+// the schema: a program is {"version": 1, "statements": [...]}, and every statement and expression is
+// an object whose "kind" names the node type, with one field per field of the Go node. tokens are
+// {"type", "lexeme", "literal", "line"}, and null when the parser made them up without a type or line. a nil list is null,
+// which keeps it apart from an empty one
+type jsonProgram struct {
+	Version    int `json:"version"`
+	Statements any `json:"statements"`
+}
+
+type jsonToken struct {
+	Type    string `json:"type"`
+	Lexeme  string `json:"lexeme"`
+	Literal any    `json:"literal,omitempty"`
+	Line    int    `json:"line"`
+}
+
+// EncodeJSON serializes a program into the versioned JSON schema
+func EncodeJSON(stmts []Stmt) ([]byte, error) {
+	e := &jsonEncoder{}
+	return json.MarshalIndent(jsonProgram{Version: ASTVersion, Statements: e.stmts(stmts)}, "", "  ")
+}
+
+// DecodeJSON rebuilds the program EncodeJSON serialized. it fails on anything EncodeJSON wouldn't have
+// written, like a node missing a child it can't do without
+func DecodeJSON(data []byte) ([]Stmt, error) {
+	var program struct {
+		Version    int `json:"version"`
+		Statements any `json:"statements"`
+	}
+	if err := json.Unmarshal(data, &program); err != nil {
+		return nil, err
+	}
+	if program.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported AST version %d, expected %d", program.Version, ASTVersion)
+	}
+
+	d := &jsonDecoder{}
+	stmts := d.stmts(program.Statements)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return stmts, nil
+}
+
+// jsonEncoder turns nodes into maps for encoding/json. implements ExprVisitor and StmtVisitor
+type jsonEncoder struct {
+	// the statement just visited, StmtVisitor methods have no other way to hand it back
+	out map[string]any
+}
+
+type jsonNode = map[string]any
+
+func (e *jsonEncoder) stmt(stmt Stmt) any {
+	if stmt == nil {
+		return nil
+	}
+	stmt.Accept(e)
+	return e.out
+}
+
+func (e *jsonEncoder) stmts(stmts []Stmt) any {
+	if stmts == nil {
+		return nil
+	}
+	encoded := make([]any, len(stmts))
+	for i, stmt := range stmts {
+		encoded[i] = e.stmt(stmt)
+	}
+	return encoded
+}
+
+func (e *jsonEncoder) expr(expr Expr) any {
+	if expr == nil {
+		return nil
+	}
+	encoded, _ := expr.Accept(e)
+	return encoded
+}
+
+func (e *jsonEncoder) exprs(exprs []Expr) any {
+	if exprs == nil {
+		return nil
+	}
+	encoded := make([]any, len(exprs))
+	for i, expr := range exprs {
+		encoded[i] = e.expr(expr)
+	}
+	return encoded
+}
+
+func encodeToken(token lexer.Token) any {
+	if token == (lexer.Token{}) {
+		return nil
+	}
+	return jsonToken{Type: token.TokenType.String(), Lexeme: token.Lexeme, Literal: token.Literal, Line: token.Line}
+}
+
+func encodeTokens(tokens []lexer.Token) any {
+	if tokens == nil {
+		return nil
+	}
+	encoded := make([]any, len(tokens))
+	for i, token := range tokens {
+		encoded[i] = encodeToken(token)
+	}
+	return encoded
+}
+
+func encodeType(annotation *TypeAnnotation) any {
+	if annotation == nil {
+		return nil
+	}
+	return jsonNode{"name": encodeToken(annotation.Name), "nullable": annotation.Nullable}
+}
+
+// ExprVisitor implementation below ----------------------------------------------------------------
+func (e *jsonEncoder) VisitLiteralExpr(expr *LiteralExpr) (any, error) {
+	return jsonNode{"kind": "literal", "value": expr.Value, "isBoolean": expr.IsBoolean, "isNil": expr.IsNil, "token": encodeToken(expr.Token)}, nil
+}
+
+func (e *jsonEncoder) VisitUnaryExpr(expr *UnaryExpr) (any, error) {
+	return jsonNode{"kind": "unary", "operator": encodeToken(lexer.Token(expr.Operator)), "expr": e.expr(expr.Expr)}, nil
+}
+
+func (e *jsonEncoder) VisitBinaryExpr(expr *BinaryExpr) (any, error) {
+	return jsonNode{"kind": "binary", "left": e.expr(expr.LeftExpr), "operator": encodeToken(lexer.Token(expr.Operator)), "right": e.expr(expr.RightExpr)}, nil
+}
+
+func (e *jsonEncoder) VisitGroupingExpr(expr *GroupingExpr) (any, error) {
+	return jsonNode{"kind": "grouping", "expr": e.expr(expr.Expr)}, nil
+}
+
+func (e *jsonEncoder) VisitVariableExpr(expr *VariableExpr) (any, error) {
+	return jsonNode{"kind": "variable", "name": encodeToken(expr.Name)}, nil
+}
+
+func (e *jsonEncoder) VisitAssignExpr(expr *AssignExpr) (any, error) {
+	return jsonNode{"kind": "assign", "name": encodeToken(expr.Name), "value": e.expr(expr.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitCompoundAssignExpr(expr *CompoundAssignExpr) (any, error) {
+	return jsonNode{"kind": "compoundAssign", "name": encodeToken(expr.Name), "operator": encodeToken(lexer.Token(expr.Operator)), "value": e.expr(expr.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitIncrementExpr(expr *IncrementExpr) (any, error) {
+	return jsonNode{"kind": "increment", "name": encodeToken(expr.Name), "operator": encodeToken(lexer.Token(expr.Operator)), "prefix": expr.Prefix}, nil
+}
+
+func (e *jsonEncoder) VisitLogicalExpr(expr *LogicalExpr) (any, error) {
+	return jsonNode{"kind": "logical", "operator": encodeToken(lexer.Token(expr.Operator)), "left": e.expr(expr.Left), "right": e.expr(expr.Right)}, nil
+}
+
+func (e *jsonEncoder) VisitConditionalExpr(expr *ConditionalExpr) (any, error) {
+	return jsonNode{"kind": "conditional", "condition": e.expr(expr.Condition), "then": e.expr(expr.ThenBranch), "else": e.expr(expr.ElseBranch)}, nil
+}
+
+func (e *jsonEncoder) VisitCallExpr(expr *CallExpr) (any, error) {
+	return jsonNode{"kind": "call", "callee": e.expr(expr.Callee), "paren": encodeToken(expr.Paren), "arguments": e.exprs(expr.Arguments), "optional": expr.Optional}, nil
+}
+
+func (e *jsonEncoder) VisitGetExpr(expr *GetExpr) (any, error) {
+	return jsonNode{"kind": "get", "object": e.expr(expr.Object), "name": encodeToken(expr.Name), "optional": expr.Optional}, nil
+}
+
+func (e *jsonEncoder) VisitOptionalChainExpr(expr *OptionalChainExpr) (any, error) {
+	return jsonNode{"kind": "optionalChain", "expr": e.expr(expr.Expr)}, nil
+}
+
+func (e *jsonEncoder) VisitSpawnExpr(expr *SpawnExpr) (any, error) {
+	return jsonNode{"kind": "spawn", "keyword": encodeToken(expr.Keyword), "call": e.expr(expr.Call)}, nil
+}
+
+func (e *jsonEncoder) VisitBadExpr(expr *BadExpr) (any, error) {
+	return jsonNode{"kind": "bad", "from": encodeToken(expr.From), "to": encodeToken(expr.To)}, nil
+}
+
+// StmtVisitor implementation below ----------------------------------------------------------------
+func (e *jsonEncoder) VisitPrintStmt(stmt *PrintStmt) error {
+	e.out = jsonNode{"kind": "print", "keyword": encodeToken(stmt.Keyword), "expr": e.expr(stmt.Expr)}
+	return nil
+}
+
+func (e *jsonEncoder) VisitExpressionStmt(stmt *ExpressionStmt) error {
+	e.out = jsonNode{"kind": "expression", "expr": e.expr(stmt.Expr)}
+	return nil
+}
+
+func (e *jsonEncoder) VisitVariableDeclStmt(stmt *VariableDeclarationStmt) error {
+	e.out = jsonNode{"kind": "var", "name": encodeToken(stmt.Name), "type": encodeType(stmt.Type), "initializer": e.expr(stmt.Initializer)}
+	return nil
+}
+
+func (e *jsonEncoder) VisitBlockStmt(stmt *BlockStmt) error {
+	stmts := e.stmts(stmt.Stmts)
+	e.out = jsonNode{"kind": "block", "brace": encodeToken(stmt.Brace), "statements": stmts}
+	return nil
+}
+
+func (e *jsonEncoder) VisitIfStmt(stmt *IfStmt) error {
+	then := e.stmt(stmt.ThenBranch)
+	els := e.stmt(stmt.ElseBranch)
+	e.out = jsonNode{"kind": "if", "keyword": encodeToken(stmt.Keyword), "condition": e.expr(stmt.Condition), "then": then, "else": els}
+	return nil
+}
+
+func (e *jsonEncoder) VisitWhileStmt(stmt *WhileStmt) error {
+	body := e.stmt(stmt.Body)
+	e.out = jsonNode{"kind": "while", "keyword": encodeToken(stmt.Keyword), "condition": e.expr(stmt.Condition), "body": body}
+	return nil
+}
+
+func (e *jsonEncoder) VisitFunctionStmt(stmt *FunctionStmt) error {
+	var paramTypes any
+	if stmt.ParamTypes != nil {
+		types := make([]any, len(stmt.ParamTypes))
+		for i, annotation := range stmt.ParamTypes {
+			types[i] = encodeType(annotation)
+		}
+		paramTypes = types
+	}
+
+	body := e.stmts(stmt.Body)
+	e.out = jsonNode{
+		"kind":       "function",
+		"name":       encodeToken(stmt.Name),
+		"generator":  stmt.Generator,
+		"params":     encodeTokens(stmt.Params),
+		"paramTypes": paramTypes,
+		"returnType": encodeType(stmt.ReturnType),
+		"body":       body,
+	}
+	return nil
+}
+
+func (e *jsonEncoder) VisitReturnStmt(stmt *ReturnStmt) error {
+	e.out = jsonNode{"kind": "return", "keyword": encodeToken(stmt.Keyword), "value": e.expr(stmt.Value)}
+	return nil
+}
+
+func (e *jsonEncoder) VisitYieldStmt(stmt *YieldStmt) error {
+	e.out = jsonNode{"kind": "yield", "keyword": encodeToken(stmt.Keyword), "value": e.expr(stmt.Value)}
+	return nil
+}
+
+func (e *jsonEncoder) VisitTestStmt(stmt *TestStmt) error {
+	body := e.stmts(stmt.Body)
+	e.out = jsonNode{"kind": "test", "keyword": encodeToken(stmt.Keyword), "name": stmt.Name, "body": body}
+	return nil
+}
+
+func (e *jsonEncoder) VisitBadStmt(stmt *BadStmt) error {
+	partial := e.stmt(stmt.Partial)
+	e.out = jsonNode{"kind": "bad", "from": encodeToken(stmt.From), "to": encodeToken(stmt.To), "partial": partial}
+	return nil
+}
+
+var patternKinds = map[PatternKind]string{LiteralPattern: "literal", BindingPattern: "binding", WildcardPattern: "wildcard"}
+
+func (e *jsonEncoder) VisitMatchStmt(stmt *MatchStmt) error {
+	var cases any
+	if stmt.Cases != nil {
+		encoded := make([]any, len(stmt.Cases))
+		for i, matchCase := range stmt.Cases {
+			var patterns any
+			if matchCase.Patterns != nil {
+				list := make([]any, len(matchCase.Patterns))
+				for j, pattern := range matchCase.Patterns {
+					var literal any
+					if pattern.Literal != nil {
+						literal = e.expr(pattern.Literal)
+					}
+					list[j] = jsonNode{"kind": patternKinds[pattern.Kind], "token": encodeToken(pattern.Token), "literal": literal}
+				}
+				patterns = list
+			}
+			encoded[i] = jsonNode{"keyword": encodeToken(matchCase.Keyword), "patterns": patterns, "guard": e.expr(matchCase.Guard), "body": e.stmt(matchCase.Body)}
+		}
+		cases = encoded
+	}
+
+	e.out = jsonNode{"kind": "match", "keyword": encodeToken(stmt.Keyword), "subject": e.expr(stmt.Subject), "cases": cases}
+	return nil
+}
+
+// jsonDecoder rebuilds nodes from the values encoding/json decoded the whole document into, so each
+// node is only looked at once. the first problem it finds sticks in err and everything after it decodes
+// to nil
+type jsonDecoder struct {
+	err error
+	// how many BadStmt partials are being decoded. the parser leaves pieces of those out, so children
+	// that are required anywhere else may be missing
+	partial int
+}
+
+type jsonObject map[string]any
+
+func (d *jsonDecoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+// object returns value's fields, and nil for null
+func (d *jsonDecoder) object(value any) jsonObject {
+	if d.err != nil || value == nil {
+		return nil
+	}
+	obj, ok := value.(map[string]any)
+	if !ok {
+		d.fail("invalid AST node: expected an object, got %s", jsonKind(value))
+		return nil
+	}
+	return obj
+}
+
+// list returns value's elements, and nil for null
+func (d *jsonDecoder) list(value any) []any {
+	if d.err != nil || value == nil {
+		return nil
+	}
+	list, ok := value.([]any)
+	if !ok {
+		d.fail("invalid AST list: expected an array, got %s", jsonKind(value))
+		return []any{}
+	}
+	return list
+}
+
+// jsonKind names the JSON type of a decoded value for errors
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// field returns obj's field name as a T, or T's zero value when it is missing or null
+func field[T any](d *jsonDecoder, obj jsonObject, name string) T {
+	var zero T
+	value := obj[name]
+	if d.err != nil || value == nil {
+		return zero
+	}
+	typed, ok := value.(T)
+	if !ok {
+		d.fail("invalid AST field '%s': expected %s, got %s", name, jsonKind(zero), jsonKind(value))
+	}
+	return typed
+}
+
+// required decodes the child in field name of a node, failing when it is missing
+func required[T comparable](d *jsonDecoder, obj jsonObject, name string, decode func(any) T) T {
+	child := decode(obj[name])
+	var zero T
+	if child == zero && d.err == nil && d.partial == 0 {
+		d.fail("'%s' node is missing '%s'", d.kind(obj), name)
+	}
+	return child
+}
+
+func (d *jsonDecoder) token(value any) lexer.Token {
+	obj := d.object(value)
+	if obj == nil {
+		return lexer.Token{}
+	}
+
+	name := field[string](d, obj, "type")
+	tokenType, ok := lexer.ParseTokenType(name)
+	if !ok && d.err == nil {
+		d.fail("unknown token type '%s'", name)
+	}
+	return lexer.Token{
+		TokenType: tokenType,
+		Lexeme:    field[string](d, obj, "lexeme"),
+		Literal:   obj["literal"],
+		Line:      int(field[float64](d, obj, "line")),
+	}
+}
+
+func (d *jsonDecoder) tokens(value any) []lexer.Token {
+	list := d.list(value)
+	if list == nil {
+		return nil
+	}
+	tokens := make([]lexer.Token, len(list))
+	for i, item := range list {
+		tokens[i] = d.token(item)
+	}
+	return tokens
+}
+
+func (d *jsonDecoder) annotation(value any) *TypeAnnotation {
+	obj := d.object(value)
+	if obj == nil {
+		return nil
+	}
+	return &TypeAnnotation{Name: d.token(obj["name"]), Nullable: field[bool](d, obj, "nullable")}
+}
+
+func (d *jsonDecoder) kind(obj jsonObject) string {
+	return field[string](d, obj, "kind")
+}
+
+func (d *jsonDecoder) exprs(value any) []Expr {
+	list := d.list(value)
+	if list == nil {
+		return nil
+	}
+	exprs := make([]Expr, len(list))
+	for i, item := range list {
+		if item == nil {
+			d.fail("null expression in list")
+		}
+		exprs[i] = d.expr(item)
+	}
+	return exprs
+}
+
+func (d *jsonDecoder) stmts(value any) []Stmt {
+	list := d.list(value)
+	if list == nil {
+		return nil
+	}
+	stmts := make([]Stmt, len(list))
+	for i, item := range list {
+		if item == nil {
+			d.fail("null statement in list")
+		}
+		stmts[i] = d.stmt(item)
+	}
+	return stmts
+}
+
+func (d *jsonDecoder) literal(obj jsonObject) *LiteralExpr {
+	return &LiteralExpr{Value: obj["value"], IsBoolean: field[bool](d, obj, "isBoolean"), IsNil: field[bool](d, obj, "isNil"), Token: d.token(obj["token"])}
+}
+
+func (d *jsonDecoder) expr(value any) Expr {
+	obj := d.object(value)
+	if obj == nil {
+		return nil
+	}
+	child := func(name string) Expr { return required(d, obj, name, d.expr) }
+
+	switch kind := d.kind(obj); kind {
+	case "literal":
+		return d.literal(obj)
+	case "unary":
+		return &UnaryExpr{Operator: Operator(d.token(obj["operator"])), Expr: child("expr")}
+	case "binary":
+		return &BinaryExpr{LeftExpr: child("left"), Operator: Operator(d.token(obj["operator"])), RightExpr: child("right")}
+	case "grouping":
+		return &GroupingExpr{Expr: child("expr")}
+	case "variable":
+		return &VariableExpr{Name: d.token(obj["name"])}
+	case "assign":
+		return &AssignExpr{Name: d.token(obj["name"]), Value: child("value")}
+	case "compoundAssign":
+		return &CompoundAssignExpr{Name: d.token(obj["name"]), Operator: Operator(d.token(obj["operator"])), Value: child("value")}
+	case "increment":
+		return &IncrementExpr{Name: d.token(obj["name"]), Operator: Operator(d.token(obj["operator"])), Prefix: field[bool](d, obj, "prefix")}
+	case "logical":
+		return &LogicalExpr{Operator: Operator(d.token(obj["operator"])), Left: child("left"), Right: child("right")}
+	case "conditional":
+		return &ConditionalExpr{Condition: child("condition"), ThenBranch: child("then"), ElseBranch: child("else")}
+	case "call":
+		return d.call(obj)
+	case "get":
+		return &GetExpr{Object: child("object"), Name: d.token(obj["name"]), Optional: field[bool](d, obj, "optional")}
+	case "optionalChain":
+		return &OptionalChainExpr{Expr: child("expr")}
+	case "spawn":
+		call := d.object(obj["call"])
+		if call == nil || d.kind(call) != "call" {
+			d.fail("spawn must wrap a call")
+			return nil
+		}
+		return &SpawnExpr{Keyword: d.token(obj["keyword"]), Call: d.call(call)}
+	case "bad":
+		return &BadExpr{From: d.token(obj["from"]), To: d.token(obj["to"])}
+	default:
+		d.fail("unknown expression kind '%s'", kind)
+		return nil
+	}
+}
+
+func (d *jsonDecoder) call(obj jsonObject) *CallExpr {
+	return &CallExpr{Callee: required(d, obj, "callee", d.expr), Paren: d.token(obj["paren"]), Arguments: d.exprs(obj["arguments"]), Optional: field[bool](d, obj, "optional")}
+}
+
+func (d *jsonDecoder) stmt(value any) Stmt {
+	obj := d.object(value)
+	if obj == nil {
+		return nil
+	}
+	expr := func(name string) Expr { return required(d, obj, name, d.expr) }
+	stmt := func(name string) Stmt { return required(d, obj, name, d.stmt) }
+
+	switch kind := d.kind(obj); kind {
+	case "print":
+		return &PrintStmt{Keyword: d.token(obj["keyword"]), Expr: expr("expr")}
+	case "expression":
+		return &ExpressionStmt{Expr: expr("expr")}
+	case "var":
+		return &VariableDeclarationStmt{Name: d.token(obj["name"]), Type: d.annotation(obj["type"]), Initializer: d.expr(obj["initializer"])}
+	case "block":
+		return &BlockStmt{Brace: d.token(obj["brace"]), Stmts: d.stmts(obj["statements"])}
+	case "if":
+		return &IfStmt{Keyword: d.token(obj["keyword"]), Condition: expr("condition"), ThenBranch: stmt("then"), ElseBranch: d.stmt(obj["else"])}
+	case "while":
+		return &WhileStmt{Keyword: d.token(obj["keyword"]), Condition: expr("condition"), Body: stmt("body")}
+	case "function":
+		function := &FunctionStmt{
+			Name:       d.token(obj["name"]),
+			Generator:  field[bool](d, obj, "generator"),
+			Params:     d.tokens(obj["params"]),
+			ReturnType: d.annotation(obj["returnType"]),
+			Body:       d.stmts(obj["body"]),
+		}
+		if list := d.list(obj["paramTypes"]); list != nil {
+			function.ParamTypes = make([]*TypeAnnotation, len(list))
+			for i, item := range list {
+				function.ParamTypes[i] = d.annotation(item)
+			}
+		}
+		return function
+	case "return":
+		return &ReturnStmt{Keyword: d.token(obj["keyword"]), Value: d.expr(obj["value"])}
+	case "yield":
+		return &YieldStmt{Keyword: d.token(obj["keyword"]), Value: d.expr(obj["value"])}
+	case "test":
+		return &TestStmt{Keyword: d.token(obj["keyword"]), Name: field[string](d, obj, "name"), Body: d.stmts(obj["body"])}
+	case "match":
+		return d.match(obj)
+	case "bad":
+		d.partial++
+		defer func() { d.partial-- }()
+		return &BadStmt{From: d.token(obj["from"]), To: d.token(obj["to"]), Partial: d.stmt(obj["partial"])}
+	default:
+		d.fail("unknown statement kind '%s'", kind)
+		return nil
+	}
+}
+
+func (d *jsonDecoder) match(obj jsonObject) *MatchStmt {
+	match := &MatchStmt{Keyword: d.token(obj["keyword"]), Subject: required(d, obj, "subject", d.expr)}
+
+	if list := d.list(obj["cases"]); list != nil {
+		match.Cases = make([]MatchCase, len(list))
+		for i, item := range list {
+			caseObj := d.object(item)
+			if caseObj == nil {
+				d.fail("null match case in list")
+				return match
+			}
+			matchCase := MatchCase{Keyword: d.token(caseObj["keyword"]), Guard: d.expr(caseObj["guard"]), Body: required(d, caseObj, "body", d.stmt)}
+
+			if patterns := d.list(caseObj["patterns"]); patterns != nil {
+				matchCase.Patterns = make([]MatchPattern, len(patterns))
+				for j, rawPattern := range patterns {
+					matchCase.Patterns[j] = d.pattern(d.object(rawPattern))
+				}
+			}
+			match.Cases[i] = matchCase
+		}
+	}
+	return match
+}
+
+func (d *jsonDecoder) pattern(obj jsonObject) MatchPattern {
+	if obj == nil {
+		d.fail("null match pattern in list")
+		return MatchPattern{}
+	}
+	pattern := MatchPattern{Token: d.token(obj["token"])}
+
+	switch kind := d.kind(obj); kind {
+	case "literal":
+		pattern.Kind = LiteralPattern
+	case "binding":
+		pattern.Kind = BindingPattern
+	case "wildcard":
+		pattern.Kind = WildcardPattern
+	default:
+		d.fail("unknown pattern kind '%s'", kind)
+	}
+
+	if literal := d.object(obj["literal"]); literal != nil {
+		pattern.Literal = d.literal(literal)
+	} else if pattern.Kind == LiteralPattern && d.partial == 0 {
+		d.fail("'literal' pattern is missing 'literal'")
+	}
+	return pattern
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/stretchr/testify/assert"
+)
+
+// uses every kind of node
+const jsonSource = `
+var a: number? = -(1 + 2) * 3 ** 2;
+var b = nil ?? "s";
+a += 1; a++; --a; b = a > 1 ? true : false;
+fun* gen(x: number, y): string { yield x; yield; return; }
+fun f() { return obj?.field.method(1, 2) and !a or f(); }
+for (var i = 0; i < 3; i = i + 1) { print i; }
+for (;;) {}
+while (a) if (b) print a; else {}
+var t = spawn f();
+match (a) {
+  case 1, "x", true, nil => print 1;
+  case n if n > 2 => print n;
+  case _ => {}
+}
+test "adds" { return; }
+`
+
+func TestJSONRoundTrip(t *testing.T) {
+	p := NewParser(lexer.NewScanner(jsonSource).ScanTokens())
+	stmts := p.Parse()
+	assert.Empty(t, p.Errors)
+
+	data, err := EncodeJSON(stmts)
+	assert.Nil(t, err)
+
+	decoded, err := DecodeJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, stmts, decoded)
+}
+
+func TestJSONSchema(t *testing.T) {
+	p := NewParser(lexer.NewScanner("print x + 1;\nfor (;;) {}").ScanTokens())
+	data, err := EncodeJSON(p.Parse())
+	assert.Nil(t, err)
+
+	var program map[string]any
+	assert.Nil(t, json.Unmarshal(data, &program))
+	assert.Equal(t, float64(ASTVersion), program["version"])
+
+	statements := program["statements"].([]any)
+	print := statements[0].(map[string]any)
+	assert.Equal(t, "print", print["kind"])
+	assert.Equal(t, map[string]any{"type": "PRINT", "lexeme": "print", "line": float64(1)}, print["keyword"])
+
+	binary := print["expr"].(map[string]any)
+	assert.Equal(t, "binary", binary["kind"])
+	assert.Equal(t, map[string]any{"type": "NUMBER", "lexeme": "1", "literal": float64(1), "line": float64(1)}, binary["right"].(map[string]any)["token"])
+
+	// the `true` condition of `for (;;)` was made up by the parser, so it has no token
+	loop := statements[1].(map[string]any)
+	assert.Equal(t, "while", loop["kind"])
+	assert.Nil(t, loop["condition"].(map[string]any)["token"])
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	cases := []struct {
+		ID      int
+		JSON    string
+		Message string
+	}{
+		{ID: 1, JSON: `{"version": 2, "statements": []}`, Message: "unsupported AST version 2, expected 1"},
+		{ID: 2, JSON: `{"version": 1, "statements": [{"kind": "goto"}]}`, Message: "unknown statement kind 'goto'"},
+		{ID: 3, JSON: `{"version": 1, "statements": [{"kind": "expression", "expr": {"kind": "lambda"}}]}`, Message: "unknown expression kind 'lambda'"},
+		{ID: 4, JSON: `{"version": 1, "statements": [{"kind": "expression", "expr": {"kind": "variable", "name": {"type": "NAME"}}}]}`, Message: "unknown token type 'NAME'"},
+		{ID: 5, JSON: `{"version": 1, "statements": [{"kind": "expression", "expr": {"kind": "spawn", "call": {"kind": "variable"}}}]}`, Message: "spawn must wrap a call"},
+		// children the interpreter can't do without have to be there
+		{ID: 6, JSON: `{"version": 1, "statements": [{"kind": "print"}]}`, Message: "'print' node is missing 'expr'"},
+		{ID: 7, JSON: `{"version": 1, "statements": [null]}`, Message: "null statement in list"},
+		{ID: 8, JSON: `{"version": 1, "statements": [{"kind": "expression", "expr": {"kind": "binary", "operator": {"type": "PLUS", "lexeme": "+", "line": 1}}}]}`, Message: "'binary' node is missing 'left'"},
+		{ID: 9, JSON: `{"version": 1, "statements": [{"kind": "expression", "expr": {"kind": "binary", "left": {"kind": "literal", "value": 1}, "right": null}}]}`, Message: "'binary' node is missing 'right'"},
+		{ID: 10, JSON: `{"version": 1, "statements": [{"kind": "block", "statements": [{"kind": "while", "condition": {"kind": "literal", "value": true}}]}]}`, Message: "'while' node is missing 'body'"},
+		{ID: 11, JSON: `{"version": 1, "statements": [{"kind": "expression", "expr": {"kind": "call", "callee": {"kind": "variable"}, "arguments": [null]}}]}`, Message: "null expression in list"},
+		{ID: 12, JSON: `{"version": 1, "statements": [{"kind": "match", "subject": {"kind": "variable"}, "cases": [{"patterns": [{"kind": "literal"}], "body": {"kind": "block"}}]}]}`, Message: "'literal' pattern is missing 'literal'"},
+		{ID: 13, JSON: `{"version": 1, "statements": [{"kind": "if", "condition": {"kind": "literal"}, "then": {"kind": "block"}, "else": 1}]}`, Message: "invalid AST node: expected an object, got number"},
+		{ID: 14, JSON: `{"version": 1, "statements": [{"kind": "var", "name": {"type": "IDENTIFIER", "line": "1"}}]}`, Message: "invalid AST field 'line': expected number, got string"},
+	}
+
+	for _, testCase := range cases {
+		_, err := DecodeJSON([]byte(testCase.JSON))
+		if assert.NotNil(t, err, "test case %d failed", testCase.ID) {
+			assert.Equal(t, testCase.Message, err.Error(), "test case %d failed", testCase.ID)
+		}
+	}
+
+	// the parser leaves pieces out of a bad statement's partial one, so those may be missing
+	stmts, err := DecodeJSON([]byte(`{"version": 1, "statements": [{"kind": "bad", "partial": {"kind": "print"}}]}`))
+	assert.Nil(t, err)
+	assert.Nil(t, stmts[0].(*BadStmt).Partial.(*PrintStmt).Expr)
+}
@@ -8,9 +8,19 @@ import (
 )
 
 type Parser struct {
-	Tokens  []lexer.Token
-	Current int
-	Errors  []string
+	Tokens   []lexer.Token
+	Current  int
+	Errors   []string
+	Warnings []string
+
+	// Diagnostics holds every error and warning in the order they were found
+	Diagnostics []Diagnostic
+
+	functionDepth int  // how many function bodies enclose the current token, `return` is only valid inside one
+	blockDepth    int  // how many blocks enclose the current token, tests can only be declared outside all of them
+	sawYield      bool // whether the innermost function body parsed so far contains a `yield`
+	panicking     bool // whether the innermost declaration has reported an error it hasn't recovered from
+	nesting       int  // how many expressions and statements the current token is nested in, see maxNesting
 }
 
 func NewParser(tokens []lexer.Token) *Parser {
@@ -30,36 +40,65 @@ func (p *Parser) Parse() []Stmt {
 	return stmts
 }
 
-// declaration    → varDecl | statement ;
+// Parse parses tokens into a program and every problem found along the way. it never panics: a
+// statement with a syntax error is parsed as a BadStmt, and parsing carries on after it
+func Parse(tokens []lexer.Token) ([]Stmt, []Diagnostic) {
+	p := NewParser(tokens)
+	stmts := p.Parse()
+	return stmts, p.Diagnostics
+}
+
+// declaration    → varDecl | funDecl | testDecl | statement ;
+//
+// a declaration that reports an error skips ahead to the next statement and comes back as a BadStmt
+// spanning everything it consumed. nested declarations recover on their own, so an error in a
+// function body doesn't also throw away the statements after the function
 func (p *Parser) declaration() Stmt {
 	var stmt Stmt
+	start := p.Current
+	enclosingPanicking := p.panicking
+	p.panicking = false
+	defer func() { p.panicking = enclosingPanicking }()
 
 	if p.match(lexer.VAR) {
 		stmt = p.varDeclaration()
 	} else if p.match(lexer.FUN) {
 		stmt = p.functionDeclaration("function")
+	} else if p.atTestDeclaration() {
+		p.advance()
+		stmt = p.testDeclaration()
 	} else {
 		stmt = p.statement()
 	}
-	// TODO: whats the best way to handle errors
-	if len(p.Errors) > 0 {
-		p.synchronize()
+
+	if !p.panicking {
 		return stmt
 	}
 
-	return stmt
+	// a token nothing could parse, like a stray `}`, would otherwise be retried forever
+	if p.Current == start {
+		p.advance()
+	}
+	p.synchronize()
+
+	return &BadStmt{From: p.at(start), To: p.previous(), Partial: stmt}
 }
 
+// function → "*"? IDENTIFIER "(" parameters? ")" ( ":" type )? block ;
+// parameters → IDENTIFIER ( ":" type )? ( "," IDENTIFIER ( ":" type )? )* ;
 func (p *Parser) functionDeclaration(kind string) Stmt {
+	generator := p.match(lexer.STAR)
 	name := p.consume(lexer.IDENTIFIER, fmt.Sprintf("expect %s name.", kind))
 
 	p.consume(lexer.LEFT_PAREN, fmt.Sprintf("expect '(' after %s name.", kind))
 
 	params := []lexer.Token{}
+	paramTypes := []*TypeAnnotation{}
 
 	for {
 		if p.peek().TokenType == lexer.IDENTIFIER {
 			params = append(params, p.consume(lexer.IDENTIFIER, "expect parameter name."))
+			paramTypes = append(paramTypes, p.optionalType())
 		}
 
 		if !p.match(lexer.COMMA) {
@@ -69,21 +108,68 @@ func (p *Parser) functionDeclaration(kind string) Stmt {
 
 	p.consume(lexer.RIGHT_PAREN, "expect ')' after parameters")
 
+	returnType := p.optionalType()
+
 	p.consume(lexer.LEFT_BRACE, fmt.Sprintf("expect '{' before %s body.", kind))
 
+	enclosingSawYield := p.sawYield
+	p.sawYield = false
+	p.functionDepth++
 	body := p.block()
+	p.functionDepth--
+	generator = generator || p.sawYield
+	p.sawYield = enclosingSawYield
 
 	return &FunctionStmt{
-		Name:   name,
-		Params: params,
-		Body:   body,
+		Name:       name,
+		Generator:  generator,
+		Params:     params,
+		ParamTypes: paramTypes,
+		ReturnType: returnType,
+		Body:       body,
 	}
 
 }
 
-// varDecl → "var" IDENTIFIER ( "=" expression )? ";" ;
+// atTestDeclaration reports whether the next tokens start a test. `test` isn't a reserved word, so
+// programs can still use it as a name: only `test` followed by a string starts a test block. that is
+// never a valid expression, so a test inside a block is parsed too and reported as misplaced
+func (p *Parser) atTestDeclaration() bool {
+	return p.check(lexer.IDENTIFIER) && p.peek().Lexeme == "test" && p.at(p.Current+1).TokenType == lexer.STRING
+}
+
+// testDecl → "test" STRING block ;
+func (p *Parser) testDeclaration() Stmt {
+	keyword := p.previous()
+	name := p.advance()
+	p.consume(lexer.LEFT_BRACE, "expect '{' before test body.")
+	title, _ := name.Literal.(string)
+	// without a '{' there is no body to parse, declaration wraps the header in a BadStmt
+	if p.panicking {
+		return &TestStmt{Keyword: keyword, Name: title}
+	}
+
+	// a test body runs like a function's, so `return` ends the test early
+	p.functionDepth++
+	body := p.block()
+	p.functionDepth--
+
+	test := &TestStmt{Keyword: keyword, Name: title, Body: body}
+
+	// a misplaced test still parsed completely, so unlike the errors above there's nothing to skip past
+	if p.blockDepth > 0 {
+		p.handleError(keyword, "tests must be declared at the top level.")
+		p.panicking = false
+		return &BadStmt{From: keyword, To: p.previous(), Partial: test}
+	}
+
+	return test
+}
+
+// varDecl → "var" IDENTIFIER ( ":" type )? ( "=" expression )? ";" ;
 func (p *Parser) varDeclaration() Stmt {
 	name := p.consume(lexer.IDENTIFIER, "expect variable name.")
+	varType := p.optionalType()
 
 	var initializer Expr
 	if p.match(lexer.EQUAL) {
@@ -94,10 +180,28 @@ func (p *Parser) varDeclaration() Stmt {
 
 	return &VariableDeclarationStmt{
 		Name:        name,
+		Type:        varType,
 		Initializer: initializer,
 	}
 }
 
+// type → IDENTIFIER "?"? ;
+// `nil` is a keyword but also the name of a type, so it is accepted here too
+func (p *Parser) optionalType() *TypeAnnotation {
+	if !p.match(lexer.COLON) {
+		return nil
+	}
+
+	if !p.check(lexer.IDENTIFIER) && !p.check(lexer.NIL) {
+		p.handleError(p.peek(), "expect type name after ':'.")
+		return nil
+	}
+
+	annotation := &TypeAnnotation{Name: p.advance()}
+	annotation.Nullable = p.match(lexer.QUESTION)
+	return annotation
+}
+
 func (p *Parser) statement() Stmt {
 	if p.match(lexer.IF) {
 		return p.ifStatement()
@@ -115,8 +219,21 @@ func (p *Parser) statement() Stmt {
 		return p.forStatement()
 	}
 
+	if p.match(lexer.MATCH) {
+		return p.matchStatement()
+	}
+
+	if p.match(lexer.RETURN) {
+		return p.returnStatement()
+	}
+
+	if p.match(lexer.YIELD) {
+		return p.yieldStatement()
+	}
+
 	if p.match(lexer.LEFT_BRACE) {
 		return &BlockStmt{
+			Brace: p.previous(),
 			Stmts: p.block(),
 		}
 	}
@@ -124,18 +241,132 @@ func (p *Parser) statement() Stmt {
 	return p.expressionStatement()
 }
 
+// returnStmt → "return" expression? ";" ;
+func (p *Parser) returnStatement() Stmt {
+	keyword := p.previous()
+	if p.functionDepth == 0 {
+		p.handleError(keyword, "can't return from top-level code.")
+	}
+
+	var value Expr
+	if !p.check(lexer.SEMICOLON) {
+		value = p.expression()
+	}
+
+	p.consume(lexer.SEMICOLON, "expect ';' after return value.")
+	return &ReturnStmt{Keyword: keyword, Value: value}
+}
+
+// yieldStmt → "yield" expression? ";" ;
+func (p *Parser) yieldStatement() Stmt {
+	keyword := p.previous()
+	if p.functionDepth == 0 {
+		p.handleError(keyword, "can't yield from top-level code.")
+	}
+	p.sawYield = true
+
+	var value Expr
+	if !p.check(lexer.SEMICOLON) {
+		value = p.expression()
+	}
+
+	p.consume(lexer.SEMICOLON, "expect ';' after yield value.")
+	return &YieldStmt{Keyword: keyword, Value: value}
+}
+
+// matchStmt → "match" "(" expression ")" "{" matchCase* "}" ;
+// matchCase → "case" pattern ( "," pattern )* ( "if" expression )? "=>" statement ;
+func (p *Parser) matchStatement() Stmt {
+	keyword := p.previous()
+	p.consume(lexer.LEFT_PAREN, "expect '(' after 'match'.")
+	subject := p.expression()
+	p.consume(lexer.RIGHT_PAREN, "expect ')' after match subject.")
+	p.consume(lexer.LEFT_BRACE, "expect '{' before match cases.")
+
+	cases := []MatchCase{}
+	for !p.check(lexer.RIGHT_BRACE) && !p.isAtEnd() {
+		if !p.match(lexer.CASE) {
+			// without a `case` there is nothing to anchor on, so bail out rather than spin
+			p.handleError(p.peek(), "expect 'case' in match body.")
+			break
+		}
+		matchCase := MatchCase{Keyword: p.previous()}
+
+		for {
+			matchCase.Patterns = append(matchCase.Patterns, p.pattern())
+			if !p.match(lexer.COMMA) {
+				break
+			}
+		}
+
+		if p.match(lexer.IF) {
+			matchCase.Guard = p.expression()
+		}
+
+		p.consume(lexer.EQUAL_GREATER, "expect '=>' after case pattern.")
+		matchCase.Body = p.nestedStmt(p.statement)
+
+		if len(cases) > 0 && cases[len(cases)-1].Irrefutable() {
+			p.handleWarning(matchCase.Keyword, "unreachable case, a previous case matches every value. wildcard cases should come last.")
+		}
+		cases = append(cases, matchCase)
+	}
+
+	p.consume(lexer.RIGHT_BRACE, "expect '}' after match cases.")
+
+	return &MatchStmt{
+		Keyword: keyword,
+		Subject: subject,
+		Cases:   cases,
+	}
+}
+
+// pattern → NUMBER | "-" NUMBER | STRING | "true" | "false" | "nil" | IDENTIFIER | "_" ;
+func (p *Parser) pattern() MatchPattern {
+	if p.match(lexer.MINUS) {
+		minus := p.previous()
+		number := p.consume(lexer.NUMBER, "expect number after '-' in pattern.")
+		value, _ := number.Literal.(float64)
+		return MatchPattern{Kind: LiteralPattern, Token: minus, Literal: &LiteralExpr{Value: -value, Token: number}}
+	}
+
+	if p.match(lexer.NUMBER, lexer.STRING, lexer.TRUE, lexer.FALSE, lexer.NIL) {
+		token := p.previous()
+		literal := &LiteralExpr{Value: token.Literal, Token: token}
+		switch token.TokenType {
+		case lexer.TRUE, lexer.FALSE:
+			literal = &LiteralExpr{Value: token.TokenType == lexer.TRUE, IsBoolean: true, Token: token}
+		case lexer.NIL:
+			literal.IsNil = true
+		}
+		return MatchPattern{Kind: LiteralPattern, Token: token, Literal: literal}
+	}
+
+	if p.match(lexer.IDENTIFIER) {
+		if p.previous().Lexeme == "_" {
+			return MatchPattern{Kind: WildcardPattern, Token: p.previous()}
+		}
+		return MatchPattern{Kind: BindingPattern, Token: p.previous()}
+	}
+
+	p.handleError(p.peek(), "expect pattern.")
+	return MatchPattern{Kind: WildcardPattern, Token: p.peek()}
+}
+
 func (p *Parser) ifStatement() Stmt {
+	keyword := p.previous()
 	p.consume(lexer.LEFT_PAREN, "expect '(' after 'if'.")
 	condition := p.expression()
 	p.consume(lexer.RIGHT_PAREN, "expect ')' after if condition")
 
-	thenBranch := p.statement()
+	thenBranch := p.nestedStmt(p.statement)
 	var elseBranch Stmt
 	if p.match(lexer.ELSE) {
-		elseBranch = p.statement()
+		elseBranch = p.nestedStmt(p.statement)
 	}
 
 	return &IfStmt{
+		Keyword:    keyword,
 		Condition:  condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
@@ -143,29 +374,34 @@ func (p *Parser) ifStatement() Stmt {
 }
 
 func (p *Parser) printStatement() Stmt {
+	keyword := p.previous()
 	value := p.expression()
 
 	p.consume(lexer.SEMICOLON, "expect ';' after expression.")
 
 	return &PrintStmt{
-		Expr: value,
+		Keyword: keyword,
+		Expr:    value,
 	}
 }
 
 func (p *Parser) whileStatement() Stmt {
+	keyword := p.previous()
 	p.consume(lexer.LEFT_PAREN, "expected '(' after while")
 	condition := p.expression()
 	p.consume(lexer.RIGHT_PAREN, "expected ')' after while condition")
 
-	body := p.statement()
+	body := p.nestedStmt(p.statement)
 
 	return &WhileStmt{
+		Keyword:   keyword,
 		Condition: condition,
 		Body:      body,
 	}
 }
 
 func (p *Parser) forStatement() Stmt {
+	keyword := p.previous()
 	p.consume(lexer.LEFT_PAREN, "expect '(' after 'for'.")
 
 	// initializer
@@ -191,7 +427,7 @@ func (p *Parser) forStatement() Stmt {
 
 	p.consume(lexer.RIGHT_PAREN, "expect ')' after for clauses.")
 
-	body := p.statement()
+	body := p.nestedStmt(p.statement)
 
 	// if our loop contains an increment expression, then we append it to the original body so that it executes after the original body stmts
 	if increment != nil {
@@ -208,6 +444,12 @@ func (p *Parser) forStatement() Stmt {
 		}
 	}
 
+	body = &WhileStmt{
+		Keyword:   keyword,
+		Condition: condition,
+		Body:      body,
+	}
+
 	// if there is an initializer, it runs once before the entire loop
 	if initializer != nil {
 		body = &BlockStmt{
@@ -221,9 +463,11 @@ func (p *Parser) forStatement() Stmt {
 
 func (p *Parser) block() []Stmt {
 	stmts := []Stmt{}
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 
 	for !p.check(lexer.RIGHT_BRACE) && !p.isAtEnd() {
-		stmts = append(stmts, p.declaration())
+		stmts = append(stmts, p.nestedStmt(p.declaration))
 	}
 
 	p.consume(lexer.RIGHT_BRACE, "expect '}' after block.")
@@ -240,20 +484,28 @@ func (p *Parser) expressionStatement() Stmt {
 	}
 }
 
-// expression → assignment ;
+// expression → comma ;
 func (p *Parser) expression() Expr {
-	return p.assignment()
+	return p.comma()
 }
 
-// assignment → IDENTIFIER "=" assignment | equality ;
+// comma → assignment ( "," assignment )* ;
+//
+// the comma operator evaluates its operands left to right and yields the last one. it has the lowest
+// precedence, so anywhere a comma already separates things (call arguments) parses assignment instead.
+func (p *Parser) comma() Expr {
+	return p.binaryLevel(p.assignment, lexer.COMMA)
+}
+
+// assignment → IDENTIFIER ( "=" | "+=" | "-=" | "*=" | "/=" ) assignment | conditional ;
 func (p *Parser) assignment() Expr {
 	// expr holds the l-value of the assignment.
-	expr := p.or()
+	expr := p.conditional()
 
 	// after parsing the l-value, if an = operator exists, then pass the r-value of the assignment
 	if p.match(lexer.EQUAL) {
 		equalsTok := p.previous()
-		value := p.assignment()
+		value := p.nestedExpr(p.assignment)
 
 		// the only valid l-value type we accept right now is a variable. ie expressions like `a = "hello"; b = 1234;`
 		if variableExpr, ok := expr.(*VariableExpr); ok {
@@ -266,33 +518,71 @@ func (p *Parser) assignment() Expr {
 		} else {
 			p.handleError(equalsTok, "invalid assignment target")
 		}
+	} else if p.match(lexer.PLUS_EQUAL, lexer.MINUS_EQUAL, lexer.STAR_EQUAL, lexer.SLASH_EQUAL) {
+		operator := p.previous()
+		value := p.nestedExpr(p.assignment)
+
+		if variableExpr, ok := expr.(*VariableExpr); ok {
+			return &CompoundAssignExpr{
+				Name:     variableExpr.Name,
+				Operator: Operator(operator),
+				Value:    value,
+			}
+		} else {
+			p.handleError(operator, "invalid assignment target")
+		}
 	}
 
 	return expr
 }
 
-func (p *Parser) or() Expr {
-	expr := p.and()
-
-	for p.match(lexer.OR) {
-		operator := p.previous()
-		right := p.and()
-		expr = &LogicalExpr{
-			Left:     expr,
-			Operator: Operator(operator),
-			Right:    right,
+// conditional → coalesce ( "?" expression ":" conditional )? ;
+//
+// recursing on the else branch makes the operator right associative: `a ? b : c ? d : e` is `a ? b : (c ? d : e)`
+func (p *Parser) conditional() Expr {
+	expr := p.coalesce()
+
+	if p.match(lexer.QUESTION) {
+		thenBranch := p.nestedExpr(p.expression)
+		p.consume(lexer.COLON, "expect ':' after then branch of conditional expression.")
+		elseBranch := p.nestedExpr(p.conditional)
+
+		return &ConditionalExpr{
+			Condition:  expr,
+			ThenBranch: thenBranch,
+			ElseBranch: elseBranch,
 		}
 	}
 
 	return expr
 }
 
+// coalesce → or ( "??" or )* ;
+func (p *Parser) coalesce() Expr {
+	return p.logicalLevel(p.or, lexer.QUESTION_QUESTION)
+}
+
+// or → and ( "or" and )* ;
+func (p *Parser) or() Expr {
+	return p.logicalLevel(p.and, lexer.OR)
+}
+
+// and → equality ( "and" equality )* ;
 func (p *Parser) and() Expr {
-	expr := p.equality()
+	return p.logicalLevel(p.equality, lexer.AND)
+}
+
+// logicalLevel is binaryLevel for the operators that short circuit
+func (p *Parser) logicalLevel(next func() Expr, operator lexer.TokenType) Expr {
+	expr := next()
+	defer p.unchain(p.nesting)
 
-	for p.match(lexer.AND) {
+	for p.match(operator) {
 		operator := p.previous()
-		right := p.equality()
+		if !p.chain() {
+			return &BadExpr{From: operator, To: p.previous()}
+		}
+		right := next()
 		expr = &LogicalExpr{
 			Left:     expr,
 			Operator: Operator(operator),
@@ -305,30 +595,58 @@ func (p *Parser) and() Expr {
 
 // equality → comparison ( ( "!=" | "==" ) comparison )* ;
 func (p *Parser) equality() Expr {
-	expr := p.comparison()
+	return p.binaryLevel(p.comparison, lexer.BANG_EQUAL, lexer.EQUAL_EQUAL)
+}
 
-	for p.match(lexer.BANG_EQUAL, lexer.EQUAL_EQUAL) {
-		operator := p.previous()
-		right := p.comparison()
+// comparison → bitOr ( ( ">" | ">=" | "<" | "<=" ) bitOr )* ;
+func (p *Parser) comparison() Expr {
+	return p.binaryLevel(p.bitOr, lexer.GREATER, lexer.GREATER_EQUAL, lexer.LESS, lexer.LESS_EQUAL)
+}
 
-		expr = &BinaryExpr{
-			LeftExpr:  expr,
-			RightExpr: right,
-			Operator:  Operator(operator),
-		}
-	}
+// the bitwise operators sit between comparison and term, so `a & 1 == 0` groups as `(a & 1) == 0` and
+// `1 << n + 1` groups as `1 << (n + 1)`. from loosest to tightest:
+//
+//	|           bitOr
+//	^           bitXor
+//	&           bitAnd
+//	<< >>       shift
+//	+ -         term
+//	* / % ~/    factor
+//	! - ~ ++ -- unary
+//	**          power, right associative
+//	++ --       postfix
+//
+// bitOr → bitXor ( "|" bitXor )* ;
+func (p *Parser) bitOr() Expr {
+	return p.binaryLevel(p.bitXor, lexer.PIPE)
+}
 
-	return expr
+// bitXor → bitAnd ( "^" bitAnd )* ;
+func (p *Parser) bitXor() Expr {
+	return p.binaryLevel(p.bitAnd, lexer.CARET)
 }
 
-// comparison → term ( ( ">" | ">=" | "<" | "<=" ) term )* ;
-func (p *Parser) comparison() Expr {
-	expr := p.term()
+// bitAnd → shift ( "&" shift )* ;
+func (p *Parser) bitAnd() Expr {
+	return p.binaryLevel(p.shift, lexer.AMPERSAND)
+}
 
-	for p.match(lexer.GREATER, lexer.GREATER_EQUAL, lexer.LESS, lexer.LESS_EQUAL) {
-		operator := p.previous()
-		right := p.term()
+// shift → term ( ( "<<" | ">>" ) term )* ;
+func (p *Parser) shift() Expr {
+	return p.binaryLevel(p.term, lexer.LESS_LESS, lexer.GREATER_GREATER)
+}
 
+// binaryLevel parses a left associative precedence level whose operands are parsed by next
+func (p *Parser) binaryLevel(next func() Expr, operators ...lexer.TokenType) Expr {
+	expr := next()
+	defer p.unchain(p.nesting)
+
+	for p.match(operators...) {
+		operator := p.previous()
+		if !p.chain() {
+			return &BadExpr{From: operator, To: p.previous()}
+		}
+		right := next()
 		expr = &BinaryExpr{
 			LeftExpr:  expr,
 			Operator:  Operator(operator),
@@ -341,28 +659,65 @@ func (p *Parser) comparison() Expr {
 
 // term → factor ( ( "-" | "+" ) factor )* ;
 func (p *Parser) term() Expr {
-	expr := p.factor()
+	return p.binaryLevel(p.factor, lexer.MINUS, lexer.PLUS)
+}
 
-	for p.match(lexer.MINUS, lexer.PLUS) {
+// factor → unary ( ( "/" | "*" | "%" | "~/" ) unary )* ;
+func (p *Parser) factor() Expr {
+	return p.binaryLevel(p.unary, lexer.SLASH, lexer.STAR, lexer.PERCENT, lexer.TILDE_SLASH)
+}
+
+// unary → ( "!" | "-" | "~" ) unary | ( "++" | "--" ) IDENTIFIER | "spawn" call | power ;
+func (p *Parser) unary() Expr {
+	if p.match(lexer.SPAWN) {
+		keyword := p.previous()
+		callee := p.call()
+
+		if call, ok := callee.(*CallExpr); ok && !call.Optional {
+			return &SpawnExpr{Keyword: keyword, Call: call}
+		}
+		p.handleError(keyword, "expect a function call after 'spawn'.")
+		return callee
+	}
+
+	if p.match(lexer.PLUS_PLUS, lexer.MINUS_MINUS) {
 		operator := p.previous()
-		right := p.factor()
-		expr = &BinaryExpr{
-			LeftExpr:  expr,
-			Operator:  Operator(operator),
-			RightExpr: right,
+		operand := p.nestedExpr(p.unary)
+
+		if variableExpr, ok := operand.(*VariableExpr); ok {
+			return &IncrementExpr{
+				Name:     variableExpr.Name,
+				Operator: Operator(operator),
+				Prefix:   true,
+			}
 		}
+		p.handleError(operator, "invalid increment target")
+		return operand
 	}
-	return expr
+
+	if p.match(lexer.BANG, lexer.MINUS, lexer.TILDE) {
+		operator := p.previous()
+		right := p.nestedExpr(p.unary)
+		return &UnaryExpr{
+			Operator: Operator(operator),
+			Expr:     right,
+		}
+	}
+
+	return p.power()
 }
 
-// factor → unary ( ( "/" | "*" ) unary )* ;
-func (p *Parser) factor() Expr {
-	expr := p.unary()
+// power → postfix ( "**" unary )? ;
+//
+// the right operand recurses through unary, which makes `**` right associative (`2 ** 3 ** 2` is `2 ** 9`)
+// and allows `2 ** -1`. the left operand is a postfix expression, so `-2 ** 2` is `-(2 ** 2)`.
+func (p *Parser) power() Expr {
+	expr := p.postfix()
 
-	for p.match(lexer.SLASH, lexer.STAR) {
+	if p.match(lexer.STAR_STAR) {
 		operator := p.previous()
-		right := p.unary()
-		expr = &BinaryExpr{
+		right := p.nestedExpr(p.unary)
+		return &BinaryExpr{
 			LeftExpr:  expr,
 			Operator:  Operator(operator),
 			RightExpr: right,
@@ -372,40 +727,91 @@ func (p *Parser) factor() Expr {
 	return expr
 }
 
-// unary → ( "!" | "-" ) unary | primary ;
-func (p *Parser) unary() Expr {
-	if p.match(lexer.BANG, lexer.MINUS) {
+// postfix → call ( "++" | "--" )? ;
+func (p *Parser) postfix() Expr {
+	expr := p.call()
+
+	if p.match(lexer.PLUS_PLUS, lexer.MINUS_MINUS) {
 		operator := p.previous()
-		right := p.unary()
-		return &UnaryExpr{
-			Operator: Operator(operator),
-			Expr:     right,
+
+		if variableExpr, ok := expr.(*VariableExpr); ok {
+			return &IncrementExpr{
+				Name:     variableExpr.Name,
+				Operator: Operator(operator),
+			}
 		}
+		p.handleError(operator, "invalid increment target")
 	}
 
-	return p.call()
+	return expr
 }
 
+// call → primary ( "(" arguments? ")" | "." IDENTIFIER | "?." IDENTIFIER | "?." "(" arguments? ")" )* ;
+//
+// optional calls are spelled `f?.(x)` rather than `f?(x)`, which would be ambiguous with `f ? (x) : y`
 func (p *Parser) call() Expr {
 	expr := p.primary()
+	optional := false
+	defer p.unchain(p.nesting)
 
 	for {
+		if from := p.peek(); p.check(lexer.LEFT_PAREN) || p.check(lexer.DOT) || p.check(lexer.QUESTION_DOT) {
+			if !p.chain() {
+				return &BadExpr{From: from, To: p.previous()}
+			}
+		}
+
 		if p.match(lexer.LEFT_PAREN) {
-			expr = p.finishCall(expr)
+			expr = p.finishCall(expr, false)
+		} else if p.match(lexer.DOT) {
+			name := p.propertyName("expect property name after '.'.")
+			expr = &GetExpr{
+				Object: expr,
+				Name:   name,
+			}
+		} else if p.match(lexer.QUESTION_DOT) {
+			optional = true
+			if p.match(lexer.LEFT_PAREN) {
+				expr = p.finishCall(expr, true)
+			} else {
+				name := p.propertyName("expect property name after '?.'.")
+				expr = &GetExpr{
+					Object:   expr,
+					Name:     name,
+					Optional: true,
+				}
+			}
 		} else {
 			break
 		}
 	}
 
+	// mark where a short circuited chain ends
+	if optional {
+		return &OptionalChainExpr{
+			Expr: expr,
+		}
+	}
+
 	return expr
 }
 
-func (p *Parser) finishCall(callee Expr) Expr {
+// propertyName consumes the name after a `.`. reserved words are allowed there, so `pattern.match(str)` works
+// even though `match` starts a statement
+func (p *Parser) propertyName(message string) lexer.Token {
+	if p.peek().TokenType.IsKeyword() {
+		return p.advance()
+	}
+	return p.consume(lexer.IDENTIFIER, message)
+}
+
+func (p *Parser) finishCall(callee Expr, optional bool) Expr {
 	args := []Expr{}
 
 	if !p.check(lexer.RIGHT_PAREN) {
 		for {
-			args = append(args, p.expression())
+			// arguments are parsed below the comma operator so `f(a, b)` passes two arguments
+			args = append(args, p.nestedExpr(p.assignment))
 
 			if !p.match(lexer.COMMA) {
 				break
@@ -419,6 +825,7 @@ func (p *Parser) finishCall(callee Expr) Expr {
 		Callee:    callee,
 		Paren:     paren,
 		Arguments: args,
+		Optional:  optional,
 	}
 }
 
@@ -427,6 +834,7 @@ func (p *Parser) primary() Expr {
 	if p.match(lexer.NUMBER, lexer.STRING) {
 		return &LiteralExpr{
 			Value: p.previous().Literal,
+			Token: p.previous(),
 		}
 	}
 
@@ -435,6 +843,7 @@ func (p *Parser) primary() Expr {
 		return &LiteralExpr{
 			Value:     true,
 			IsBoolean: true,
+			Token:     p.previous(),
 		}
 	}
 
@@ -442,6 +851,7 @@ func (p *Parser) primary() Expr {
 		return &LiteralExpr{
 			Value:     false,
 			IsBoolean: true,
+			Token:     p.previous(),
 		}
 	}
 
@@ -449,11 +859,12 @@ func (p *Parser) primary() Expr {
 		return &LiteralExpr{
 			Value: p.previous().Literal,
 			IsNil: true,
+			Token: p.previous(),
 		}
 	}
 
 	if p.match(lexer.LEFT_PAREN) {
-		expr := p.expression()
+		expr := p.nestedExpr(p.expression)
 		p.consume(lexer.RIGHT_PAREN, "Expect '(' after expression)")
 		return &GroupingExpr{
 			Expr: expr,
@@ -466,10 +877,10 @@ func (p *Parser) primary() Expr {
 		}
 	}
 
-	// if we reach here, throw a syntax error
+	// if we reach here, throw a syntax error. the offending token is left for the caller to recover from
 	p.handleError(p.peek(), "Expect expression.")
 
-	return nil
+	return &BadExpr{From: p.peek(), To: p.peek()}
 }
 
 // --------------------- parser machinery
@@ -488,8 +899,9 @@ func (p *Parser) consume(tokenType lexer.TokenType, message string) lexer.Token
 	if p.check(tokenType) {
 		return p.advance()
 	} else {
+		// stand in a token of the expected type, so there's still a line to report things at
 		p.handleError(p.peek(), message)
-		return lexer.Token{}
+		return lexer.Token{TokenType: tokenType, Line: p.peek().Line}
 	}
 }
 
@@ -497,18 +909,30 @@ var (
 	ErrParse = errors.New("parse error")
 )
 
+// handleError reports an error and puts the parser in panic mode. the errors that follow one are usually
+// caused by it, so nothing more is reported until the declaration it is in recovers
 func (p *Parser) handleError(token lexer.Token, message string) error {
-	if token.TokenType == lexer.EOF {
+	if p.panicking {
+		return ErrParse
+	}
 
-		msg := formatErrorMessage(token.Line, " at end", message)
-		p.Errors = append(p.Errors, msg)
-	} else {
-		msg := formatErrorMessage(token.Line, fmt.Sprintf("at %s", token.Lexeme), message)
-		p.Errors = append(p.Errors, msg)
+	diagnostic := Diagnostic{Line: token.Line, Where: fmt.Sprintf("at %s", token.Lexeme), Message: message}
+	if token.TokenType == lexer.EOF {
+		diagnostic.Where = "at end"
 	}
+	p.Diagnostics = append(p.Diagnostics, diagnostic)
+	p.Errors = append(p.Errors, diagnostic.String())
+	p.panicking = true
 	return ErrParse
 }
 
+// warnings flag suspicious code that still parses
+func (p *Parser) handleWarning(token lexer.Token, message string) {
+	diagnostic := Diagnostic{Line: token.Line, Where: fmt.Sprintf("at %s", token.Lexeme), Message: message, Warning: true}
+	p.Diagnostics = append(p.Diagnostics, diagnostic)
+	p.Warnings = append(p.Warnings, diagnostic.String())
+}
+
 func formatErrorMessage(line int, where string, message string) string {
 	return fmt.Sprintf("[line %d] Error %s: %s", line, where, message)
 }
@@ -528,14 +952,109 @@ func (p *Parser) synchronize() {
 			lexer.IF,
 			lexer.WHILE,
 			lexer.PRINT,
-			lexer.RETURN:
+			lexer.RETURN,
+			lexer.MATCH,
+			lexer.YIELD:
 			return
+		case lexer.IDENTIFIER:
+			if p.atTestDeclaration() {
+				return
+			}
+		// the end of the enclosing block, which block() consumes
+		case lexer.RIGHT_BRACE:
+			if p.blockDepth > 0 {
+				return
+			}
 		}
 
 		p.advance()
 	}
 }
 
+// maxNesting bounds how deeply expressions and statements can nest. parsing, and every visitor after it,
+// recurses once per level, and running out of Go stack is a fatal error rather than a panic. operator
+// chains count too, so it is also how long one like `a + b + c` can get
+const maxNesting = 1024
+
+// chain counts one more operator of a left associative chain like `a + b + c` or `a.b.c` toward
+// maxNesting, until unchain puts the count back once the chain ends. each one nests the chain so far a
+// level deeper, just as parentheses would. past maxNesting the rest of the chain is reported and skipped
+func (p *Parser) chain() bool {
+	if p.nesting >= maxNesting {
+		p.handleError(p.peek(), "expression is nested too deeply.")
+		p.skipNested(false)
+		return false
+	}
+
+	p.nesting++
+	return true
+}
+
+func (p *Parser) unchain(nesting int) {
+	p.nesting = nesting
+}
+
+// nestedExpr parses an expression one level deeper than the current one, like the inside of parentheses
+// or the operand of `-`. past maxNesting the expression is reported, skipped and parsed as a BadExpr
+func (p *Parser) nestedExpr(parse func() Expr) Expr {
+	if p.nesting >= maxNesting {
+		from := p.peek()
+		p.handleError(from, "expression is nested too deeply.")
+		p.skipNested(false)
+		return &BadExpr{From: from, To: p.previous()}
+	}
+
+	p.nesting++
+	defer func() { p.nesting-- }()
+	return parse()
+}
+
+// nestedStmt is nestedExpr for statements, like the body of an `if` or a block. the statement that is
+// too deep is skipped completely, so the statements around it parse as if it were fine
+func (p *Parser) nestedStmt(parse func() Stmt) Stmt {
+	if p.nesting >= maxNesting {
+		from := p.peek()
+		p.handleError(from, "statement is nested too deeply.")
+		p.skipNested(true)
+		p.panicking = false
+		return &BadStmt{From: from, To: p.previous()}
+	}
+
+	p.nesting++
+	defer func() { p.nesting-- }()
+	return parse()
+}
+
+// skipNested skips the code that was too deeply nested to parse. it stops at the first bracket it didn't
+// see opened, which belongs to a level that is still being parsed, or at a `;` outside any brackets.
+// a statement also takes that `;` or the `}` ending its block with it
+func (p *Parser) skipNested(statement bool) {
+	depth := 0
+	for !p.isAtEnd() {
+		switch p.peek().TokenType {
+		case lexer.LEFT_PAREN, lexer.LEFT_BRACE:
+			depth++
+		case lexer.RIGHT_PAREN, lexer.RIGHT_BRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 && statement && p.peek().TokenType == lexer.RIGHT_BRACE {
+				p.advance()
+				return
+			}
+		case lexer.SEMICOLON:
+			if depth == 0 {
+				if statement {
+					p.advance()
+				}
+				return
+			}
+		}
+		p.advance()
+	}
+}
+
 func (p *Parser) check(tokenType lexer.TokenType) bool {
 	if p.isAtEnd() {
 		return false
@@ -556,9 +1075,23 @@ func (p *Parser) isAtEnd() bool {
 }
 
 func (p *Parser) peek() lexer.Token {
-	return p.Tokens[p.Current]
+	return p.at(p.Current)
 }
 
 func (p *Parser) previous() lexer.Token {
-	return p.Tokens[p.Current-1]
+	return p.at(p.Current - 1)
+}
+
+// at is the token at idx. tokens that didn't come from the scanner may be empty or lack the EOF
+// marker, so anything out of range reads as an EOF on the last line
+func (p *Parser) at(idx int) lexer.Token {
+	if idx >= 0 && idx < len(p.Tokens) {
+		return p.Tokens[idx]
+	}
+
+	line := 1
+	if len(p.Tokens) > 0 {
+		line = p.Tokens[len(p.Tokens)-1].Line
+	}
+	return lexer.Token{TokenType: lexer.EOF, Line: line}
 }
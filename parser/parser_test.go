@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/brandonshearin/go-lox/lexer"
@@ -163,6 +164,42 @@ var testCases = []TestCase{
 		Source:                 "5 * 2 - 6 > false != true",
 		ExpectedRepresentation: "(!= (> (- (* 5.00 2.00) 6.00) false) true)",
 	},
+	// modulo and integer division share a level with * and /
+	{
+		ID:                     10,
+		Source:                 "1 + 7 % 3 ~/ 2 * 4",
+		ExpectedRepresentation: "(+ 1.00 (* (~/ (% 7.00 3.00) 2.00) 4.00))",
+	},
+	// exponentiation is right associative
+	{
+		ID:                     11,
+		Source:                 "2 ** 3 ** 2",
+		ExpectedRepresentation: "(** 2.00 (** 3.00 2.00))",
+	},
+	// exponentiation binds tighter than unary minus on its left, but accepts it on its right
+	{
+		ID:                     12,
+		Source:                 "-2 ** -1",
+		ExpectedRepresentation: "(- (** 2.00 (- 1.00)))",
+	},
+	// exponentiation > factor exprs
+	{
+		ID:                     13,
+		Source:                 "2 * 3 ** 2",
+		ExpectedRepresentation: "(* 2.00 (** 3.00 2.00))",
+	},
+	// term exprs > shift exprs > & > ^ > | > comparison exprs
+	{
+		ID:                     14,
+		Source:                 "1 | 2 ^ 3 & 4 << 5 + 6 > 7",
+		ExpectedRepresentation: "(> (| 1.00 (^ 2.00 (& 3.00 (<< 4.00 (+ 5.00 6.00))))) 7.00)",
+	},
+	// bitwise exprs are left associative and bind tighter than equality
+	{
+		ID:                     15,
+		Source:                 "a & 1 & 2 == ~b >> 1",
+		ExpectedRepresentation: "(== (& (& a 1.00) 2.00) (>> (~ b) 1.00))",
+	},
 }
 
 func TestPrecedence(t *testing.T) {
@@ -383,7 +420,7 @@ func TestFunctionDecl(t *testing.T) {
 	_ = p.declaration()
 
 	assert.NotEmpty(t, p.Errors)
-	assert.Len(t, p.Errors, 3)
+	assert.Len(t, p.Errors, 1)
 
 	// error case: no identifier, no parens
 	source = "fun "
@@ -392,7 +429,7 @@ func TestFunctionDecl(t *testing.T) {
 	_ = p.declaration()
 
 	assert.NotEmpty(t, p.Errors)
-	assert.Len(t, p.Errors, 5)
+	assert.Len(t, p.Errors, 1)
 
 }
 
@@ -408,7 +445,9 @@ func TestSynchronize(t *testing.T) {
 	assert.Len(t, p.Errors, 3)
 
 	for _, stmt := range stmts {
-		assert.IsType(t, &VariableDeclarationStmt{}, stmt, "stmt should be a variable declaration with an empty token for the identifier")
+		if assert.IsType(t, &BadStmt{}, stmt) {
+			assert.IsType(t, &VariableDeclarationStmt{}, stmt.(*BadStmt).Partial, "stmt should be a variable declaration with an empty token for the identifier")
+		}
 	}
 
 }
@@ -425,3 +464,465 @@ func TestAssignExpr(t *testing.T) {
 	assert.IsType(t, &AssignExpr{}, ast.(*ExpressionStmt).Expr)
 	assert.Equal(t, "a", ast.(*ExpressionStmt).Expr.(*AssignExpr).Name.Lexeme)
 }
+
+func TestGetExpr(t *testing.T) {
+	source := "a.b(1).c"
+	tokens := lexer.NewScanner(source).ScanTokens()
+
+	p := NewParser(tokens)
+	exprAST := p.expression()
+
+	assert.Empty(t, p.Errors)
+	assert.IsType(t, &GetExpr{}, exprAST)
+	assert.Equal(t, "c", exprAST.(*GetExpr).Name.Lexeme)
+
+	call := exprAST.(*GetExpr).Object.(*CallExpr)
+	assert.Len(t, call.Arguments, 1)
+	assert.Equal(t, "b", call.Callee.(*GetExpr).Name.Lexeme)
+
+	// property names must be identifiers
+	p = NewParser(lexer.NewScanner("a.1").ScanTokens())
+	_ = p.expression()
+
+	assert.Len(t, p.Errors, 1)
+}
+
+func TestCompoundAssignExpr(t *testing.T) {
+	cases := []struct {
+		Source   string
+		Expected string
+	}{
+		{"a += 1", "(+= a 1.00)"},
+		{"a -= b *= 2", "(-= a (*= b 2.00))"},
+		{"a /= 2 + 3", "(/= a (+ 2.00 3.00))"},
+		{"++a", "(++ a)"},
+		{"--a", "(-- a)"},
+		{"a++", "(post++ a)"},
+		{"-a--", "(- (post-- a))"},
+		{"a++ ** 2", "(** (post++ a) 2.00)"},
+	}
+
+	for _, testCase := range cases {
+		p := NewParser(lexer.NewScanner(testCase.Source).ScanTokens())
+		exprAST := p.expression()
+
+		assert.Empty(t, p.Errors, testCase.Source)
+		printer := ASTPrinter{}
+		assert.Equal(t, testCase.Expected, printer.Print(exprAST), testCase.Source)
+	}
+
+	// only variables can be updated
+	for _, source := range []string{"1 += 2", "(a) -= 1", "++1", "a()--"} {
+		p := NewParser(lexer.NewScanner(source).ScanTokens())
+		_ = p.expression()
+
+		assert.Len(t, p.Errors, 1, source)
+	}
+}
+
+func TestConditionalAndCommaExpr(t *testing.T) {
+	cases := []struct {
+		Source   string
+		Expected string
+	}{
+		{"a ? b : c", "(?: a b c)"},
+		// right associative
+		{"a ? b : c ? d : e", "(?: a b (?: c d e))"},
+		{"a ? b ? c : d : e", "(?: a (?: b c d) e)"},
+		// binds looser than `or` and tighter than assignment
+		{"a or b ? c and d : e", "(?: (or a b) (and c d) e)"},
+		{"x = a ? b : c", "(= x (?: a b c))"},
+		// comma has the lowest precedence and is left associative
+		{"a, b, c", "(, (, a b) c)"},
+		{"a ? b, c : d", "(?: a (, b c) d)"},
+		{"(a, b) + 1", "(+ (group (, a b)) 1.00)"},
+	}
+
+	for _, testCase := range cases {
+		p := NewParser(lexer.NewScanner(testCase.Source).ScanTokens())
+		exprAST := p.expression()
+
+		assert.Empty(t, p.Errors, testCase.Source)
+		printer := ASTPrinter{}
+		assert.Equal(t, testCase.Expected, printer.Print(exprAST), testCase.Source)
+	}
+
+	// the comma operator doesn't swallow call arguments
+	p := NewParser(lexer.NewScanner("f(a, (b, c), d ? e : g)").ScanTokens())
+	exprAST := p.expression()
+
+	assert.Empty(t, p.Errors)
+	assert.Len(t, exprAST.(*CallExpr).Arguments, 3)
+
+	// a missing ':' is a syntax error
+	p = NewParser(lexer.NewScanner("a ? b").ScanTokens())
+	_ = p.expression()
+
+	if assert.NotEmpty(t, p.Errors) {
+		assert.Contains(t, p.Errors[0], "expect ':' after then branch of conditional expression.")
+	}
+}
+
+func TestNilCoalescingAndOptionalChaining(t *testing.T) {
+	cases := []struct {
+		Source   string
+		Expected string
+	}{
+		{"a ?? b ?? c", "(?? (?? a b) c)"},
+		// `??` binds looser than `or` and tighter than the conditional operator
+		{"a or b ?? c ? d : e", "(?: (?? (or a b) c) d e)"},
+		{"a?.b", "(?.b a)"},
+		{"a?.b.c(1)", "(call (.c (?.b a)) 1.00)"},
+		{"f?.(1, 2)", "(?.call f 1.00 2.00)"},
+	}
+
+	for _, testCase := range cases {
+		p := NewParser(lexer.NewScanner(testCase.Source).ScanTokens())
+		exprAST := p.expression()
+
+		assert.Empty(t, p.Errors, testCase.Source)
+		printer := ASTPrinter{}
+		assert.Equal(t, testCase.Expected, printer.Print(exprAST), testCase.Source)
+	}
+
+	// the whole chain is wrapped so evaluation knows where to stop short circuiting
+	p := NewParser(lexer.NewScanner("a?.b.c").ScanTokens())
+	exprAST := p.expression()
+
+	assert.IsType(t, &OptionalChainExpr{}, exprAST)
+	assert.True(t, exprAST.(*OptionalChainExpr).Expr.(*GetExpr).Object.(*GetExpr).Optional)
+
+	// chains without `?.` are left alone
+	p = NewParser(lexer.NewScanner("a.b.c").ScanTokens())
+	exprAST = p.expression()
+
+	assert.IsType(t, &GetExpr{}, exprAST)
+}
+
+func TestMatchStmt(t *testing.T) {
+	source := `match (x) {
+		case 1, -2, "x", true, nil => print "literal";
+		case n if n > 10 => { print n; }
+		case _ => print "other";
+	}`
+	p := NewParser(lexer.NewScanner(source).ScanTokens())
+	stmts := p.Parse()
+
+	assert.Empty(t, p.Errors)
+	assert.Empty(t, p.Warnings)
+	assert.Len(t, stmts, 1)
+	assert.IsType(t, &MatchStmt{}, stmts[0])
+
+	matchStmt := stmts[0].(*MatchStmt)
+	assert.IsType(t, &VariableExpr{}, matchStmt.Subject)
+	assert.Len(t, matchStmt.Cases, 3)
+
+	literals := matchStmt.Cases[0].Patterns
+	assert.Len(t, literals, 5)
+	assert.Equal(t, float64(1), literals[0].Literal.Value)
+	assert.Equal(t, float64(-2), literals[1].Literal.Value)
+	assert.Equal(t, "x", literals[2].Literal.Value)
+	assert.Equal(t, true, literals[3].Literal.Value)
+	assert.True(t, literals[4].Literal.IsNil)
+
+	assert.Equal(t, BindingPattern, matchStmt.Cases[1].Patterns[0].Kind)
+	assert.Equal(t, "n", matchStmt.Cases[1].Patterns[0].Token.Lexeme)
+	assert.IsType(t, &BinaryExpr{}, matchStmt.Cases[1].Guard)
+	assert.IsType(t, &BlockStmt{}, matchStmt.Cases[1].Body)
+
+	assert.Equal(t, WildcardPattern, matchStmt.Cases[2].Patterns[0].Kind)
+}
+
+func TestMatchStmtWarnings(t *testing.T) {
+	// a wildcard that isn't last makes the cases after it unreachable
+	source := `match (x) { case _ => print 1; case 2 => print 2; }`
+	p := NewParser(lexer.NewScanner(source).ScanTokens())
+	_ = p.Parse()
+
+	assert.Empty(t, p.Errors)
+	if assert.Len(t, p.Warnings, 1) {
+		assert.Contains(t, p.Warnings[0], "unreachable case")
+	}
+
+	// so does an unguarded binding
+	source = `match (x) { case n => print n; case 2 => print 2; }`
+	p = NewParser(lexer.NewScanner(source).ScanTokens())
+	_ = p.Parse()
+
+	assert.Len(t, p.Warnings, 1)
+
+	// but a guarded one doesn't
+	source = `match (x) { case n if n > 1 => print n; case _ => print 2; }`
+	p = NewParser(lexer.NewScanner(source).ScanTokens())
+	_ = p.Parse()
+
+	assert.Empty(t, p.Warnings)
+}
+
+func TestMatchStmtErrors(t *testing.T) {
+	for _, source := range []string{
+		"match (x) { print 1; }",
+		"match (x) { case => print 1; }",
+		"match (x) { case 1 print 1; }",
+		"match x { case 1 => print 1; }",
+	} {
+		p := NewParser(lexer.NewScanner(source).ScanTokens())
+		_ = p.Parse()
+
+		assert.NotEmpty(t, p.Errors, source)
+	}
+}
+
+func TestKeywordPropertyNames(t *testing.T) {
+	p := NewParser(lexer.NewScanner("pattern.match(s)").ScanTokens())
+	exprAST := p.expression()
+
+	assert.Empty(t, p.Errors)
+	assert.Equal(t, "match", exprAST.(*CallExpr).Callee.(*GetExpr).Name.Lexeme)
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	ast := getStmtFromSource("var count: number = 1;")
+	varDecl := ast.(*VariableDeclarationStmt)
+
+	assert.Equal(t, "number", varDecl.Type.String())
+	assert.IsType(t, &LiteralExpr{}, varDecl.Initializer)
+
+	// unannotated declarations have no type
+	ast = getStmtFromSource("var count = 1;")
+	assert.Nil(t, ast.(*VariableDeclarationStmt).Type)
+
+	source := "fun add(a: number, b, c: nil): string? { return a; }"
+	p := NewParser(lexer.NewScanner(source).ScanTokens())
+	ast = p.declaration()
+
+	assert.Empty(t, p.Errors)
+	functionDecl := ast.(*FunctionStmt)
+	if assert.Len(t, functionDecl.ParamTypes, 3) {
+		assert.Equal(t, "number", functionDecl.ParamTypes[0].String())
+		assert.Nil(t, functionDecl.ParamTypes[1])
+		assert.Equal(t, "nil", functionDecl.ParamTypes[2].String())
+	}
+	assert.Equal(t, "string?", functionDecl.ReturnType.String())
+	assert.IsType(t, &ReturnStmt{}, functionDecl.Body[0])
+
+	p = NewParser(lexer.NewScanner("var x: = 1;").ScanTokens())
+	_ = p.Parse()
+	assert.Len(t, p.Errors, 1)
+}
+
+func TestReturnOutsideFunction(t *testing.T) {
+	p := NewParser(lexer.NewScanner("return 1;").ScanTokens())
+	_ = p.Parse()
+
+	if assert.Len(t, p.Errors, 1) {
+		assert.Contains(t, p.Errors[0], "can't return from top-level code.")
+	}
+}
+
+func TestGeneratorDecl(t *testing.T) {
+	source := "fun* g() {} fun h() { fun inner() { yield 1; } } fun k() { if (true) yield; }"
+	p := NewParser(lexer.NewScanner(source).ScanTokens())
+	stmts := p.Parse()
+
+	assert.Empty(t, p.Errors)
+	assert.True(t, stmts[0].(*FunctionStmt).Generator)
+	// a yield only makes its own function a generator
+	assert.False(t, stmts[1].(*FunctionStmt).Generator)
+	assert.True(t, stmts[1].(*FunctionStmt).Body[0].(*FunctionStmt).Generator)
+	assert.True(t, stmts[2].(*FunctionStmt).Generator)
+
+	p = NewParser(lexer.NewScanner("yield 1;").ScanTokens())
+	_ = p.Parse()
+	if assert.Len(t, p.Errors, 1) {
+		assert.Contains(t, p.Errors[0], "can't yield from top-level code.")
+	}
+}
+
+func TestSpawnExpr(t *testing.T) {
+	p := NewParser(lexer.NewScanner("var t = spawn worker(1, 2);").ScanTokens())
+	stmts := p.Parse()
+
+	assert.Empty(t, p.Errors)
+	spawn := stmts[0].(*VariableDeclarationStmt).Initializer.(*SpawnExpr)
+	assert.Equal(t, "(spawn (call worker 1.00 2.00))", (&ASTPrinter{}).Print(spawn))
+
+	for _, source := range []string{"spawn worker;", "spawn f?.();"} {
+		p = NewParser(lexer.NewScanner(source).ScanTokens())
+		_ = p.Parse()
+		if assert.Len(t, p.Errors, 1, source) {
+			assert.Contains(t, p.Errors[0], "expect a function call after 'spawn'.", source)
+		}
+	}
+}
+
+func TestPrintProgram(t *testing.T) {
+	source := "var a = 1; fun f(x: number) { if (x) print x; else { return; } } match (a) { case 1, n if n => print 1; }"
+	p := NewParser(lexer.NewScanner(source).ScanTokens())
+
+	expected := `(var a 1.00)
+(fun f (x: number)
+  (if x
+    (print x)
+    (block
+      (return))))
+(match a
+  (case 1.00 n (if n)
+    (print 1.00)))
+`
+	assert.Equal(t, expected, (&ASTPrinter{}).PrintProgram(p.Parse()))
+}
+
+func TestTestStmt(t *testing.T) {
+	p := NewParser(lexer.NewScanner(`test "adds numbers" { assertEqual(1 + 2, 3); return; }`).ScanTokens())
+	stmts := p.Parse()
+
+	assert.Empty(t, p.Errors)
+	test := stmts[0].(*TestStmt)
+	assert.Equal(t, "adds numbers", test.Name)
+	assert.Equal(t, "(test \"adds numbers\"\n  (expr (call assertEqual (+ 1.00 2.00) 3.00))\n  (return))\n", (&ASTPrinter{}).PrintProgram(stmts))
+
+	cases := map[string]string{
+		`fun f() { test "nested" {} }`: "tests must be declared at the top level.",
+		`test named {}`:                "expect ';' after expression.",
+		`test "no body";`:              "expect '{' before test body.",
+	}
+	for source, message := range cases {
+		p := NewParser(lexer.NewScanner(source).ScanTokens())
+		_ = p.Parse()
+		if assert.NotEmpty(t, p.Errors, source) {
+			assert.Contains(t, p.Errors[0], message, source)
+		}
+	}
+
+	// test is only a keyword in front of a string, elsewhere it is an ordinary name
+	p = NewParser(lexer.NewScanner(`var test = 1; fun check(test) { return test; } test = check(test); print test;`).ScanTokens())
+	stmts = p.Parse()
+	assert.Empty(t, p.Errors)
+	assert.Equal(t, "(var test 1.00)\n(fun check (test)\n  (return test))\n(expr (= test (call check test)))\n(print test)\n", (&ASTPrinter{}).PrintProgram(stmts))
+}
+
+func TestBadNodes(t *testing.T) {
+	p := NewParser(lexer.NewScanner("print 1 +;\nvar a = 2;").ScanTokens())
+	stmts := p.Parse()
+
+	if assert.Len(t, stmts, 2) {
+		bad := stmts[0].(*BadStmt)
+		assert.Equal(t, lexer.PRINT, bad.From.TokenType)
+		assert.Equal(t, lexer.SEMICOLON, bad.To.TokenType)
+		assert.IsType(t, &BadExpr{}, bad.Partial.(*PrintStmt).Expr.(*BinaryExpr).RightExpr)
+		assert.IsType(t, &VariableDeclarationStmt{}, stmts[1])
+	}
+	assert.Equal(t, "(bad\n  (print (+ 1.00 (bad))))\n(var a 2.00)\n", (&ASTPrinter{}).PrintProgram(stmts))
+	assert.Equal(t, 1, StmtLine(stmts[0]))
+
+	// a broken test header leaves a test without a body
+	p = NewParser(lexer.NewScanner(`test "no body";`).ScanTokens())
+	stmts = p.Parse()
+	if assert.NotEmpty(t, stmts) {
+		test := stmts[0].(*BadStmt).Partial.(*TestStmt)
+		assert.Equal(t, "no body", test.Name)
+		assert.Empty(t, test.Body)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	type TestCase struct {
+		ID       int
+		Source   string
+		Errors   int
+		Expected string
+	}
+	testCases := []TestCase{
+		// every statement is reported, not just the first
+		{ID: 1, Source: "print ; var = 1; 1 = 2;", Errors: 3, Expected: "(bad\n  (print (bad)))\n(bad\n  (var  1.00))\n(bad\n  (expr 1.00))\n"},
+		// an error in a function body doesn't throw away the statements after the function
+		{ID: 2, Source: "fun f() { print ; } a = 1; b;", Errors: 1, Expected: "(fun f ()\n  (bad\n    (print (bad))))\n(expr (= a 1.00))\n(expr b)\n"},
+		// recovery stops at the end of the enclosing block instead of skipping past it
+		{ID: 3, Source: "{ x + } print 1;", Errors: 1, Expected: "(block\n  (bad\n    (expr (+ x (bad)))))\n(print 1.00)\n"},
+		// and at statements that don't start with one of the classic keywords
+		{ID: 4, Source: "a + ; match (a) { case _ => print a; }", Errors: 1, Expected: "(bad\n  (expr (+ a (bad))))\n(match a\n  (case _\n    (print a)))\n"},
+		// a misplaced test parses completely, so the rest of the block is kept
+		{ID: 5, Source: `{ test "t" {} print 1; }`, Errors: 1, Expected: "(block\n  (bad\n    (test \"t\"))\n  (print 1.00))\n"},
+		// only the first error of a statement is reported, the ones after it are caused by it
+		{ID: 6, Source: "var a = (1 + ; print 1;", Errors: 1, Expected: "(bad\n  (var a (group (+ 1.00 (bad)))))\n(print 1.00)\n"},
+	}
+
+	for _, testCase := range testCases {
+		p := NewParser(lexer.NewScanner(testCase.Source).ScanTokens())
+		stmts := p.Parse()
+
+		assert.Len(t, p.Errors, testCase.Errors, "test case %d failed", testCase.ID)
+		assert.Equal(t, testCase.Expected, (&ASTPrinter{}).PrintProgram(stmts), "test case %d failed", testCase.ID)
+	}
+}
+
+func TestParseTokensWithoutEOF(t *testing.T) {
+	for _, tokens := range [][]lexer.Token{
+		nil,
+		{{TokenType: lexer.PRINT, Lexeme: "print", Line: 3}},
+		{{TokenType: lexer.RIGHT_BRACE, Lexeme: "}", Line: 1}, {TokenType: lexer.LEFT_BRACE, Lexeme: "{", Line: 1}},
+	} {
+		stmts, diagnostics := Parse(tokens)
+		assert.NotContains(t, stmts, nil)
+		if len(tokens) > 0 {
+			assert.NotEmpty(t, diagnostics)
+		}
+	}
+
+	_, diagnostics := Parse([]lexer.Token{{TokenType: lexer.PRINT, Lexeme: "print", Line: 3}})
+	if assert.NotEmpty(t, diagnostics) {
+		assert.Equal(t, "[line 3] Error at end: Expect expression.", diagnostics[0].String())
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	stmts, diagnostics := Parse(lexer.NewScanner("match (1) { case _ => print 1; case 2 => print 2; }\nprint ;").ScanTokens())
+
+	assert.Len(t, stmts, 2)
+	assert.Equal(t, []Diagnostic{
+		{Line: 1, Where: "at case", Message: "unreachable case, a previous case matches every value. wildcard cases should come last.", Warning: true},
+		{Line: 2, Where: "at ;", Message: "Expect expression."},
+	}, diagnostics)
+	assert.Equal(t, "[line 1] Warning at case: unreachable case, a previous case matches every value. wildcard cases should come last.", diagnostics[0].String())
+	assert.Equal(t, "[line 2] Error at ;: Expect expression.", diagnostics[1].String())
+}
+
+func TestNestingLimit(t *testing.T) {
+	nested := func(depth int) string {
+		return "print " + strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth) + ";\nprint 2;"
+	}
+
+	chain := func(length int, operator string) string {
+		return "print a" + strings.Repeat(operator+"a", length) + ";\nprint 2;"
+	}
+
+	for _, source := range []string{nested(maxNesting), chain(maxNesting, " + "), chain(maxNesting, ".")} {
+		p := NewParser(lexer.NewScanner(source).ScanTokens())
+		stmts := p.Parse()
+		assert.Empty(t, p.Errors)
+		assert.Len(t, stmts, 2)
+	}
+
+	// code nested too deeply is reported once and skipped, and parsing carries on after it
+	cases := map[string]string{
+		nested(maxNesting + 1): "[line 1] Error at 1: expression is nested too deeply.",
+		nested(tooDeep):        "[line 1] Error at (: expression is nested too deeply.",
+		// each operator of a chain nests everything before it a level deeper
+		chain(maxNesting+1, " + "): "[line 1] Error at a: expression is nested too deeply.",
+		chain(tooDeep, " + "):      "[line 1] Error at a: expression is nested too deeply.",
+		chain(tooDeep, " ?? "):     "[line 1] Error at a: expression is nested too deeply.",
+		chain(tooDeep, "."):        "[line 1] Error at .: expression is nested too deeply.",
+		"print f" + strings.Repeat("()", tooDeep) + ";\nprint 2;":                          "[line 1] Error at (: expression is nested too deeply.",
+		strings.Repeat("{", tooDeep) + strings.Repeat("}", tooDeep) + "\nprint 2;":         "[line 1] Error at {: statement is nested too deeply.",
+		strings.Repeat("fun f() {", tooDeep) + strings.Repeat("}", tooDeep) + "\nprint 2;": "[line 1] Error at fun: statement is nested too deeply.",
+	}
+	for source, message := range cases {
+		p := NewParser(lexer.NewScanner(source).ScanTokens())
+		stmts := p.Parse()
+		assert.Equal(t, []string{message}, p.Errors)
+		if assert.Len(t, stmts, 2) {
+			assert.IsType(t, &PrintStmt{}, stmts[1])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package parser
+
+// StmtLine is the line a statement starts on, or 0 when the parser made the statement up
+func StmtLine(stmt Stmt) int {
+	switch s := stmt.(type) {
+	case *PrintStmt:
+		return s.Keyword.Line
+	case *ExpressionStmt:
+		return ExprLine(s.Expr)
+	case *VariableDeclarationStmt:
+		return s.Name.Line
+	case *BlockStmt:
+		if s.Brace.Line == 0 && len(s.Stmts) > 0 {
+			// a desugared for loop starts where its initializer does
+			return StmtLine(s.Stmts[0])
+		}
+		return s.Brace.Line
+	case *IfStmt:
+		return s.Keyword.Line
+	case *WhileStmt:
+		return s.Keyword.Line
+	case *FunctionStmt:
+		return s.Name.Line
+	case *ReturnStmt:
+		return s.Keyword.Line
+	case *YieldStmt:
+		return s.Keyword.Line
+	case *MatchStmt:
+		return s.Keyword.Line
+	case *TestStmt:
+		return s.Keyword.Line
+	case *BadStmt:
+		return s.From.Line
+	default:
+		return 0
+	}
+}
+
+// ExprLine is the line of the leftmost token of an expression, or 0 when the parser made it up
+func ExprLine(expr Expr) int {
+	switch e := expr.(type) {
+	case *LiteralExpr:
+		return e.Token.Line
+	case *UnaryExpr:
+		return e.Operator.Line
+	case *BinaryExpr:
+		return ExprLine(e.LeftExpr)
+	case *GroupingExpr:
+		return ExprLine(e.Expr)
+	case *VariableExpr:
+		return e.Name.Line
+	case *AssignExpr:
+		return e.Name.Line
+	case *CompoundAssignExpr:
+		return e.Name.Line
+	case *IncrementExpr:
+		if e.Prefix {
+			return e.Operator.Line
+		}
+		return e.Name.Line
+	case *LogicalExpr:
+		return ExprLine(e.Left)
+	case *ConditionalExpr:
+		return ExprLine(e.Condition)
+	case *CallExpr:
+		return ExprLine(e.Callee)
+	case *GetExpr:
+		return ExprLine(e.Object)
+	case *OptionalChainExpr:
+		return ExprLine(e.Expr)
+	case *SpawnExpr:
+		return e.Keyword.Line
+	case *BadExpr:
+		return e.From.Line
+	default:
+		return 0
+	}
+}
@@ -5,14 +5,190 @@ import (
 	"strings"
 )
 
-// ASTPrinter implements the visitor interface
-type ASTPrinter struct{}
+// ASTPrinter implements the visitor interfaces, printing expressions as s-expressions and statements
+// as an indented tree of them
+type ASTPrinter struct {
+	builder strings.Builder
+	depth   int
+}
 
 func (a *ASTPrinter) Print(expr Expr) string {
 	val, _ := expr.Accept(a)
 	return val.(string)
 }
 
+// PrintProgram prints every statement on its own line, with nested statements indented below their parent
+func (a *ASTPrinter) PrintProgram(stmts []Stmt) string {
+	a.builder.Reset()
+	a.depth = 0
+	for _, stmt := range stmts {
+		a.stmt(stmt)
+		a.builder.WriteString("\n")
+	}
+	return a.builder.String()
+}
+
+func (a *ASTPrinter) stmt(stmt Stmt) {
+	if stmt == nil {
+		a.builder.WriteString("<error>")
+		return
+	}
+	stmt.Accept(a)
+}
+
+// node writes `(name exprs...` and, one level deeper, each child statement on a line of its own. the
+// caller writes anything else the node has, then closes it with `)`
+func (a *ASTPrinter) node(name string, exprs []Expr, children ...Stmt) {
+	a.builder.WriteString("(" + name)
+	for _, expr := range exprs {
+		a.builder.WriteString(" " + a.Print(expr))
+	}
+
+	a.depth++
+	for _, child := range children {
+		a.builder.WriteString("\n" + strings.Repeat("  ", a.depth))
+		a.stmt(child)
+	}
+	a.depth--
+}
+
+func (a *ASTPrinter) VisitPrintStmt(stmt *PrintStmt) error {
+	a.node("print", []Expr{stmt.Expr})
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitExpressionStmt(stmt *ExpressionStmt) error {
+	a.node("expr", []Expr{stmt.Expr})
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitVariableDeclStmt(stmt *VariableDeclarationStmt) error {
+	name := "var " + stmt.Name.Lexeme
+	if stmt.Type != nil {
+		name += ": " + stmt.Type.String()
+	}
+	exprs := []Expr{}
+	if stmt.Initializer != nil {
+		exprs = append(exprs, stmt.Initializer)
+	}
+	a.node(name, exprs)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitBlockStmt(stmt *BlockStmt) error {
+	a.node("block", nil, stmt.Stmts...)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitIfStmt(stmt *IfStmt) error {
+	children := []Stmt{stmt.ThenBranch}
+	if stmt.ElseBranch != nil {
+		children = append(children, stmt.ElseBranch)
+	}
+	a.node("if", []Expr{stmt.Condition}, children...)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitWhileStmt(stmt *WhileStmt) error {
+	a.node("while", []Expr{stmt.Condition}, stmt.Body)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitFunctionStmt(stmt *FunctionStmt) error {
+	name := "fun"
+	if stmt.Generator {
+		name = "fun*"
+	}
+	params := make([]string, len(stmt.Params))
+	for i, param := range stmt.Params {
+		params[i] = param.Lexeme
+		if i < len(stmt.ParamTypes) && stmt.ParamTypes[i] != nil {
+			params[i] += ": " + stmt.ParamTypes[i].String()
+		}
+	}
+	name += " " + stmt.Name.Lexeme + " (" + strings.Join(params, " ") + ")"
+	if stmt.ReturnType != nil {
+		name += ": " + stmt.ReturnType.String()
+	}
+
+	a.node(name, nil, stmt.Body...)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitReturnStmt(stmt *ReturnStmt) error {
+	exprs := []Expr{}
+	if stmt.Value != nil {
+		exprs = append(exprs, stmt.Value)
+	}
+	a.node("return", exprs)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitYieldStmt(stmt *YieldStmt) error {
+	exprs := []Expr{}
+	if stmt.Value != nil {
+		exprs = append(exprs, stmt.Value)
+	}
+	a.node("yield", exprs)
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitTestStmt(stmt *TestStmt) error {
+	a.node(fmt.Sprintf("test %q", stmt.Name), nil, stmt.Body...)
+	a.builder.WriteString(")")
+	return nil
+}
+
+// a bad statement prints whatever part of it did parse as its child
+func (a *ASTPrinter) VisitBadStmt(stmt *BadStmt) error {
+	if stmt.Partial == nil {
+		a.node("bad", nil)
+	} else {
+		a.node("bad", nil, stmt.Partial)
+	}
+	a.builder.WriteString(")")
+	return nil
+}
+
+func (a *ASTPrinter) VisitMatchStmt(stmt *MatchStmt) error {
+	a.node("match", []Expr{stmt.Subject})
+
+	a.depth++
+	for _, matchCase := range stmt.Cases {
+		patterns := make([]string, len(matchCase.Patterns))
+		for i, pattern := range matchCase.Patterns {
+			switch pattern.Kind {
+			case LiteralPattern:
+				patterns[i] = a.Print(pattern.Literal)
+			default:
+				patterns[i] = pattern.Token.Lexeme
+			}
+		}
+
+		name := "case " + strings.Join(patterns, " ")
+		if matchCase.Guard != nil {
+			name += " (if " + a.Print(matchCase.Guard) + ")"
+		}
+
+		a.builder.WriteString("\n" + strings.Repeat("  ", a.depth))
+		a.node(name, nil, matchCase.Body)
+		a.builder.WriteString(")")
+	}
+	a.depth--
+
+	a.builder.WriteString(")")
+	return nil
+}
+
 func (a *ASTPrinter) VisitBinaryExpr(expr *BinaryExpr) (any, error) {
 	return a.parenthesize(expr.Operator.Lexeme, expr.LeftExpr, expr.RightExpr), nil
 }
@@ -54,19 +230,55 @@ func (a *ASTPrinter) VisitVariableExpr(expr *VariableExpr) (any, error) {
 	return expr.Name.Lexeme, nil
 }
 
-// todo: haven't implemented yet
 func (a *ASTPrinter) VisitAssignExpr(expr *AssignExpr) (any, error) {
-	return expr.Name.Lexeme, nil
+	return a.parenthesize("= "+expr.Name.Lexeme, expr.Value), nil
+}
+
+func (a *ASTPrinter) VisitCompoundAssignExpr(expr *CompoundAssignExpr) (any, error) {
+	return a.parenthesize(expr.Operator.Lexeme+" "+expr.Name.Lexeme, expr.Value), nil
+}
+
+func (a *ASTPrinter) VisitIncrementExpr(expr *IncrementExpr) (any, error) {
+	if expr.Prefix {
+		return a.parenthesize(expr.Operator.Lexeme + " " + expr.Name.Lexeme), nil
+	}
+	return a.parenthesize("post" + expr.Operator.Lexeme + " " + expr.Name.Lexeme), nil
+}
+
+func (a *ASTPrinter) VisitConditionalExpr(expr *ConditionalExpr) (any, error) {
+	return a.parenthesize("?:", expr.Condition, expr.ThenBranch, expr.ElseBranch), nil
 }
 
-// todo: haven't implemented yet
 func (a *ASTPrinter) VisitLogicalExpr(expr *LogicalExpr) (any, error) {
-	return expr.Left, nil
+	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
 }
 
-// todo: haven't implemented yet
 func (a *ASTPrinter) VisitCallExpr(expr *CallExpr) (any, error) {
-	return expr.Callee, nil
+	name := "call"
+	if expr.Optional {
+		name = "?.call"
+	}
+	return a.parenthesize(name, append([]Expr{expr.Callee}, expr.Arguments...)...), nil
+}
+
+func (a *ASTPrinter) VisitGetExpr(expr *GetExpr) (any, error) {
+	if expr.Optional {
+		return a.parenthesize("?."+expr.Name.Lexeme, expr.Object), nil
+	}
+	return a.parenthesize("."+expr.Name.Lexeme, expr.Object), nil
+}
+
+// the chain boundary only matters when evaluating, so it prints as the chain itself
+func (a *ASTPrinter) VisitOptionalChainExpr(expr *OptionalChainExpr) (any, error) {
+	return expr.Expr.Accept(a)
+}
+
+func (a *ASTPrinter) VisitSpawnExpr(expr *SpawnExpr) (any, error) {
+	return a.parenthesize("spawn", expr.Call), nil
+}
+
+func (a *ASTPrinter) VisitBadExpr(expr *BadExpr) (any, error) {
+	return "(bad)", nil
 }
 
 func (a *ASTPrinter) parenthesize(name string, expr ...Expr) string {
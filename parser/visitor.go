@@ -7,8 +7,15 @@ type ExprVisitor interface {
 	VisitLiteralExpr(expr *LiteralExpr) (any, error)
 	VisitVariableExpr(expr *VariableExpr) (any, error)
 	VisitAssignExpr(expr *AssignExpr) (any, error)
+	VisitCompoundAssignExpr(expr *CompoundAssignExpr) (any, error)
+	VisitIncrementExpr(expr *IncrementExpr) (any, error)
 	VisitLogicalExpr(expr *LogicalExpr) (any, error)
+	VisitConditionalExpr(expr *ConditionalExpr) (any, error)
 	VisitCallExpr(expr *CallExpr) (any, error)
+	VisitGetExpr(expr *GetExpr) (any, error)
+	VisitOptionalChainExpr(expr *OptionalChainExpr) (any, error)
+	VisitSpawnExpr(expr *SpawnExpr) (any, error)
+	VisitBadExpr(expr *BadExpr) (any, error)
 }
 
 type StmtVisitor interface {
@@ -19,4 +26,9 @@ type StmtVisitor interface {
 	VisitIfStmt(stmt *IfStmt) error
 	VisitWhileStmt(stmt *WhileStmt) error
 	VisitFunctionStmt(stmt *FunctionStmt) error
+	VisitMatchStmt(stmt *MatchStmt) error
+	VisitReturnStmt(stmt *ReturnStmt) error
+	VisitYieldStmt(stmt *YieldStmt) error
+	VisitTestStmt(stmt *TestStmt) error
+	VisitBadStmt(stmt *BadStmt) error
 }
@@ -0,0 +1,169 @@
+package tester
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats are the report formats Report can write
+var Formats = []string{"text", "tap", "junit"}
+
+// Report writes results to w in one of Formats
+func Report(w io.Writer, format string, results []Result) error {
+	switch format {
+	case "text":
+		return reportText(w, results)
+	case "tap":
+		return reportTAP(w, results)
+	case "junit":
+		return reportJUnit(w, results)
+	default:
+		return fmt.Errorf("unknown report format '%s', expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Failed counts the results that didn't pass
+func Failed(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+func total(results []Result) time.Duration {
+	var duration time.Duration
+	for _, result := range results {
+		duration += result.Duration
+	}
+	return duration
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+}
+
+// reportText lists every test with its status and timing, followed by why each failure failed
+func reportText(w io.Writer, results []Result) error {
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s  %s:%d  %s (%s)\n", status, result.File, result.Line, result.Name, milliseconds(result.Duration))
+
+		if !result.Passed() {
+			fmt.Fprintf(w, "      line %d: %s\n", result.FailureLine, result.Failure)
+			for _, line := range strings.Split(strings.TrimSuffix(result.Output, "\n"), "\n") {
+				if line != "" {
+					fmt.Fprintf(w, "      | %s\n", line)
+				}
+			}
+		}
+	}
+
+	failed := Failed(results)
+	_, err := fmt.Fprintf(w, "\n%d tests, %d passed, %d failed (%s)\n", len(results), len(results)-failed, failed, milliseconds(total(results)))
+	return err
+}
+
+// reportTAP writes the Test Anything Protocol, version 13, with a YAML block describing each failure
+func reportTAP(w io.Writer, results []Result) error {
+	fmt.Fprintf(w, "TAP version 13\n1..%d\n", len(results))
+	for n, result := range results {
+		status := "ok"
+		if !result.Passed() {
+			status = "not ok"
+		}
+		fmt.Fprintf(w, "%s %d - %s: %s\n", status, n+1, result.File, result.Name)
+
+		if !result.Passed() {
+			fmt.Fprintf(w, "  ---\n  message: %q\n  at: %s:%d\n  duration_ms: %s\n", result.Failure, result.File, result.FailureLine, strings.TrimSuffix(milliseconds(result.Duration), "ms"))
+			if result.Output != "" {
+				fmt.Fprintf(w, "  output: %q\n", result.Output)
+			}
+			fmt.Fprintln(w, "  ...")
+		}
+	}
+	return nil
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// reportJUnit writes JUnit XML with a testsuite per file, in the order the files were run
+func reportJUnit(w io.Writer, results []Result) error {
+	report := junitSuites{Tests: len(results), Failures: Failed(results), Time: seconds(total(results))}
+
+	byFile := map[string]int{}
+	durations := []time.Duration{}
+	for _, result := range results {
+		idx, ok := byFile[result.File]
+		if !ok {
+			idx = len(report.Suites)
+			byFile[result.File] = idx
+			report.Suites = append(report.Suites, junitSuite{Name: result.File})
+			durations = append(durations, 0)
+		}
+
+		testCase := junitCase{Name: result.Name, Classname: result.File, Time: seconds(result.Duration)}
+		suite := &report.Suites[idx]
+		suite.Tests++
+		if !result.Passed() {
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: result.Failure,
+				Text:    fmt.Sprintf("%s:%d: %s", result.File, result.FailureLine, result.Failure),
+			}
+			testCase.SystemOut = result.Output
+		}
+		suite.Cases = append(suite.Cases, testCase)
+
+		durations[idx] += result.Duration
+		suite.Time = seconds(durations[idx])
+	}
+
+	fmt.Fprint(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,175 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brandonshearin/go-lox/formatter"
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/brandonshearin/go-lox/lexer"
+	"github.com/brandonshearin/go-lox/parser"
+)
+
+// Result is the outcome of a single test block
+type Result struct {
+	File string
+	Name string
+	Line int
+
+	// Failure is the error that stopped the test, empty when it passed. FailureLine is where it happened
+	Failure     string
+	FailureLine int
+	// Output is everything the test printed, including what the file's top level printed first
+	Output   string
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool { return r.Failure == "" }
+
+// Runner runs the `test` blocks declared in lox files. every test gets an interpreter of its own that
+// runs the rest of the file first, so tests only share what the file itself sets up, never each
+// other's changes to it
+type Runner struct {
+	// Filter selects tests by name, every test runs when it's nil
+	Filter *regexp.Regexp
+	// FS limits what the tests may touch on disk, see interpreter.FSPolicy
+	FS interpreter.FSPolicy
+	// Coverage collects what every test ran, across all the files run, when set
+	Coverage *interpreter.Coverage
+	// Context cancels whichever test is running, and every one after it. nil never cancels
+	Context context.Context
+	// Timeout fails a test that runs longer, 0 lets tests run as long as they like
+	Timeout time.Duration
+}
+
+// Discover returns the test files under each path: every *_test.lox file in a directory and its
+// subdirectories, or the path itself when it names a file
+func Discover(paths ...string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), "_test.lox") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// RunFile runs the tests in a file, failing when it can't be read or doesn't parse
+func (r *Runner) RunFile(file string) ([]Result, error) {
+	source, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return r.RunSource(file, string(source))
+}
+
+// RunSource runs the tests in source, reporting them as declared in file
+func (r *Runner) RunSource(file string, source string) ([]Result, error) {
+	s := lexer.NewScanner(source)
+	p := parser.NewParser(s.ScanTokens())
+	stmts := p.Parse()
+	if problems := append(append([]string{}, s.Errors...), p.Errors...); len(problems) > 0 {
+		return nil, fmt.Errorf("%s:\n%s", file, strings.Join(problems, "\n"))
+	}
+
+	if r.Coverage != nil {
+		r.Coverage.Register(file, source, stmts)
+	}
+
+	setup := []parser.Stmt{}
+	tests := []*parser.TestStmt{}
+	for _, stmt := range stmts {
+		if test, ok := stmt.(*parser.TestStmt); ok {
+			tests = append(tests, test)
+		} else {
+			setup = append(setup, stmt)
+		}
+	}
+
+	results := []Result{}
+	for _, test := range tests {
+		if r.Filter != nil && !r.Filter.MatchString(test.Name) {
+			continue
+		}
+		results = append(results, r.run(file, setup, test))
+	}
+	return results, nil
+}
+
+// run runs one test on a fresh interpreter, followed by any timers it started. timers run on a
+// virtual clock so tests never wait for them, but a test that loops forever is stopped by its Timeout
+func (r *Runner) run(file string, setup []parser.Stmt, test *parser.TestStmt) Result {
+	i := interpreter.NewInterpreter()
+	i.Stdout = io.Discard
+	i.FS = r.FS
+	i.Coverage = r.Coverage
+	i.Loop = interpreter.NewVirtualEventLoop()
+
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	if r.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	i.Context = ctx
+
+	start := time.Now()
+	err := i.Interpret(setup)
+	if err == nil {
+		err = i.RunTest(test)
+	}
+	if err == nil {
+		err = i.Loop.Run(i)
+	}
+	i.Shutdown()
+
+	result := Result{
+		File:     file,
+		Name:     test.Name,
+		Line:     test.Keyword.Line,
+		Output:   i.Output.String(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		interpreter.QuoteAssertion(err, formatter.Expr)
+		result.Failure, result.FailureLine = err.Message, err.Token.Line
+		// a timeout that struck between timer callbacks happened in no line, so it's the test's
+		if result.FailureLine == 0 {
+			result.FailureLine = result.Line
+		}
+	}
+	return result
+}
@@ -0,0 +1,205 @@
+package tester
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/brandonshearin/go-lox/interpreter"
+	"github.com/stretchr/testify/assert"
+)
+
+const mathTests = `fun add(a, b) { return a + b; }
+var counter = 0;
+
+test "adds" {
+  counter = counter + 1;
+  assertEqual(add(1, 2), 3);
+}
+
+test "starts from a fresh file" {
+  counter = counter + 1;
+  assertEqual(counter, 1);
+}
+
+test "fails" {
+  print "debug";
+  assertEqual(add(1, 2), 4);
+}
+
+test "waits for timers" {
+  var fired = false;
+  fun later() { fired = true; }
+  fun check() { assert(fired, "timer never fired"); }
+  setTimeout(later, 60000);
+  setTimeout(check, 120000);
+}
+`
+
+func TestRunSource(t *testing.T) {
+	results, err := (&Runner{}).RunSource("math_test.lox", mathTests)
+	assert.Nil(t, err)
+
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, "adds", results[0].Name)
+		assert.Equal(t, 4, results[0].Line)
+		for _, idx := range []int{0, 1, 3} {
+			assert.True(t, results[idx].Passed(), results[idx].Failure)
+		}
+
+		failed := results[2]
+		assert.False(t, failed.Passed())
+		assert.Equal(t, "assertion failed: assertEqual(add(1, 2), 4): got 3, want 4.", failed.Failure)
+		assert.Equal(t, 16, failed.FailureLine)
+		assert.Equal(t, "debug\n", failed.Output)
+	}
+	assert.Equal(t, 1, Failed(results))
+
+	results, err = (&Runner{Filter: regexp.MustCompile("^(adds|fails)$")}).RunSource("math_test.lox", mathTests)
+	assert.Nil(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "adds", results[0].Name)
+		assert.Equal(t, "fails", results[1].Name)
+	}
+}
+
+func TestRunSourceErrors(t *testing.T) {
+	_, err := (&Runner{}).RunSource("broken_test.lox", `test "x" { print ; }`)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "broken_test.lox:\n[line 1] Error at ;: Expect expression.")
+	}
+
+	// a file whose top level fails fails every test in it
+	results, err := (&Runner{}).RunSource("setup_test.lox", "var a = -\"x\";\ntest \"a\" {}\ntest \"b\" {}")
+	assert.Nil(t, err)
+	for _, result := range results {
+		assert.Equal(t, "operand must be a number.", result.Failure)
+		assert.Equal(t, 1, result.FailureLine)
+	}
+}
+
+func TestRunSourceTimeout(t *testing.T) {
+	source := "test \"hangs\" {\n  while (true) {}\n}\ntest \"waits\" {\n  fun never() {}\n  setInterval(never, 1);\n}\ntest \"passes\" {}"
+
+	// a test that never finishes fails once its time is up, and the tests after it still run
+	results, err := (&Runner{Timeout: 50 * time.Millisecond}).RunSource("hang_test.lox", source)
+	assert.Nil(t, err)
+	if assert.Len(t, results, 3) {
+		for _, result := range results[:2] {
+			assert.Equal(t, "execution cancelled: context deadline exceeded.", result.Failure, result.Name)
+			// cancelled between timer callbacks, a test still fails at a line of its own
+			assert.NotZero(t, result.FailureLine, result.Name)
+		}
+		assert.True(t, results[2].Passed(), results[2].Failure)
+	}
+
+	// cancelling the runner's Context fails every test left
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = (&Runner{Context: ctx}).RunSource("hang_test.lox", source)
+	assert.Nil(t, err)
+	for _, result := range results {
+		assert.Equal(t, "execution cancelled: context canceled.", result.Failure, result.Name)
+	}
+}
+
+func TestRunSourceCoverage(t *testing.T) {
+	runner := &Runner{Coverage: interpreter.NewCoverage()}
+	_, err := runner.RunSource("math_test.lox", mathTests)
+	assert.Nil(t, err)
+	_, err = runner.RunSource("more_test.lox", "test \"x\" {\n  if (false) print 1;\n}")
+	assert.Nil(t, err)
+
+	files := runner.Coverage.Files()
+	if assert.Len(t, files, 2) {
+		// the top level runs again before each of the four tests, but each test body only runs once
+		lines := files[0].Lines()
+		assert.Equal(t, 4, lines[2])
+		assert.Equal(t, 1, lines[5])
+		assert.Equal(t, 4, lines[1])
+
+		assert.Equal(t, "more_test.lox", files[1].Path)
+		assert.Equal(t, [2]int{0, 1}, files[1].Branches()[0].Taken)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b_test.lox", "a_test.lox", "main.lox", "sub/c_test.lox", "sub/test.lox"} {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.Nil(t, os.WriteFile(path, []byte(""), 0o644))
+	}
+
+	files, err := Discover(dir, filepath.Join(dir, "main.lox"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a_test.lox"),
+		filepath.Join(dir, "b_test.lox"),
+		filepath.Join(dir, "main.lox"),
+		filepath.Join(dir, "sub", "c_test.lox"),
+	}, files)
+
+	_, err = Discover(filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+}
+
+var reportResults = []Result{
+	{File: "a_test.lox", Name: "passes", Line: 1, Duration: 1500 * time.Microsecond},
+	{File: "a_test.lox", Name: "fails", Line: 3, Failure: "assertion failed: assert(x).", FailureLine: 4, Output: "debug\n", Duration: 2 * time.Millisecond},
+	{File: "b_test.lox", Name: "also passes", Line: 1, Duration: 500 * time.Microsecond},
+}
+
+func TestReport(t *testing.T) {
+	cases := []struct {
+		Format   string
+		Expected string
+	}{
+		{Format: "text", Expected: `PASS  a_test.lox:1  passes (1.50ms)
+FAIL  a_test.lox:3  fails (2.00ms)
+      line 4: assertion failed: assert(x).
+      | debug
+PASS  b_test.lox:1  also passes (0.50ms)
+
+3 tests, 2 passed, 1 failed (4.00ms)
+`},
+		{Format: "tap", Expected: `TAP version 13
+1..3
+ok 1 - a_test.lox: passes
+not ok 2 - a_test.lox: fails
+  ---
+  message: "assertion failed: assert(x)."
+  at: a_test.lox:4
+  duration_ms: 2.00
+  output: "debug\n"
+  ...
+ok 3 - b_test.lox: also passes
+`},
+		{Format: "junit", Expected: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" time="0.004">
+  <testsuite name="a_test.lox" tests="2" failures="1" time="0.004">
+    <testcase name="passes" classname="a_test.lox" time="0.002"></testcase>
+    <testcase name="fails" classname="a_test.lox" time="0.002">
+      <failure message="assertion failed: assert(x).">a_test.lox:4: assertion failed: assert(x).</failure>
+      <system-out>debug&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="b_test.lox" tests="1" failures="0" time="0.001">
+    <testcase name="also passes" classname="b_test.lox" time="0.001"></testcase>
+  </testsuite>
+</testsuites>
+`},
+	}
+
+	for _, testCase := range cases {
+		var out bytes.Buffer
+		assert.Nil(t, Report(&out, testCase.Format, reportResults), testCase.Format)
+		assert.Equal(t, testCase.Expected, out.String(), testCase.Format)
+	}
+
+	assert.EqualError(t, Report(&bytes.Buffer{}, "xml", reportResults), "unknown report format 'xml', expected one of text, tap, junit")
+}